@@ -1,138 +1,77 @@
 package main
 
 import (
-	"bytes"
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"fmt"
-	"github.com/go-gota/gota/dataframe"
 	"github.com/go-gota/gota/series"
-	"io/ioutil"
-	"math/rand"
 )
 
 const path = "../datasets/bmi/SOCR_Data_MLB_HeightsWeights.csv"
 
+var schema = mlutil.Schema{
+	Header: mlutil.MatchHeader,
+	Columns: []mlutil.ColumnSpec{
+		{Name: "Name", Type: series.String},
+		{Name: "Team", Type: series.String},
+		{Name: "Position", Type: series.String, Categories: []string{"Catcher", "Designated_Hitter", "First_Baseman", "Outfielder", "Relief_Pitcher", "Second_Baseman", "Shortstop", "Starting_Pitcher", "Third_Baseman"}},
+		{Name: "Height(inches)", Type: series.Float, Unit: "inches", Range: &mlutil.Fences{Lower: 48, Upper: 96}},
+		{Name: "Weight(pounds)", Type: series.Float, Unit: "pounds", Nullable: true, Range: &mlutil.Fences{Lower: 80, Upper: 400}},
+		{Name: "Age", Type: series.Float, Unit: "years", Range: &mlutil.Fences{Lower: 15, Upper: 60}},
+	},
+}
+
 func main() {
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, schema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b))
 
-	df = df.Select([]string{"Position", "Height(inches)", "Weight(pounds)", "Age"})
+	df = df.Select([]string{"Team", "Position", "Height(inches)", "Weight(pounds)", "Age"})
 	df = df.Rename("Height", "Height(inches)")
 	df = df.Rename("Weight", "Weight(pounds)")
 
-	df = df.Mutate(series.New(df.Col("Height"), series.Float, "Height"))
-	df = df.Mutate(series.New(df.Col("Weight"), series.Float, "Weight"))
-
-	df = df.Filter(dataframe.F{1, "Weight", "<", 260})
-
-	df.Col("Height").Min()
-
-	df = rescale(df, "Height")
-	df = rescale(df, "Weight")
-
-	perm := rand.Perm(df.Nrow())
-
-	df.Subset(perm[0:int(0.7*float64(len(perm)))])
-
-	split(df, 0.7)
-
-	df.Col("Position")
-	UniqueValues(df, "Position")
-	ohSeries := OneHotSeries(df, "Position", UniqueValues(df, "Position"))
-	dfEncoded := df.Mutate(ohSeries[0])
-	for i := 1; i < len(ohSeries); i++ {
-		dfEncoded = dfEncoded.Mutate(ohSeries[i])
+	//  One of the players has no weight
+	df, err = mlutil.DropMissing(df, "Weight")
+	if err != nil {
+		panic(err)
 	}
 
-	dfEncoded = dfEncoded.Drop("Position")
-
-}
-
-//  rescale maps the given column values onto the range [0,1]
-func rescale(df dataframe.DataFrame, col string) dataframe.DataFrame {
-	s := df.Col(col)
-	min := s.Min()
-	max := s.Max()
-	v := make([]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		v[i] = (s.Elem(i).Float() - min) / (max - min)
+	training, validation, err := mlutil.SplitWith(df, 0.7, mlutil.SplitConfig{Seed: 42, Stratify: "Position"})
+	if err != nil {
+		panic(err)
 	}
-	rs := series.Floats(v)
-	rs.Name = col
-	return df.Mutate(rs)
-}
 
-//  meanNormalise maps the given column values onto the range [-1,1] by subtracting mean and dividing by max - min
-func meanNormalise(df dataframe.DataFrame, col string) dataframe.DataFrame {
-	s := df.Col(col)
-	min := s.Min()
-	max := s.Max()
-	mean := s.Mean()
-	v := make([]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		v[i] = (s.Elem(i).Float() - mean) / (max - min)
+	//  Remove the players whose height or weight is far from the median of the training set. The modified z-score
+	//  is based on the median absolute deviation, so unlike the mean and standard deviation it is not pulled towards
+	//  the outliers it is looking for
+	outliers := mlutil.NewOutlierDetector(mlutil.OutlierMAD, 0, "Height", "Weight")
+	err = outliers.Fit(training)
+	if err != nil {
+		panic(err)
 	}
-	rs := series.Floats(v)
-	rs.Name = col
-	return df.Mutate(rs)
-}
-
-//  meanNormalise maps the given column values onto the range [-1,1] by subtracting mean and dividing by max - min
-func standardise(df dataframe.DataFrame, col string) dataframe.DataFrame {
-	s := df.Col(col)
-	std := s.StdDev()
-	mean := s.Mean()
-	v := make([]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		v[i] = (s.Elem(i).Float() - mean) / std
+	training, report, err := outliers.Filter(training)
+	if err != nil {
+		panic(err)
 	}
-	rs := series.Floats(v)
-	rs.Name = col
-	return df.Mutate(rs)
-}
-
-//  split splits the dataframe into training and validation subsets. valFraction (0 <= valFraction <= 1) of the samples
-//  are reserved for validation and the rest are for training.
-func split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:len(perm)])
-	return training, validation
-}
-
-func UniqueValues(df dataframe.DataFrame, col string) []string {
-	var ret []string
-	m := make(map[string]bool)
-	for _, val := range df.Col(col).Records() {
-		m[val] = true
+	fmt.Printf("Outliers:\n%v", report)
+
+	//  Learn the scaling and the Position and Team categories from the training set only, so that no statistics of
+	//  the validation set leak into training, then apply the same preprocessing to the validation set. Positions that
+	//  are not in the training set are one-hot encoded as all zeros. There are 30 teams, so rather than adding a
+	//  column for each, Team is replaced by its share of the players
+	encoder := mlutil.NewOneHotEncoder("Position")
+	encoder.Unknown = mlutil.UnknownIgnore
+	teams := mlutil.NewFrequencyEncoder("Team")
+	teams.Normalize = true
+	preprocessing := mlutil.NewPipeline(mlutil.NewMinMaxScaler("Height", "Weight"), encoder, teams)
+	training, err = preprocessing.FitTransform(training)
+	if err != nil {
+		panic(err)
 	}
-	for key := range m {
-		ret = append(ret, key)
+	validation, err = preprocessing.Transform(validation)
+	if err != nil {
+		panic(err)
 	}
-	return ret
-}
+	fmt.Println(training.Names())
 
-func OneHotSeries(df dataframe.DataFrame, col string, vals []string) []series.Series {
-	m := make(map[string]int)
-	s := make([]series.Series, len(vals), len(vals))
-	//cache the mapping for performance reasons
-	for i := range vals {
-		m[vals[i]] = i
-	}
-	for i := range s {
-		vals := make([]int, df.Col(col).Len(), df.Col(col).Len())
-		for j, val := range df.Col(col).Records() {
-			if i == m[val] {
-				vals[j] = 1
-			}
-		}
-		s[i] = series.Ints(vals)
-	}
-	for i := range vals {
-		s[i].Name = vals[i]
-	}
-	return s
 }
@@ -1,24 +1,34 @@
 package main
 
 import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"bufio"
 	"bytes"
 	"fmt"
 	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
-	"io/ioutil"
 )
 
 const path = "../datasets/bmi/500_Person_Gender_Height_Weight_Index.csv"
 
+var schema = mlutil.Schema{
+	Header: mlutil.MatchHeader,
+	Columns: []mlutil.ColumnSpec{
+		{Name: "Gender", Type: series.String, Categories: []string{"Female", "Male"}},
+		{Name: "Height", Type: series.Float, Unit: "cm", Range: &mlutil.Fences{Lower: 50, Upper: 250}},
+		{Name: "Weight", Type: series.Float, Unit: "kg", Range: &mlutil.Fences{Lower: 20, Upper: 250}},
+		{Name: "Index", Type: series.Int, Range: &mlutil.Fences{Lower: 0, Upper: 5}},
+	},
+}
+
 func main() {
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, schema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b))
 
 	fmt.Println("Minimum", df.Col("Height").Min())
 	fmt.Println("Maximum", df.Col("Height").Max())
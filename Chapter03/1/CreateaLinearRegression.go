@@ -1,38 +1,96 @@
 package main
 
 import (
-	"bytes"
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
+	"encoding/json"
 	"fmt"
 	"github.com/cdipaolo/goml/base"
 	"github.com/cdipaolo/goml/linear"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
-	"gonum.org/v1/gonum/stat"
-	"io/ioutil"
-	"math/rand"
 )
 
 func main() {
 	const path = "../datasets/housing/CaliforniaHousing/cal_housing.data"
-	columns := []string{"longitude", "latitude", "housingMedianAge", "totalRooms", "totalBedrooms", "population", "households", "medianIncome", "medianHouseValue"}
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, mlutil.CaliforniaHousingSchema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b), dataframe.Names(columns...))
 
-	df = df.Mutate(Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms"))
-	df = df.Mutate(Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms"))
-	df = df.Mutate(Divide(df.Col("population"), df.Col("households"), "averageOccupancy"))
-	df = df.Mutate(MultiplyConst(df.Col("medianHouseValue"), 0.00001))
+	averageRooms, err := mlutil.Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms")
+	if err != nil {
+		panic(err)
+	}
+	averageBedrooms, err := mlutil.Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms")
+	if err != nil {
+		panic(err)
+	}
+	averageOccupancy, err := mlutil.Divide(df.Col("population"), df.Col("households"), "averageOccupancy")
+	if err != nil {
+		panic(err)
+	}
+	df = df.Mutate(averageRooms)
+	df = df.Mutate(averageBedrooms)
+	df = df.Mutate(averageOccupancy)
+	df = df.Mutate(mlutil.MultiplyConst(df.Col("medianHouseValue"), 0.00001))
 	df = df.Select([]string{"medianIncome", "housingMedianAge", "averageRooms", "averageBedrooms", "population", "averageOccupancy", "latitude", "longitude", "medianHouseValue"})
 
-	training, validation := Split(df, 0.75)
+	training, validation, err := mlutil.SplitWith(df, 0.75, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
+	}
 
-	trainingX, trainingY := DataFrameToXYs(training, "medianHouseValue")
-	validationX, validationY := DataFrameToXYs(validation, "medianHouseValue")
+	//  Fill any missing values with the medians of the training set
+	missing, err := mlutil.MissingValues(training)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Missing values:\n%v", missing)
+	features := mlutil.FeatureNames(training, "medianHouseValue")
+	imputer := mlutil.NewPipeline(mlutil.NewImputer(mlutil.ImputeMedian, features...))
+	training, err = imputer.FitTransform(training)
+	if err != nil {
+		panic(err)
+	}
+	validation, err = imputer.Transform(validation)
+	if err != nil {
+		panic(err)
+	}
+
+	trainingX, trainingY, err := mlutil.DataFrameToXYs(training, "medianHouseValue")
+	if err != nil {
+		panic(err)
+	}
+	validationX, validationY, err := mlutil.DataFrameToXYs(validation, "medianHouseValue")
+	if err != nil {
+		panic(err)
+	}
+
+	//  Pick the learning rate and regularization by 5-fold cross-validation of 20 random candidates on the training set,
+	//  rather than guessing them. Candidates whose gradient ascent diverges are ranked last.
+	search := mlutil.Search{
+		New: func(p mlutil.Params) (mlutil.Model, error) {
+			return mlutil.NewLeastSquaresModel(base.BatchGA, p["learningRate"], p["regularization"], 150), nil
+		},
+		Space: mlutil.ParamSpace{
+			"learningRate":   mlutil.LogUniform{Min: 1e-4, Max: 1e-1},
+			"regularization": mlutil.Uniform{Min: 0, Max: 10},
+		},
+		Scorer:    mlutil.MeanSquaredError,
+		ScoreName: "MSE",
+		Minimise:  true,
+		Seed:      42,
+	}
+	tuning, err := search.Random(trainingX, trainingY, 20)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(tuning)
+	if err := tuning.SaveCSV("../models/housing_least_squares_tuning.csv"); err != nil {
+		panic(err)
+	}
+	best := tuning.Best().Params
+	learningRate, regularization := best["learningRate"], best["regularization"]
 
-	model := linear.NewLeastSquares(base.BatchGA, 1e-2, 6, 150, trainingX, trainingY)
+	model := linear.NewLeastSquares(base.BatchGA, learningRate, regularization, 150, trainingX, trainingY)
 
 	//Train
 	err = model.Learn()
@@ -41,96 +99,63 @@ func main() {
 	}
 
 	//On validation set
-	errors := make([]float64, len(validationX), len(validationX))
+	predictions := make([]float64, len(validationX), len(validationX))
 	for i := range validationX {
 		prediction, err := model.Predict(validationX[i])
 		if err != nil {
 			panic("Prediction error " + err.Error())
 		}
-		errors[i] = (prediction[0] - validationY[i]) * (prediction[0] - validationY[i])
+		predictions[i] = prediction[0]
+	}
+	report, err := mlutil.NewRegressionReport(validationY, predictions, len(validationX[0]))
+	if err != nil {
+		panic(err)
 	}
+	fmt.Printf("Validation:\n%v", report)
+	fmt.Print(report.ResidualsTable(10))
 
-	fmt.Printf("MSE: %5.2f\n", stat.Mean(errors, nil))
+	// Save the model so that it can be served by the Chapter05 prediction server
+	preprocessing, err := json.Marshal(imputer)
+	if err != nil {
+		panic(err)
+	}
+	err = mlutil.SaveModel("../models/housing_least_squares.json", mlutil.WrapLeastSquares(model), mlutil.ModelMetadata{
+		Features:      features,
+		Target:        "medianHouseValue",
+		Preprocessing: preprocessing,
+		Metrics:       report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
+	}
 
 	// On training set
-	errors = make([]float64, len(trainingX), len(trainingX))
+	predictions = make([]float64, len(trainingX), len(trainingX))
 	for i := range trainingX {
 		prediction, err := model.Predict(trainingX[i])
 		if err != nil {
 			panic("Prediction error " + err.Error())
 		}
-		errors[i] = (prediction[0] - trainingY[i]) * (prediction[0] - trainingY[i])
-	}
-
-	fmt.Printf("MSE: %5.2f\n", stat.Mean(errors, nil))
-}
-
-// Divide divides two series and returns a series with the given name. The series must have the same length.
-func Divide(s1 series.Series, s2 series.Series, name string) series.Series {
-	if s1.Len() != s2.Len() {
-		panic("Series must have the same length!")
+		predictions[i] = prediction[0]
 	}
-
-	ret := make([]interface{}, s1.Len(), s1.Len())
-	for i := 0; i < s1.Len(); i++ {
-		ret[i] = s1.Elem(i).Float() / s2.Elem(i).Float()
+	report, err = mlutil.NewRegressionReport(trainingY, predictions, len(trainingX[0]))
+	if err != nil {
+		panic(err)
 	}
-	s := series.Floats(ret)
-	s.Name = name
-	return s
-}
-
-//MultiplyConst multiplies the series by a constant and returns another series with the same name.
-func MultiplyConst(s series.Series, f float64) series.Series {
-	ret := make([]interface{}, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		ret[i] = s.Elem(i).Float() * f
-	}
-	ss := series.Floats(ret)
-	ss.Name = s.Name
-	return ss
-}
-
-func Split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:])
-	return training, validation
-}
+	fmt.Printf("Training:\n%v", report)
 
-//DataFrameToXYs converts a dataframe with float64 columns to a slice of independent variable columns as floats
-//  and the dependent variable (yCol). This can then be used with eg. goml's linear ML algorithms.
-//  yCol is optional - if it doesn't exist only the x (independent) variables will be returned.
-func DataFrameToXYs(df dataframe.DataFrame, yCol string) ([][]float64, []float64) {
-	var (
-		x      [][]float64
-		y      []float64
-		yColIx = -1
-	)
-
-	//find dependent variable column index
-	for i, col := range df.Names() {
-		if col == yCol {
-			yColIx = i
-			break
-		}
+	// 5-fold cross-validation on the whole dataset, fitting the median imputer on the training rows of every fold
+	folds, err := mlutil.KFold(df, 5, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
 	}
-	if yColIx == -1 {
-		fmt.Println("Warning - no dependent variable")
-	}
-	x = make([][]float64, df.Nrow(), df.Nrow())
-	y = make([]float64, df.Nrow())
-	for i := 0; i < df.Nrow(); i++ {
-		var xx []float64
-		for j := 0; j < df.Ncol(); j++ {
-			if j == yColIx {
-				y[i] = df.Elem(i, j).Float()
-				continue
-			}
-			xx = append(xx, df.Elem(i, j).Float())
-		}
-		x[i] = xx
+	cv, err := mlutil.CrossValidatePipeline(df, "medianHouseValue", folds, func() *mlutil.Pipeline {
+		return mlutil.NewPipeline(mlutil.NewImputer(mlutil.ImputeMedian, features...))
+	}, mlutil.ModelTrainFunc(func() mlutil.Model {
+		return mlutil.NewLeastSquaresModel(base.BatchGA, learningRate, regularization, 150)
+	}), map[string]mlutil.Scorer{"MSE": mlutil.MeanSquaredError, "MAE": mlutil.MeanAbsoluteError, "R2": mlutil.R2})
+	if err != nil {
+		panic(err)
 	}
-	return x, y
+	fmt.Print(cv)
 }
@@ -1,37 +1,51 @@
 package main
 
 import (
-	"bytes"
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"fmt"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
 	"github.com/sajari/regression"
-	"gonum.org/v1/gonum/stat"
-	"io/ioutil"
-	"math/rand"
 )
 
 const path = "../datasets/housing/CaliforniaHousing/cal_housing.data"
 
 func main() {
 
-	columns := []string{"longitude", "latitude", "housingMedianAge", "totalRooms", "totalBedrooms", "population", "households", "medianIncome", "medianHouseValue"}
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, mlutil.CaliforniaHousingSchema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b), dataframe.Names(columns...))
 
-	df = df.Mutate(Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms"))
-	df = df.Mutate(Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms"))
-	df = df.Mutate(Divide(df.Col("population"), df.Col("households"), "averageOccupancy"))
-	df = df.Mutate(MultiplyConst(df.Col("medianHouseValue"), 0.00001))
+	averageRooms, err := mlutil.Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms")
+	if err != nil {
+		panic(err)
+	}
+	averageBedrooms, err := mlutil.Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms")
+	if err != nil {
+		panic(err)
+	}
+	averageOccupancy, err := mlutil.Divide(df.Col("population"), df.Col("households"), "averageOccupancy")
+	if err != nil {
+		panic(err)
+	}
+	df = df.Mutate(averageRooms)
+	df = df.Mutate(averageBedrooms)
+	df = df.Mutate(averageOccupancy)
+	df = df.Mutate(mlutil.MultiplyConst(df.Col("medianHouseValue"), 0.00001))
 	df = df.Select([]string{"medianIncome", "housingMedianAge", "averageRooms", "averageBedrooms", "population", "averageOccupancy", "latitude", "longitude", "medianHouseValue"})
 
-	training, validation := Split(df, 0.75)
+	training, validation, err := mlutil.SplitWith(df, 0.75, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
+	}
 
-	trainingX, trainingY := DataFrameToXYs(training, "medianHouseValue")
-	validationX, validationY := DataFrameToXYs(validation, "medianHouseValue")
+	trainingX, trainingY, err := mlutil.DataFrameToXYs(training, "medianHouseValue")
+	if err != nil {
+		panic(err)
+	}
+	validationX, validationY, err := mlutil.DataFrameToXYs(validation, "medianHouseValue")
+	if err != nil {
+		panic(err)
+	}
 
 	model := new(regression.Regression)
 
@@ -43,97 +57,56 @@ func main() {
 	}
 
 	//On validation set
-	errors := make([]float64, len(validationX), len(validationX))
+	predictions := make([]float64, len(validationX), len(validationX))
 	for i := range validationX {
 		prediction, err := model.Predict(validationX[i])
 		if err != nil {
 			panic("Prediction error " + err.Error())
 		}
-		errors[i] = (prediction - validationY[i]) * (prediction - validationY[i])
+		predictions[i] = prediction
+	}
+	report, err := mlutil.NewRegressionReport(validationY, predictions, len(validationX[0]))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Validation:\n%v", report)
+
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/housing_ols.json", mlutil.WrapOLS(model), mlutil.ModelMetadata{
+		Features: mlutil.FeatureNames(training, "medianHouseValue"),
+		Target:   "medianHouseValue",
+		Metrics:  report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
 	}
-
-	fmt.Printf("MSE: %5.2f\n", stat.Mean(errors, nil))
 
 	// On training set
-	errors = make([]float64, len(trainingX), len(trainingX))
+	predictions = make([]float64, len(trainingX), len(trainingX))
 	for i := range trainingX {
 		prediction, err := model.Predict(trainingX[i])
 		if err != nil {
 			panic("Prediction error " + err.Error())
 		}
-		errors[i] = (prediction - trainingY[i]) * (prediction - trainingY[i])
+		predictions[i] = prediction
 	}
-
-	fmt.Printf("MSE: %5.2f\n", stat.Mean(errors, nil))
-
-}
-
-//Divide divides two series and returns a series with the given name. The series must have the same length.
-func Divide(s1 series.Series, s2 series.Series, name string) series.Series {
-	if s1.Len() != s2.Len() {
-		panic("Series must have the same length!")
-	}
-
-	ret := make([]interface{}, s1.Len(), s1.Len())
-	for i := 0; i < s1.Len(); i++ {
-		ret[i] = s1.Elem(i).Float() / s2.Elem(i).Float()
+	report, err = mlutil.NewRegressionReport(trainingY, predictions, len(trainingX[0]))
+	if err != nil {
+		panic(err)
 	}
-	s := series.Floats(ret)
-	s.Name = name
-	return s
-}
-
-//MultiplyConst multiplies the series by a constant and returns another series with the same name.
-func MultiplyConst(s series.Series, f float64) series.Series {
-	ret := make([]interface{}, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		ret[i] = s.Elem(i).Float() * f
-	}
-	ss := series.Floats(ret)
-	ss.Name = s.Name
-	return ss
-}
+	fmt.Printf("Training:\n%v", report)
 
-func Split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:])
-	return training, validation
-}
-
-//DataFrameToXYs converts a dataframe with float64 columns to a slice of independent variable columns as floats
-//  and the dependent variable (yCol). This can then be used with eg. goml's linear ML algorithms.
-//  yCol is optional - if it doesn't exist only the x (independent) variables will be returned.
-func DataFrameToXYs(df dataframe.DataFrame, yCol string) ([][]float64, []float64) {
-	var (
-		x      [][]float64
-		y      []float64
-		yColIx = -1
-	)
-
-	//find dependent variable column index
-	for i, col := range df.Names() {
-		if col == yCol {
-			yColIx = i
-			break
-		}
+	// 5-fold cross-validation on the whole dataset
+	folds, err := mlutil.KFold(df, 5, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
 	}
-	if yColIx == -1 {
-		fmt.Println("Warning - no dependent variable")
-	}
-	x = make([][]float64, df.Nrow(), df.Nrow())
-	y = make([]float64, df.Nrow())
-	for i := 0; i < df.Nrow(); i++ {
-		var xx []float64
-		for j := 0; j < df.Ncol(); j++ {
-			if j == yColIx {
-				y[i] = df.Elem(i, j).Float()
-				continue
-			}
-			xx = append(xx, df.Elem(i, j).Float())
-		}
-		x[i] = xx
+	cv, err := mlutil.CrossValidate(df, "medianHouseValue", folds, mlutil.ModelTrainFunc(func() mlutil.Model {
+		return mlutil.NewOLSModel()
+	}), map[string]mlutil.Scorer{"MSE": mlutil.MeanSquaredError, "MAE": mlutil.MeanAbsoluteError, "R2": mlutil.R2})
+	if err != nil {
+		panic(err)
 	}
-	return x, y
+	fmt.Print(cv)
+
 }
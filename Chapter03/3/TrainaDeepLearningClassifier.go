@@ -1,13 +1,13 @@
 package main
 
 import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"fmt"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
 	"github.com/patrikeh/go-deep"
 	"github.com/patrikeh/go-deep/training"
-	mnist "github.com/petar/GoMNIST"
-	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
 )
 
 var (
@@ -16,52 +16,61 @@ var (
 )
 
 func main() {
-	set, err := mnist.ReadSet("../datasets/mnist/images.gz", "../datasets/mnist/labels.gz")
+	images, err := mlutil.LoadMNIST("../datasets/mnist", "train")
 	if err != nil {
 		panic(err)
 	}
-	//set.Images[1]
-
-	df := MNISTSetToDataframe(set, 1000)
+	images = images.Slice(0, 1000)
 
 	categories := []string{"tshirt", "trouser", "pullover", "dress", "coat", "sandal", "shirt", "shoe", "bag", "boot"}
 
-	train, validation := Split(df, 0.75)
-
-	trainingImages := ImageSeriesToFloats(train, "Image")
-	validationImages := ImageSeriesToFloats(validation, "Image")
-
-	trainingOutputs := make([][]float64, len(trainingImages))
-	validationOutputs := make([][]float64, len(validationImages))
-
-	ltCol := train.Col("Label")
-	for i := range trainingImages {
-		l := make([]float64, len(categories), len(categories))
-		val, _ := ltCol.Elem(i).Int()
-		l[val] = 1
-		trainingOutputs[i] = l
+	train, validation, err := images.Split(0.75, mlutil.SplitConfig{Seed: 42, Stratify: "Label"})
+	if err != nil {
+		panic(err)
 	}
 
-	lvCol := validation.Col("Label")
-	for i := range validationImages {
-		l := make([]float64, len(categories), len(categories))
-		val, _ := lvCol.Elem(i).Int()
-		l[val] = 1
-		validationOutputs[i] = l
+	validationExamples, err = validation.Examples(len(categories))
+	if err != nil {
+		panic(err)
 	}
 
-	for i := range trainingImages {
-		trainingExamples = append(trainingExamples, training.Example{trainingImages[i], trainingOutputs[i]})
+	// Every trial trains a network, so choose the size of the hidden layers and the learning rate with Bayesian
+	// optimisation, which needs far fewer trials than a grid search. The trials are logged as they finish, and
+	// running the program again resumes the search from the log instead of repeating it.
+	search := mlutil.Search{
+		New: func(p mlutil.Params) (mlutil.Model, error) {
+			return mlutil.NewNeuralModel(deep.Config{
+				Layout:     []int{p.Int("hidden"), p.Int("hidden"), len(categories)},
+				Activation: deep.ActivationReLU,
+				Mode:       deep.ModeMultiClass,
+				Weight:     deep.NewNormal(0.5, 0.1),
+				Bias:       true,
+			}, training.NewSGD(p["learningRate"], 0.1, 1e-6, true), 50), nil
+		},
+		Space: mlutil.ParamSpace{
+			"hidden":       mlutil.IntRange{Min: 32, Max: 256, Step: 32},
+			"learningRate": mlutil.LogUniform{Min: 1e-3, Max: 1e-1},
+		},
+		Folds:    3,
+		Stratify: true,
+		Seed:     42,
+	}
+	logPath := "../models/fashion_neural_trials.jsonl"
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		panic(err)
 	}
-	for i := range validationImages {
-		validationExamples = append(validationExamples, training.Example{validationImages[i], validationOutputs[i]})
+	tuning, err := search.Bayesian(train.Floats(), train.LabelFloats(), 15, mlutil.Bayesian{Log: logPath})
+	if err != nil {
+		panic(err)
 	}
+	fmt.Print(tuning)
+	best := tuning.Best().Params
 
 	network := deep.NewNeural(&deep.Config{
 		// Input size: 784 in our case (number of pixels in each image)
-		Inputs: len(trainingImages[0]),
-		// Two hidden layers of 128 neurons each, and an output layer 10 neurons (one for each class)
-		Layout: []int{128, 128, len(categories)},
+		Inputs: train.Size(),
+		// Two hidden layers of the tuned size, and an output layer 10 neurons (one for each class)
+		Layout: []int{best.Int("hidden"), best.Int("hidden"), len(categories)},
 		// ReLU activation to introduce some additional non-linearity
 		Activation: deep.ActivationReLU,
 		// We need a multi-class model
@@ -72,93 +81,60 @@ func main() {
 	})
 
 	// Parameters: learning rate, momentum, alpha decay, nesterov
-	optimizer := training.NewSGD(0.006, 0.1, 1e-6, true)
-	trainer := training.NewTrainer(optimizer, 1)
-
-	trainer.Train(network, trainingExamples, validationExamples, 500) // training, validation, iterations
+	optimizer := training.NewSGD(best["learningRate"], 0.1, 1e-6, true)
+	// The trainer keeps the solver across epochs, so that the momentum and the learning rate decay carry over as
+	// they would in a single call to training.Trainer.Train
+	trainer := mlutil.NewEpochTrainer(optimizer)
+
+	// Show the network a differently augmented copy of the training images in every epoch. The augmenter is seeded,
+	// so that every run sees the same sequence of images.
+	augmenter := mlutil.NewAugmenter(42,
+		mlutil.Shift{Max: 2},
+		mlutil.HorizontalFlip{P: 0.5},
+		mlutil.Rotation{MaxDegrees: 10},
+		mlutil.Cutout{Size: 6},
+	)
+	printer := training.NewStatsPrinter()
+	printer.Init(network)
+	start := time.Now()
+	for epoch := 1; epoch <= 500; epoch++ {
+		trainingExamples, err = augmenter.Augment(train).Examples(len(categories))
+		if err != nil {
+			panic(err)
+		}
+		trainer.TrainEpoch(network, trainingExamples)
+		if epoch%50 == 0 {
+			printer.PrintProgress(network, validationExamples, time.Since(start), epoch)
+		}
+	}
 
 	validCorrect := 0.
-	for i := range validationImages {
-		prediction := network.Predict(validationImages[i])
+	actual := validation.LabelFloats()
+	predicted := make([]float64, len(validationExamples), len(validationExamples))
+	for i := range validationExamples {
+		prediction := network.Predict(validationExamples[i].Input)
 
-		if MaxIndex(prediction) == MaxIndex(validationOutputs[i]) {
+		predicted[i] = float64(mlutil.MaxIndex(prediction))
+		if predicted[i] == actual[i] {
 			validCorrect++
 		}
 	}
-	fmt.Printf("Validation Accuracy: %5.2f\n", validCorrect/float64(len(validationImages)))
-}
-
-func MNISTSetToDataframe(st *mnist.Set, maxExamples int) dataframe.DataFrame {
-	length := maxExamples
-	if length > len(st.Images) {
-		length = len(st.Images)
-	}
-	s := make([]string, length, length)
-	l := make([]int, length, length)
-	for i := 0; i < length; i++ {
-		s[i] = string(st.Images[i])
-		l[i] = int(st.Labels[i])
-	}
-	var df dataframe.DataFrame
-	images := series.Strings(s)
-	images.Name = "Image"
-	labels := series.Ints(l)
-	labels.Name = "Label"
-	df = dataframe.New(images, labels)
-	return df
-}
+	fmt.Printf("Validation Accuracy: %5.2f\n", validCorrect/float64(len(validationExamples)))
 
-func EqualsInt(s series.Series, to int) (*series.Series, error) {
-	eq := make([]int, s.Len(), s.Len())
-	ints, err := s.Int()
+	report, err := mlutil.NewClassificationReport(actual, predicted, categories)
 	if err != nil {
-		return nil, err
-	}
-	for i := range ints {
-		if ints[i] == to {
-			eq[i] = 1
-		}
-	}
-	ret := series.Ints(eq)
-	return &ret, nil
-}
-
-func Split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:])
-	return training, validation
-}
-
-func NormalizeBytes(bs []byte) []float64 {
-	ret := make([]float64, len(bs), len(bs))
-	for i := range bs {
-		ret[i] = float64(bs[i]) / 255.
-	}
-	return ret
-}
-
-func ImageSeriesToFloats(df dataframe.DataFrame, col string) [][]float64 {
-	s := df.Col(col)
-	ret := make([][]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		b := []byte(s.Elem(i).String())
-		ret[i] = NormalizeBytes(b)
+		panic(err)
 	}
-	return ret
-}
-
-func MaxIndex(f []float64) (i int) {
-	var (
-		curr float64
-		ix   int = -1
-	)
-	for i := range f {
-		if f[i] > curr {
-			curr = f[i]
-			ix = i
-		}
+	fmt.Print(report.Confusion)
+	fmt.Print(report)
+
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/fashion_neural.json", mlutil.WrapNeural(network), mlutil.ModelMetadata{
+		Target:  "Label",
+		Labels:  categories,
+		Metrics: report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
 	}
-	return ix
 }
@@ -1,45 +1,40 @@
 package main
 
 import (
-	"bufio"
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"bytes"
 	"fmt"
 	"github.com/cdipaolo/goml/base"
 	"github.com/cdipaolo/goml/linear"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
-	mnist "github.com/petar/GoMNIST"
-	"gonum.org/v1/gonum/integrate"
-	"gonum.org/v1/gonum/stat"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
+	"io/ioutil"
 	"math"
-	"math/rand"
 )
 
 func main() {
-	set, err := mnist.ReadSet("../datasets/mnist/images.gz", "../datasets/mnist/labels.gz")
-
-	//set.Images[1]
-
-	df := MNISTSetToDataframe(set, 1000)
+	images, err := mlutil.LoadMNIST("../datasets/mnist", "train")
+	if err != nil {
+		panic(err)
+	}
+	images = images.Slice(0, 1000)
 
 	categories := []string{"tshirt", "trouser", "pullover", "dress", "coat", "sandal", "shirt", "shoe", "bag", "boot"}
 
-	training, validation := Split(df, 0.75)
-
-	trainingIsTrouser, err1 := EqualsInt(training.Col("Label"), 1)
-	validationIsTrouser, err2 := EqualsInt(validation.Col("Label"), 1)
-	if err1 != nil || err2 != nil {
-		fmt.Println("Error", err1, err2)
+	training, validation, err := images.Split(0.75, mlutil.SplitConfig{Seed: 42, Stratify: "Label"})
+	if err != nil {
+		panic(err)
 	}
 
-	trainingImages := ImageSeriesToFloats(training, "Image")
-	validationImages := ImageSeriesToFloats(validation, "Image")
+	trainingIsTrouser := isTrouser(training.Labels)
+	validationIsTrouser := isTrouser(validation.Labels)
 
-	model := linear.NewLogistic(base.BatchGA, 1e-4, 1, 150, trainingImages, trainingIsTrouser.Float())
+	trainingImages := training.Floats()
+	validationImages := validation.Floats()
+
+	model := linear.NewLogistic(base.BatchGA, 1e-4, 1, 150, trainingImages, trainingIsTrouser)
 
 	//Train
 	err = model.Learn()
@@ -47,170 +42,121 @@ func main() {
 		fmt.Println(err)
 	}
 
-	//Count correct classifications
-	var correct = 0.
+	//Classify the validation set
+	predictions := make([]float64, len(validationImages), len(validationImages))
 	for i := range validationImages {
 		prediction, err := model.Predict(validationImages[i])
 		if err != nil {
 			panic(err)
 		}
-
-		if math.Round(prediction[0]) == validationIsTrouser.Elem(i).Float() {
-			correct++
-		}
+		predictions[i] = math.Round(prediction[0])
 	}
 
-	//accuracy
-	//correct/float64(len(validationImages))
-
-	//Count true positives and false negatives
-	var truePositives = 0.
-	var falsePositives = 0.
-	var falseNegatives = 0.
-	for i := range validationImages {
-		prediction, err := model.Predict(validationImages[i])
-		if err != nil {
-			panic(err)
-		}
-		if validationIsTrouser.Elem(i).Float() == 1 {
-			if math.Round(prediction[0]) == 0 {
-				// Predicted false, but actually true
-				falseNegatives++
-			} else {
-				// Predicted true, correctly
-				truePositives++
-			}
-		} else {
-			if math.Round(prediction[0]) == 1 {
-				// Predicted true, but actually false
-				falsePositives++
-			}
-		}
+	//accuracy, precision, recall etc.
+	report, err := mlutil.NewClassificationReport(validationIsTrouser, predictions, []string{"other", "trouser"})
+	if err != nil {
+		panic(err)
 	}
+	fmt.Print(report.Confusion)
+	fmt.Print(report)
 
-	//precision
-	//truePositives/(truePositives+falsePositives)
-
-	//recall
-	//truePositives/(truePositives+falseNegatives)
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/fashion_is_trousers.json", mlutil.WrapLogistic(model), mlutil.ModelMetadata{
+		Target:  "isTrouser",
+		Labels:  []string{"other", "trouser"},
+		Metrics: report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
+	}
 
-	model2 := linear.NewSoftmax(base.BatchGA, 1e-4, 1, 10, 100, trainingImages, training.Col("Label").Float())
+	model2 := linear.NewSoftmax(base.BatchGA, 1e-4, 1, 10, 100, trainingImages, training.LabelFloats())
 
 	//Train
 	err = model2.Learn()
 	if err != nil {
 		fmt.Println(err)
 	}
-	//create objects for ROC generation
-	//as per https://godoc.org/github.com/gonum/stat#ROC
-	y := make([][]float64, len(categories), len(categories))
-	classes := make([][]bool, len(categories), len(categories))
+	//class probabilities for ROC generation
+	probs := make([][]float64, len(validationImages), len(validationImages))
+	predictions = make([]float64, len(validationImages), len(validationImages))
 	//Validate
-	for i := 0; i < validation.Col("Image").Len(); i++ {
+	for i := range validationImages {
 		prediction, err := model2.Predict(validationImages[i])
 		if err != nil {
 			panic(err)
 		}
-		for j := range categories {
-			y[j] = append(y[j], prediction[j])
-			classes[j] = append(classes[j], validation.Col("Label").Elem(i).Float() != float64(j))
-		}
+		predictions[i] = float64(mlutil.MaxIndex(prediction))
+		probs[i] = prediction
 	}
 
-	//Calculate ROC
-	tprs := make([][]float64, len(categories), len(categories))
-	fprs := make([][]float64, len(categories), len(categories))
-
-	for i := range categories {
-		stat.SortWeightedLabeled(y[i], classes[i], nil)
-		cutoffs := make([]float64, len(categories))
-		tprs[i], fprs[i], _ = stat.ROC(cutoffs, y[i], classes[i], nil)
+	report, err = mlutil.NewClassificationReport(validation.LabelFloats(), predictions, categories)
+	if err != nil {
+		panic(err)
 	}
+	fmt.Print(report.Confusion)
+	fmt.Print(report)
 
-	for i := range categories {
-		fmt.Println(categories[i])
-		auc := integrate.Trapezoidal(fprs[i], tprs[i])
-		fmt.Println(auc)
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/fashion_softmax.json", mlutil.WrapSoftmax(model2), mlutil.ModelMetadata{
+		Target:  "Label",
+		Labels:  categories,
+		Metrics: report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
 	}
 
-	//display.JPEG(plotROCBytes(fprs, tprs, categories))
-}
-
-func MNISTSetToDataframe(st *mnist.Set, maxExamples int) dataframe.DataFrame {
-	length := maxExamples
-	if length > len(st.Images) {
-		length = len(st.Images)
-	}
-	s := make([]string, length, length)
-	l := make([]int, length, length)
-	for i := 0; i < length; i++ {
-		s[i] = string(st.Images[i])
-		l[i] = int(st.Labels[i])
-	}
-	var df dataframe.DataFrame
-	images := series.Strings(s)
-	images.Name = "Image"
-	labels := series.Ints(l)
-	labels.Name = "Label"
-	df = dataframe.New(images, labels)
-	return df
-}
-
-func Split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:])
-	return training, validation
-}
-
-func EqualsInt(s series.Series, to int) (*series.Series, error) {
-	eq := make([]int, s.Len(), s.Len())
-	ints, err := s.Int()
+	//  The models only saw the first 1000 images. Stream the rest of the set through the softmax model a batch at a
+	//  time, rather than converting all of them to floats at once
+	imagesPath, labelsPath, err := mlutil.MNISTFiles("../datasets/mnist", "train")
 	if err != nil {
-		return nil, err
+		panic(err)
 	}
-	for i := range ints {
-		if ints[i] == to {
-			eq[i] = 1
-		}
+	batches, err := mlutil.OpenMNISTBatches(imagesPath, labelsPath, 1000)
+	if err != nil {
+		panic(err)
 	}
-	ret := series.Ints(eq)
-	return &ret, nil
-}
-
-func NormalizeBytes(bs []byte) []float64 {
-	ret := make([]float64, len(bs), len(bs))
-	for i := range bs {
-		ret[i] = float64(bs[i]) / 255.
+	defer batches.Close()
+	err = batches.Skip(1000)
+	if err != nil {
+		panic(err)
 	}
-	return ret
-}
+	unseen, unseenPredictions, err := mlutil.PredictBatches(mlutil.WrapSoftmax(model2), batches)
+	if err != nil {
+		panic(err)
+	}
+	report, err = mlutil.NewClassificationReport(unseen, unseenPredictions, categories)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Remaining %d images:\n%v", len(unseen), report)
 
-func ImageSeriesToFloats(df dataframe.DataFrame, col string) [][]float64 {
-	s := df.Col(col)
-	ret := make([][]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		b := []byte(s.Elem(i).String())
-		ret[i] = NormalizeBytes(b)
+	//Calculate one-vs-rest ROC and precision-recall curves
+	curves, err := mlutil.OneVsRestCurves(validation.LabelFloats(), probs, categories)
+	if err != nil {
+		panic(err)
 	}
-	return ret
+	fmt.Print(curves)
+	fprs, tprs := curves.ROCPoints()
+
+	// Saves the curves to "Multi-class ROC.jpg". plotROCBytes also returns the JPEG, so in a notebook
+	// display.JPEG(plotROCBytes(fprs, tprs, categories)) shows them
+	plotROCBytes(fprs, tprs, categories)
 }
 
-func MaxIndex(f []float64) (i int) {
-	var (
-		curr float64
-		ix   int = -1
-	)
-	for i := range f {
-		if f[i] > curr {
-			curr = f[i]
-			ix = i
+//  isTrouser returns 1 for the labels of trousers and 0 for the others
+func isTrouser(labels []int) []float64 {
+	ret := make([]float64, len(labels), len(labels))
+	for i := range labels {
+		if labels[i] == 1 {
+			ret[i] = 1
 		}
 	}
-	return ix
+	return ret
 }
 
+// plotROCBytes plots a ROC curve per label, saves it to "Multi-class ROC.jpg" and returns the JPEG
 func plotROCBytes(fprs, tprs [][]float64, labels []string) []byte {
 	p := plot.New()
 
@@ -240,11 +186,12 @@ func plotROCBytes(fprs, tprs [][]float64, labels []string) []byte {
 	if err != nil {
 		panic(err)
 	}
-	if err := p.Save(5*vg.Inch, 4*vg.Inch, "Multi-class ROC.jpg"); err != nil {
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile("Multi-class ROC.jpg", b.Bytes(), 0644); err != nil {
 		panic(err)
 	}
-	var b bytes.Buffer
-	writer := bufio.NewWriter(&b)
-	w.WriteTo(writer)
 	return b.Bytes()
 }
@@ -1,150 +1,185 @@
 package main
 
 import (
-	"bytes"
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
+	"encoding/json"
 	"fmt"
-	"github.com/fxsjy/RF.go/RF/Regression"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
-	"gonum.org/v1/gonum/stat"
 	"io/ioutil"
-	"math/rand"
+	"os"
 )
 
 const path = "../datasets/housing/CaliforniaHousing/cal_housing.data"
 
 func main() {
-	columns := []string{"longitude", "latitude", "housingMedianAge", "totalRooms", "totalBedrooms", "population", "households", "medianIncome", "medianHouseValue"}
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, mlutil.CaliforniaHousingSchema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b), dataframe.Names(columns...))
-	df = df.Mutate(Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms"))
-	df = df.Mutate(Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms"))
-	df = df.Mutate(Divide(df.Col("population"), df.Col("households"), "averageOccupancy"))
-	df = df.Mutate(MultiplyConst(df.Col("medianHouseValue"), 0.00001))
+	averageRooms, err := mlutil.Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms")
+	if err != nil {
+		panic(err)
+	}
+	averageBedrooms, err := mlutil.Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms")
+	if err != nil {
+		panic(err)
+	}
+	averageOccupancy, err := mlutil.Divide(df.Col("population"), df.Col("households"), "averageOccupancy")
+	if err != nil {
+		panic(err)
+	}
+	df = df.Mutate(averageRooms)
+	df = df.Mutate(averageBedrooms)
+	df = df.Mutate(averageOccupancy)
+	df = df.Mutate(mlutil.MultiplyConst(df.Col("medianHouseValue"), 0.00001))
 	df = df.Select([]string{"medianIncome", "housingMedianAge", "averageRooms", "averageBedrooms", "population", "averageOccupancy", "latitude", "longitude", "medianHouseValue"})
 
-	training, validation := Split(df, 0.75)
-
-	tx, trainingY := DataFrameToXYs(training, "medianHouseValue")
-	vx, validationY := DataFrameToXYs(validation, "medianHouseValue")
+	training, validation, err := mlutil.SplitWith(df, 0.75, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
+	}
 
-	var (
-		trainingX   = make([][]interface{}, len(tx), len(tx))
-		validationX = make([][]interface{}, len(vx), len(vx))
-	)
+	// Fill any missing values with the medians of the training set
+	features := mlutil.FeatureNames(training, "medianHouseValue")
+	imputer := mlutil.NewPipeline(mlutil.NewImputer(mlutil.ImputeMedian, features...))
+	training, err = imputer.FitTransform(training)
+	if err != nil {
+		panic(err)
+	}
+	validation, err = imputer.Transform(validation)
+	if err != nil {
+		panic(err)
+	}
+	preprocessing, err := json.Marshal(imputer)
+	if err != nil {
+		panic(err)
+	}
 
-	for i := range tx {
-		trainingX[i] = FloatsToInterfaces(tx[i])
+	trainingX, trainingY, err := mlutil.DataFrameToXYs(training, "medianHouseValue")
+	if err != nil {
+		panic(err)
 	}
-	for i := range vx {
-		validationX[i] = FloatsToInterfaces(vx[i])
+	validationX, validationY, err := mlutil.DataFrameToXYs(validation, "medianHouseValue")
+	if err != nil {
+		panic(err)
 	}
 
-	model := Regression.BuildForest(trainingX, trainingY, 25, len(trainingX), 1)
+	// Grow the trees on bootstrap samples concurrently. The examples left out of each tree's sample give an
+	// estimate of the error on unseen data, the out-of-bag error, without holding any out
+	model := &mlutil.RandomForestModel{Trees: 25, Criterion: mlutil.SquaredError, MinSamplesLeaf: 2, Seed: 42}
+	if err := model.Fit(trainingX, trainingY); err != nil {
+		panic(err)
+	}
+	oobMSE, oobExamples := model.OOBScore()
+	fmt.Printf("Out-of-bag MSE: %5.2f (%d examples)\n", oobMSE, oobExamples)
 
 	//On validation set
-	errors := make([]float64, len(validationX), len(validationX))
+	predictions := make([]float64, len(validationX), len(validationX))
 	for i := range validationX {
-		prediction := model.Predicate(validationX[i])
-		if err != nil {
-			panic("Prediction error " + err.Error())
+		if predictions[i], err = model.Predict(validationX[i]); err != nil {
+			panic(err)
 		}
-		errors[i] = (prediction - validationY[i]) * (prediction - validationY[i])
 	}
+	report, err := mlutil.NewRegressionReport(validationY, predictions, len(validationX[0]))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Validation:\n%v", report)
 
-	fmt.Printf("MSE: %5.2f\n", stat.Mean(errors, nil))
+	// Which housing features the forest relies on: the impurity decrease of the splits on each feature, and the
+	// increase in validation MSE when its values are shuffled
+	importances, err := mlutil.NewImportanceReport(features, model.FeatureImportances(), nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Impurity importances:\n%v", importances)
+	importances, err = mlutil.PermutationImportance(model, validationX, validationY, features, mlutil.MeanSquaredError, true, 5, 42)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Permutation importances:\n%v", importances)
+
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/housing_forest.json", model, mlutil.ModelMetadata{
+		Features:      features,
+		Target:        "medianHouseValue",
+		Preprocessing: preprocessing,
+		Metrics:       report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
+	}
 
 	// On training set
-	errors = make([]float64, len(trainingX), len(trainingX))
+	predictions = make([]float64, len(trainingX), len(trainingX))
 	for i := range trainingX {
-		prediction := model.Predicate(trainingX[i])
-		if err != nil {
-			panic("Prediction error " + err.Error())
+		if predictions[i], err = model.Predict(trainingX[i]); err != nil {
+			panic(err)
 		}
-		errors[i] = (prediction - trainingY[i]) * (prediction - trainingY[i])
 	}
-
-	fmt.Printf("MSE: %5.2f\n", stat.Mean(errors, nil))
-
-}
-
-// Divide divides two series and returns a series with the given name. The series must have the same length.
-func Divide(s1 series.Series, s2 series.Series, name string) series.Series {
-	if s1.Len() != s2.Len() {
-		panic("Series must have the same length!")
+	report, err = mlutil.NewRegressionReport(trainingY, predictions, len(trainingX[0]))
+	if err != nil {
+		panic(err)
 	}
+	fmt.Printf("Training:\n%v", report)
 
-	ret := make([]interface{}, s1.Len(), s1.Len())
-	for i := 0; i < s1.Len(); i++ {
-		ret[i] = s1.Elem(i).Float() / s2.Elem(i).Float()
+	// 5-fold cross-validation on the whole dataset, fitting the median imputer on the training rows of every fold
+	folds, err := mlutil.KFold(df, 5, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
 	}
-	s := series.Floats(ret)
-	s.Name = name
-	return s
-}
-
-//  MultiplyConst multiplies the series by a constant and returns another series with the same name.
-func MultiplyConst(s series.Series, f float64) series.Series {
-	ret := make([]interface{}, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		ret[i] = s.Elem(i).Float() * f
-	}
-	ss := series.Floats(ret)
-	ss.Name = s.Name
-	return ss
-}
-
-func Split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:])
-	return training, validation
-}
-
-//  DataFrameToXYs converts a dataframe with float64 columns to a slice of independent variable columns as floats
-//  and the dependent variable (yCol). This can then be used with eg. goml's linear ML algorithms.
-//  yCol is optional - if it doesn't exist only the x (independent) variables will be returned.
-func DataFrameToXYs(df dataframe.DataFrame, yCol string) ([][]float64, []float64) {
-	var (
-		x      [][]float64
-		y      []float64
-		yColIx = -1
-	)
-
-	//find dependent variable column index
-	for i, col := range df.Names() {
-		if col == yCol {
-			yColIx = i
-			break
-		}
+	cv, err := mlutil.CrossValidatePipeline(df, "medianHouseValue", folds, func() *mlutil.Pipeline {
+		return mlutil.NewPipeline(mlutil.NewImputer(mlutil.ImputeMedian, features...))
+	}, mlutil.ModelTrainFunc(func() mlutil.Model {
+		return &mlutil.RandomForestModel{Trees: 25, Criterion: mlutil.SquaredError, MinSamplesLeaf: 2, Seed: 42}
+	}), map[string]mlutil.Scorer{"MSE": mlutil.MeanSquaredError, "MAE": mlutil.MeanAbsoluteError, "R2": mlutil.R2})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(cv)
+
+	// A single CART tree, pruned with cost-complexity pruning, for comparison. Unlike the forest, its splits can be
+	// read, so write them out as text and as a Graphviz graph (render it with dot -Tpng housing_tree.dot -o tree.png)
+	tree := &mlutil.TreeModel{Criterion: mlutil.SquaredError, MinSamplesLeaf: 20, CCPAlpha: 1e-3}
+	tree.FeatureNames = features
+	if err := tree.Fit(trainingX, trainingY); err != nil {
+		panic(err)
 	}
-	if yColIx == -1 {
-		fmt.Println("Warning - no dependent variable")
-	}
-	x = make([][]float64, df.Nrow(), df.Nrow())
-	y = make([]float64, df.Nrow())
-	for i := 0; i < df.Nrow(); i++ {
-		var xx []float64
-		for j := 0; j < df.Ncol(); j++ {
-			if j == yColIx {
-				y[i] = df.Elem(i, j).Float()
-				continue
-			}
-			xx = append(xx, df.Elem(i, j).Float())
+	treePredictions := make([]float64, len(validationX), len(validationX))
+	for i := range validationX {
+		if treePredictions[i], err = tree.Predict(validationX[i]); err != nil {
+			panic(err)
 		}
-		x[i] = xx
 	}
-	return x, y
-}
-
-func FloatsToInterfaces(f []float64) []interface{} {
-	iif := make([]interface{}, len(f), len(f))
-	for i := range f {
-		iif[i] = f[i]
+	report, err = mlutil.NewRegressionReport(validationY, treePredictions, len(validationX[0]))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Decision tree (%d leaves, depth %d) validation:\n%v", tree.Leaves(), tree.Depth(), report)
+	importances, err = mlutil.NewImportanceReport(tree.FeatureNames, tree.FeatureImportances(), nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(importances)
+	if err := ioutil.WriteFile("../models/housing_tree.txt", []byte(tree.Text()), 0644); err != nil {
+		panic(err)
+	}
+	f, err := os.Create("../models/housing_tree.dot")
+	if err != nil {
+		panic(err)
+	}
+	if err := tree.WriteDOT(f); err != nil {
+		panic(err)
+	}
+	if err := f.Close(); err != nil {
+		panic(err)
+	}
+	err = mlutil.SaveModel("../models/housing_tree.json", tree, mlutil.ModelMetadata{
+		Features:      tree.FeatureNames,
+		Target:        "medianHouseValue",
+		Preprocessing: preprocessing,
+		Metrics:       report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
 	}
-	return iif
 }
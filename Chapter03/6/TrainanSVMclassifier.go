@@ -1,19 +1,15 @@
 package main
 
 import (
-	"bufio"
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"bytes"
 	"fmt"
 	"github.com/datastream/libsvm"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
-	mnist "github.com/petar/GoMNIST"
-	"gonum.org/v1/gonum/integrate"
-	"gonum.org/v1/gonum/stat"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
+	"io/ioutil"
 	"math/rand"
 	"os"
 )
@@ -23,68 +19,64 @@ func main() {
 		fmt.Println("WARNING: GODEBUG Not set to cgocheck=0. This example will probably not work!")
 	}
 
-	set, err := mnist.ReadSet("../datasets/mnist/images.gz", "../datasets/mnist/labels.gz")
+	images, err := mlutil.LoadMNIST("../datasets/mnist", "train")
 	if err != nil {
 		panic(err)
 	}
-
-	//set.Images[1]
-
-	df := MNISTSetToDataframe(set, 1000)
+	images = images.Slice(0, 1000)
 
 	categories := []string{"tshirt", "trouser", "pullover", "dress", "coat", "sandal", "shirt", "shoe", "bag", "boot"}
 
-	training, validation := Split(df, 0.75)
-
-	_, err1 := EqualsInt(training.Col("Label"), 1)
-	_, err2 := EqualsInt(validation.Col("Label"), 1)
-	if err1 != nil || err2 != nil {
-		fmt.Println("Error", err1, err2)
-	}
-
-	//construct outputs
-	trainingImages := ImageSeriesToFloats(training, "Image")
-	validationImages := ImageSeriesToFloats(validation, "Image")
-
-	trainingOutputs := make([]float64, len(trainingImages))
-	validationOutputs := make([]float64, len(validationImages))
-
-	ltCol := training.Col("Label")
-	for i := range trainingImages {
-		trainingOutputs[i] = ltCol.Elem(i).Float()
-	}
-
-	lvCol := validation.Col("Label")
-	for i := range validationImages {
-		validationOutputs[i] = lvCol.Elem(i).Float()
+	training, validation, err := images.Split(0.75, mlutil.SplitConfig{Seed: 42, Stratify: "Label"})
+	if err != nil {
+		panic(err)
 	}
 
 	//construct inputs following https://github.com/cjlin1/libsvm
-	var (
-		trainingProblem   libsvm.SVMProblem
-		validationProblem libsvm.SVMProblem
-	)
-
-	trainingProblem.L = len(trainingImages)
-	validationProblem.L = len(validationImages)
-	for i := range trainingImages {
-		trainingProblem.X = append(trainingProblem.X, FloatsToSVMNode(trainingImages[i]))
+	trainingProblem := training.SVMProblem()
+	validationProblem := validation.SVMProblem()
+
+	//  Tune C and Gamma by successive halving: 27 random candidates are cross-validated on 100 training images, the
+	//  best third of them on 300 images and the best three on all of them
+	search := mlutil.Search{
+		New: func(p mlutil.Params) (mlutil.Model, error) {
+			return mlutil.NewSVMModel(libsvm.SVMParameter{
+				SvmType:    libsvm.CSVC,
+				KernelType: libsvm.RBF,
+				C:          p["C"],
+				Gamma:      p["Gamma"],
+				CacheSize:  100,
+				Eps:        0.001,
+			}), nil
+		},
+		Space: mlutil.ParamSpace{
+			"C":     mlutil.LogUniform{Min: 1, Max: 1000},
+			"Gamma": mlutil.LogUniform{Min: 1e-4, Max: 1e-1},
+		},
+		Stratify: true,
+		Seed:     42,
+	}
+	candidates, err := search.Space.Sample(rand.New(rand.NewSource(42)), 27)
+	if err != nil {
+		panic(err)
 	}
-	trainingProblem.Y = trainingOutputs
-
-	for i := range validationImages {
-		validationProblem.X = append(validationProblem.X, FloatsToSVMNode(validationImages[i]))
+	tuning, err := search.SuccessiveHalving(training.Floats(), training.LabelFloats(), candidates, mlutil.Halving{MinResource: 100})
+	if err != nil {
+		panic(err)
 	}
-	validationProblem.Y = validationOutputs
+	fmt.Print(tuning)
+	if err := tuning.SaveCSV("../models/fashion_svm_tuning.csv"); err != nil {
+		panic(err)
+	}
+	best := tuning.Best().Params
 
 	//  configure SVM
 	svm := libsvm.NewSvm()
-	//  From Python notebook
 	param := libsvm.SVMParameter{
 		SvmType:     libsvm.CSVC,
 		KernelType:  libsvm.RBF,
-		C:           100,
-		Gamma:       0.01,
+		C:           best["C"],
+		Gamma:       best["Gamma"],
 		Coef0:       0,
 		Degree:      3,
 		Eps:         0.001,
@@ -109,10 +101,14 @@ func main() {
 		}
 	}
 
+	// libsvm orders the probabilities by model.Label, so put them back in category order
+	labels := model.Label
 	for i := range validationProblem.X {
 		prediction := svm.SVMPredictProbability(model, validationProblem.X[i], p)
 		probs[i] = make([]float64, len(categories))
-		copy(probs[i], p)
+		for j := range labels {
+			probs[i][labels[j]] = p[j]
+		}
 		predictions[i] = int(prediction)
 		if prediction == validationProblem.Y[i] {
 			validCorrect++
@@ -121,121 +117,42 @@ func main() {
 	fmt.Printf("Train Accuracy: %5.2f\n", trainCorrect/float64(len(trainingProblem.X)))
 	fmt.Printf("Validation Accuracy: %5.2f\n", validCorrect/float64(len(validationProblem.X)))
 
-	//create objects for ROC generation
-	//as per https://godoc.org/github.com/gonum/stat#ROC
-	y := make([][]float64, len(categories), len(categories))
-	classes := make([][]bool, len(categories), len(categories))
-	labels := model.Label
-	for i := range y {
-		classes[i] = make([]bool, len(probs), len(probs))
-	}
-
-	for i := range probs {
-		for j := range categories {
-			y[labels[j]] = append(y[labels[j]], probs[i][j])
-			classes[labels[j]][i] = float64(labels[j]) != validationProblem.Y[i]
-		}
-
-	}
-
-	//Calculate ROC
-	tprs := make([][]float64, len(categories), len(categories))
-	fprs := make([][]float64, len(categories), len(categories))
-
-	for i := range categories {
-		stat.SortWeightedLabeled(y[i], classes[i], nil)
-		cutoffs := make([]float64, len(categories))
-		tprs[i], fprs[i], _ = stat.ROC(cutoffs, y[i], classes[i], nil)
-	}
-
-	for i := range categories {
-		fmt.Println(categories[i])
-		auc := integrate.Trapezoidal(fprs[i], tprs[i])
-		fmt.Println(auc)
-	}
-
-	//display.JPEG(plotROCBytes(fprs, tprs, categories))
-
-}
-
-func MNISTSetToDataframe(st *mnist.Set, maxExamples int) dataframe.DataFrame {
-	length := maxExamples
-	if length > len(st.Images) {
-		length = len(st.Images)
+	validationPredictions := make([]float64, len(predictions), len(predictions))
+	for i := range predictions {
+		validationPredictions[i] = float64(predictions[i])
 	}
-	s := make([]string, length, length)
-	l := make([]int, length, length)
-	for i := 0; i < length; i++ {
-		s[i] = string(st.Images[i])
-		l[i] = int(st.Labels[i])
+	report, err := mlutil.NewClassificationReport(validationProblem.Y, validationPredictions, categories)
+	if err != nil {
+		panic(err)
 	}
-	var df dataframe.DataFrame
-	images := series.Strings(s)
-	images.Name = "Image"
-	labels := series.Ints(l)
-	labels.Name = "Label"
-	df = dataframe.New(images, labels)
-	return df
-}
+	fmt.Print(report.Confusion)
+	fmt.Print(report)
 
-func EqualsInt(s series.Series, to int) (*series.Series, error) {
-	eq := make([]int, s.Len(), s.Len())
-	ints, err := s.Int()
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/fashion_svm.json", mlutil.WrapSVM(svm, model), mlutil.ModelMetadata{
+		Target:  "Label",
+		Labels:  categories,
+		Metrics: report.Metrics(),
+	})
 	if err != nil {
-		return nil, err
-	}
-	for i := range ints {
-		if ints[i] == to {
-			eq[i] = 1
-		}
+		panic(err)
 	}
-	ret := series.Ints(eq)
-	return &ret, nil
-}
-
-func Split(df dataframe.DataFrame, valFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame) {
-	perm := rand.Perm(df.Nrow())
-	cutoff := int(valFraction * float64(len(perm)))
-	training = df.Subset(perm[:cutoff])
-	validation = df.Subset(perm[cutoff:])
-	return training, validation
-}
 
-func NormalizeBytes(bs []byte) []float64 {
-	ret := make([]float64, len(bs), len(bs))
-	for i := range bs {
-		ret[i] = float64(bs[i]) / 255.
+	//Calculate one-vs-rest ROC and precision-recall curves
+	curves, err := mlutil.OneVsRestCurves(validationProblem.Y, probs, categories)
+	if err != nil {
+		panic(err)
 	}
-	return ret
-}
+	fmt.Print(curves)
+	fprs, tprs := curves.ROCPoints()
 
-func ImageSeriesToFloats(df dataframe.DataFrame, col string) [][]float64 {
-	s := df.Col(col)
-	ret := make([][]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		b := []byte(s.Elem(i).String())
-		ret[i] = NormalizeBytes(b)
-	}
-	return ret
-}
+	// Saves the curves to "SVM ROC.jpg". plotROCBytes also returns the JPEG, so in a notebook
+	// display.JPEG(plotROCBytes(fprs, tprs, categories)) shows them
+	plotROCBytes(fprs, tprs, categories)
 
-//  FloatstoSVMNode converts a slice of float64 to SVMNode with sequential indices starting at 1
-func FloatsToSVMNode(f []float64) []libsvm.SVMNode {
-	ret := make([]libsvm.SVMNode, len(f), len(f))
-	for i := range f {
-		ret[i] = libsvm.SVMNode{
-			Index: i + 1,
-			Value: f[i],
-		}
-	}
-	//End of Vector
-	ret = append(ret, libsvm.SVMNode{
-		Index: -1,
-		Value: 0,
-	})
-	return ret
 }
 
+// plotROCBytes plots a ROC curve per label, saves it to "SVM ROC.jpg" and returns the JPEG
 func plotROCBytes(fprs, tprs [][]float64, labels []string) []byte {
 	p := plot.New()
 
@@ -265,11 +182,12 @@ func plotROCBytes(fprs, tprs [][]float64, labels []string) []byte {
 	if err != nil {
 		panic(err)
 	}
-	if err := p.Save(5*vg.Inch, 4*vg.Inch, "SVM ROC.jpg"); err != nil {
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile("SVM ROC.jpg", b.Bytes(), 0644); err != nil {
 		panic(err)
 	}
-	var b bytes.Buffer
-	writer := bufio.NewWriter(&b)
-	w.WriteTo(writer)
 	return b.Bytes()
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
+	"encoding/json"
+	"fmt"
+)
+
+const path = "../datasets/housing/CaliforniaHousing/cal_housing.data"
+
+func main() {
+	housing()
+	fashion()
+}
+
+// housing predicts the median house value of the California housing dataset with gradient boosted trees.
+func housing() {
+	df, err := mlutil.LoadCSV(path, mlutil.CaliforniaHousingSchema)
+	if err != nil {
+		panic(err)
+	}
+	averageRooms, err := mlutil.Divide(df.Col("totalRooms"), df.Col("households"), "averageRooms")
+	if err != nil {
+		panic(err)
+	}
+	averageBedrooms, err := mlutil.Divide(df.Col("totalBedrooms"), df.Col("households"), "averageBedrooms")
+	if err != nil {
+		panic(err)
+	}
+	averageOccupancy, err := mlutil.Divide(df.Col("population"), df.Col("households"), "averageOccupancy")
+	if err != nil {
+		panic(err)
+	}
+	df = df.Mutate(averageRooms)
+	df = df.Mutate(averageBedrooms)
+	df = df.Mutate(averageOccupancy)
+	df = df.Mutate(mlutil.MultiplyConst(df.Col("medianHouseValue"), 0.00001))
+	df = df.Select([]string{"medianIncome", "housingMedianAge", "averageRooms", "averageBedrooms", "population", "averageOccupancy", "latitude", "longitude", "medianHouseValue"})
+
+	training, validation, err := mlutil.SplitWith(df, 0.75, mlutil.SplitConfig{Seed: 42})
+	if err != nil {
+		panic(err)
+	}
+	// Fill any missing values with the medians of the training set
+	features := mlutil.FeatureNames(training, "medianHouseValue")
+	imputer := mlutil.NewPipeline(mlutil.NewImputer(mlutil.ImputeMedian, features...))
+	training, err = imputer.FitTransform(training)
+	if err != nil {
+		panic(err)
+	}
+	validation, err = imputer.Transform(validation)
+	if err != nil {
+		panic(err)
+	}
+	preprocessing, err := json.Marshal(imputer)
+	if err != nil {
+		panic(err)
+	}
+	trainingX, trainingY, err := mlutil.DataFrameToXYs(training, "medianHouseValue")
+	if err != nil {
+		panic(err)
+	}
+	validationX, validationY, err := mlutil.DataFrameToXYs(validation, "medianHouseValue")
+	if err != nil {
+		panic(err)
+	}
+
+	// The house values are capped at 500,000, so use the Huber loss, which is less affected by the capped values
+	// than the squared error. Every round fits a tree of depth 4 to 80% of the examples; training stops when the
+	// loss on a tenth of the training examples, held out, has not improved for 20 rounds
+	model := &mlutil.GBDTModel{
+		Loss:                mlutil.HuberLoss,
+		Rounds:              1000,
+		LearningRate:        0.1,
+		MaxDepth:            4,
+		Subsample:           0.8,
+		EarlyStoppingRounds: 20,
+		Seed:                42,
+	}
+	if err := model.Fit(trainingX, trainingY); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Housing: kept %d rounds\n", model.FittedRounds())
+
+	predictions := make([]float64, len(validationX), len(validationX))
+	for i := range validationX {
+		if predictions[i], err = model.Predict(validationX[i]); err != nil {
+			panic(err)
+		}
+	}
+	report, err := mlutil.NewRegressionReport(validationY, predictions, len(validationX[0]))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Validation:\n%v", report)
+
+	// Save the model so that it can be served by the Chapter05 prediction server
+	err = mlutil.SaveModel("../models/housing_gbdt.json", model, mlutil.ModelMetadata{
+		Features:      features,
+		Target:        "medianHouseValue",
+		Preprocessing: preprocessing,
+		Metrics:       report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// fashion classifies Fashion-MNIST images with gradient boosted trees, a tree per class in every round.
+func fashion() {
+	images, err := mlutil.LoadMNIST("../datasets/mnist", "train")
+	if err != nil {
+		panic(err)
+	}
+	images = images.Slice(0, 1000)
+
+	categories := []string{"tshirt", "trouser", "pullover", "dress", "coat", "sandal", "shirt", "shoe", "bag", "boot"}
+
+	training, validation, err := images.Split(0.75, mlutil.SplitConfig{Seed: 42, Stratify: "Label"})
+	if err != nil {
+		panic(err)
+	}
+
+	// The pixels take at most 256 values, so every value gets its own bin and the histograms find the same splits
+	// as an exact search
+	model := &mlutil.GBDTModel{
+		Loss:                mlutil.SoftmaxLoss,
+		Rounds:              200,
+		LearningRate:        0.1,
+		MaxDepth:            3,
+		MinSamplesLeaf:      5,
+		Subsample:           0.8,
+		EarlyStoppingRounds: 10,
+		Seed:                42,
+	}
+	if err := model.Fit(training.Floats(), training.LabelFloats()); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Fashion-MNIST: kept %d rounds of %d trees\n", model.FittedRounds(), len(categories))
+
+	validationX := validation.Floats()
+	actual := validation.LabelFloats()
+	predicted := make([]float64, len(validationX), len(validationX))
+	for i := range validationX {
+		if predicted[i], err = model.Predict(validationX[i]); err != nil {
+			panic(err)
+		}
+	}
+	report, err := mlutil.NewClassificationReport(actual, predicted, categories)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(report.Confusion)
+	fmt.Print(report)
+
+	err = mlutil.SaveModel("../models/fashion_gbdt.json", model, mlutil.ModelMetadata{
+		Target:  "Label",
+		Labels:  categories,
+		Metrics: report.Metrics(),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
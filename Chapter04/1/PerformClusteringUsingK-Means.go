@@ -1,32 +1,32 @@
 package main
 
 import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"bufio"
 	"bytes"
 	"fmt"
 	"github.com/cdipaolo/goml/base"
 	"github.com/cdipaolo/goml/cluster"
-	"github.com/go-gota/gota/dataframe"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
-	"io/ioutil"
 	"strconv"
 )
 
 const path = "../datasets/iris/iris.csv"
 
 func main() {
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, mlutil.IrisSchema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b))
-	df.SetNames("petal length", "petal width", "sepal length", "sepal width", "species")
 
-	features, classification := DataFrameToXYs(df, "species")
+	features, classification, err := mlutil.DataFrameToXYs(df, "species")
+	if err != nil {
+		panic(err)
+	}
 
 	model := cluster.NewKMeans(3, 30, features)
 
@@ -34,6 +34,14 @@ func main() {
 		panic(err)
 	}
 
+	// Save the centroids so that the clustering can be reused without learning it again
+	err = mlutil.SaveModel("../models/iris_kmeans.json", mlutil.WrapKMeans(model), mlutil.ModelMetadata{
+		Features: mlutil.FeatureNames(df, "species"),
+	})
+	if err != nil {
+		panic(err)
+	}
+
 	scatterData, labels := PredictionsToScatterData(features, classification, model, 2, 3)
 
 	b1, _ := PlotClusterData(scatterData, labels, "Sepal length", "Sepal width")
@@ -41,42 +49,6 @@ func main() {
 	//display.JPEG(b)
 }
 
-//  DataFrameToXYs converts a dataframe with float64 columns to a slice of independent variable columns as floats
-//  and the dependent variable (yCol). This can then be used with eg. goml's linear ML algorithms.
-//  yCol is optional - if it doesn't exist only the x (independent) variables will be returned.
-func DataFrameToXYs(df dataframe.DataFrame, yCol string) ([][]float64, []float64) {
-	var (
-		x      [][]float64
-		y      []float64
-		yColIx = -1
-	)
-
-	//find dependent variable column index
-	for i, col := range df.Names() {
-		if col == yCol {
-			yColIx = i
-			break
-		}
-	}
-	if yColIx == -1 {
-		fmt.Println("Warning - no dependent variable")
-	}
-	x = make([][]float64, df.Nrow(), df.Nrow())
-	y = make([]float64, df.Nrow())
-	for i := 0; i < df.Nrow(); i++ {
-		var xx []float64
-		for j := 0; j < df.Ncol(); j++ {
-			if j == yColIx {
-				y[i] = df.Elem(i, j).Float()
-				continue
-			}
-			xx = append(xx, df.Elem(i, j).Float())
-		}
-		x[i] = xx
-	}
-	return x, y
-}
-
 //  PredictionsToScatterData gets predictions from the model based on the features and converts to map from label to XYs
 func PredictionsToScatterData(features [][]float64, labels []float64, model base.Model, featureForXAxis, featureForYAxis int) (map[int]plotter.XYs, map[int][]float64) {
 	ret := make(map[int]plotter.XYs)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
 	"bufio"
 	"bytes"
 	"fmt"
@@ -12,24 +13,21 @@ import (
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
-	"io/ioutil"
 	"strconv"
 )
 
 const path = "../datasets/iris/iris.csv"
 
 func main() {
-	b, err := ioutil.ReadFile(path)
+	df, err := mlutil.LoadCSV(path, mlutil.IrisSchema)
 	if err != nil {
-		fmt.Println("Error!", err)
+		panic(err)
 	}
-	df := dataframe.ReadCSV(bytes.NewReader(b))
-	df.SetNames("petal length", "petal width", "sepal length", "sepal width", "species")
 
-	df = Standardise(df, "petal length")
-	df = Standardise(df, "petal width")
-	df = Standardise(df, "sepal length")
-	df = Standardise(df, "sepal width")
+	df, err = mlutil.NewPipeline(mlutil.NewStandardScaler("petal length", "petal width", "sepal length", "sepal width")).FitTransform(df)
+	if err != nil {
+		panic(err)
+	}
 	labels := df.Col("species").Float()
 	df = DropColumn(df, "species")
 	features := DataFrameToMatrix(df)
@@ -65,20 +63,6 @@ func main() {
 	//display.JPEG(b)
 }
 
-//  Standardise maps the given column values by subtracting mean and rescaling by standard deviation
-func Standardise(df dataframe.DataFrame, col string) dataframe.DataFrame {
-	s := df.Col(col)
-	std := s.StdDev()
-	mean := s.Mean()
-	v := make([]float64, s.Len(), s.Len())
-	for i := 0; i < s.Len(); i++ {
-		v[i] = (s.Elem(i).Float() - mean) / std
-	}
-	rs := series.Floats(v)
-	rs.Name = col
-	return df.Mutate(rs)
-}
-
 //  DropColumn returns a new dataframe that does not include the given column
 func DropColumn(df dataframe.DataFrame, col string) dataframe.DataFrame {
 	var s []series.Series
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
+	"flag"
+	"log"
+	"net/http"
+)
+
+//  Serves a model saved by one of the Chapter03 programs, eg.
+//
+//	go run . -model ../../Chapter03/models/fashion_is_trousers.json -scale 0.00392156862745098
+//
+//  and answers the requests of the Predict client in Chapter05/1 in place of model_http.py.
+func main() {
+	modelPath := flag.String("model", "../../Chapter03/models/fashion_is_trousers.json", "model file written by mlutil.SaveModel")
+	addr := flag.String("addr", "127.0.0.1:8001", "address to listen on")
+	scale := flag.Float64("scale", 0, "multiply every input by this before prediction, eg. 1/255 for raw pixel values (0 = off)")
+	field := flag.String("field", "is_trousers", "boolean field added to classifier predictions")
+	label := flag.String("label", "trouser", "class label that sets the boolean field")
+	flag.Parse()
+
+	model, meta, err := mlutil.LoadModel(*modelPath)
+	if err != nil {
+		log.Fatalf("failed to load model: %v", err)
+	}
+	server, err := mlutil.NewPredictionServer(model, meta)
+	if err != nil {
+		log.Fatalf("failed to load preprocessing: %v", err)
+	}
+	server.Scale = *scale
+	server.FlagField = *field
+	server.FlagLabel = *label
+
+	log.Printf("Serving %s model (%s) from %s on %s", meta.Kind, meta.Library, *modelPath, *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config is the configuration of a training run. It can be read from a JSON or YAML file with -config, and any
+// field can then be overridden with a flag.
+type Config struct {
+	Data   DataConfig `json:"data" yaml:"data"`
+	Params Params     `json:"params" yaml:"params"`
+
+	// TrainFraction is the fraction of the examples used for training. The rest are used to compute the metrics.
+	TrainFraction float64 `json:"train_fraction" yaml:"train_fraction"`
+	// Seed seeds the split of the data and the models that use randomness.
+	Seed int64 `json:"seed" yaml:"seed"`
+	// Output is the path the trained model is written to, and Report the path the metrics report is written to.
+	Output string `json:"output" yaml:"output"`
+	Report string `json:"report" yaml:"report"`
+}
+
+// DataConfig says where the dataset is and how to read it.
+type DataConfig struct {
+	// Path is a CSV file, or a directory of MNIST style IDX files as written by download-fashion-mnist.sh.
+	Path string `json:"path" yaml:"path"`
+	// Format is "csv" or "mnist". If empty, it is guessed from Path.
+	Format string `json:"format" yaml:"format"`
+	// Schema names a built-in schema for CSV files: "california_housing" or "iris". If empty, the file must have a
+	// header line and the types of the columns are detected.
+	Schema string `json:"schema" yaml:"schema"`
+	// Split is the MNIST split to read, "train" or "t10k".
+	Split string `json:"split" yaml:"split"`
+	// Target is the column the model predicts. Features are the input columns; if empty, all the other columns are.
+	Target   string   `json:"target" yaml:"target"`
+	Features []string `json:"features" yaml:"features"`
+	// Labels are the names of the classes of a classifier, stored in the model metadata.
+	Labels []string `json:"labels" yaml:"labels"`
+	// MaxExamples caps the number of examples read. 0 reads them all.
+	MaxExamples int `json:"max_examples" yaml:"max_examples"`
+	// Standardise gives the features of a CSV file zero mean and unit variance before training. The fitted scaler
+	// is stored in the model metadata.
+	Standardise bool `json:"standardise" yaml:"standardise"`
+}
+
+// Params are the hyper-parameters of the models. Each model family only uses some of them.
+type Params struct {
+	// LearningRate, Iterations, Regularization and Method configure the goml models; LearningRate and Iterations
+	// (the number of epochs) also configure the multi-layer perceptron, and Iterations K-Means.
+	LearningRate   float64 `json:"learning_rate" yaml:"learning_rate"`
+	Iterations     int     `json:"iterations" yaml:"iterations"`
+	Regularization float64 `json:"regularization" yaml:"regularization"`
+	// Method is "batch" or "stochastic" gradient ascent.
+	Method string `json:"method" yaml:"method"`
+	// Classes is the number of classes of the softmax and perceptron classifiers. If 0, it is one more than the
+	// largest label.
+	Classes int `json:"classes" yaml:"classes"`
+	// Positive, if not negative, turns the labels into 1 for that class and 0 for the others, for the logistic
+	// classifier.
+	Positive int `json:"positive" yaml:"positive"`
+
+	// Trees, Samples (per tree, 0 for all) and SplitFeatures (per split) configure the random forest.
+	Trees         int `json:"trees" yaml:"trees"`
+	Samples       int `json:"samples" yaml:"samples"`
+	SplitFeatures int `json:"split_features" yaml:"split_features"`
+
+	// Kernel is "linear", "poly", "rbf" or "sigmoid"; C, Gamma and Degree are the parameters of the SVM.
+	Kernel string  `json:"kernel" yaml:"kernel"`
+	C      float64 `json:"c" yaml:"c"`
+	Gamma  float64 `json:"gamma" yaml:"gamma"`
+	Degree int     `json:"degree" yaml:"degree"`
+
+	// Layers are the sizes of the hidden layers of the perceptron, which is trained with SGD with Momentum and
+	// Decay.
+	Layers   []int   `json:"layers" yaml:"layers"`
+	Momentum float64 `json:"momentum" yaml:"momentum"`
+	Decay    float64 `json:"decay" yaml:"decay"`
+
+	// K is the number of clusters of K-Means.
+	K int `json:"k" yaml:"k"`
+	// Components is the number of principal components kept by PCA.
+	Components int `json:"components" yaml:"components"`
+}
+
+// loadConfig reads a JSON or YAML config from path into cfg, leaving the fields it does not set unchanged. Unknown
+// fields are errors, so that a misspelt hyper-parameter is not silently ignored.
+func loadConfig(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("%s: config files must be .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// bindFlags defines a flag for every field of cfg, with the current values as defaults, and returns the path given
+// with -config.
+func bindFlags(fs *flag.FlagSet, cfg *Config) *string {
+	config := fs.String("config", "", "JSON or YAML `file` to read the configuration from; flags override it")
+
+	d := &cfg.Data
+	fs.StringVar(&d.Path, "data", d.Path, "CSV file or MNIST directory to train on")
+	fs.StringVar(&d.Format, "format", d.Format, `dataset format, "csv" or "mnist" (default: guessed from -data)`)
+	fs.StringVar(&d.Schema, "schema", d.Schema, `built-in CSV schema, "california_housing" or "iris"`)
+	fs.StringVar(&d.Split, "split", d.Split, `MNIST split, "train" or "t10k"`)
+	fs.StringVar(&d.Target, "target", d.Target, "target column")
+	fs.Var((*stringList)(&d.Features), "features", "comma separated feature columns (default: all but the target)")
+	fs.Var((*stringList)(&d.Labels), "labels", "comma separated class names")
+	fs.IntVar(&d.MaxExamples, "max-examples", d.MaxExamples, "maximum number of examples to read, 0 for all")
+	fs.BoolVar(&d.Standardise, "standardise", d.Standardise, "standardise the CSV features")
+
+	fs.Float64Var(&cfg.TrainFraction, "train-fraction", cfg.TrainFraction, "fraction of the examples used for training")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed")
+	fs.StringVar(&cfg.Output, "out", cfg.Output, "`path` the model is written to")
+	fs.StringVar(&cfg.Report, "report", cfg.Report, "`path` the metrics report is written to (default: -out with .metrics.json)")
+
+	p := &cfg.Params
+	fs.Float64Var(&p.LearningRate, "learning-rate", p.LearningRate, "learning rate")
+	fs.IntVar(&p.Iterations, "iterations", p.Iterations, "iterations or epochs")
+	fs.Float64Var(&p.Regularization, "regularization", p.Regularization, "regularization")
+	fs.StringVar(&p.Method, "method", p.Method, `gradient ascent method, "batch" or "stochastic"`)
+	fs.IntVar(&p.Classes, "classes", p.Classes, "number of classes, 0 to infer from the labels")
+	fs.IntVar(&p.Positive, "positive", p.Positive, "class predicted as 1 by the logistic classifier, -1 to use the labels as they are")
+	fs.IntVar(&p.Trees, "trees", p.Trees, "number of trees")
+	fs.IntVar(&p.Samples, "samples", p.Samples, "examples drawn for each tree, 0 for all")
+	fs.IntVar(&p.SplitFeatures, "split-features", p.SplitFeatures, "features considered at each split")
+	fs.StringVar(&p.Kernel, "kernel", p.Kernel, `SVM kernel, "linear", "poly", "rbf" or "sigmoid"`)
+	fs.Float64Var(&p.C, "c", p.C, "SVM cost")
+	fs.Float64Var(&p.Gamma, "gamma", p.Gamma, "SVM kernel gamma")
+	fs.IntVar(&p.Degree, "degree", p.Degree, "SVM polynomial kernel degree")
+	fs.Var((*intList)(&p.Layers), "layers", "comma separated sizes of the hidden layers")
+	fs.Float64Var(&p.Momentum, "momentum", p.Momentum, "SGD momentum")
+	fs.Float64Var(&p.Decay, "decay", p.Decay, "SGD learning rate decay")
+	fs.IntVar(&p.K, "k", p.K, "number of clusters")
+	fs.IntVar(&p.Components, "components", p.Components, "number of principal components")
+	return config
+}
+
+// stringList is a flag.Value of comma separated strings.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// intList is a flag.Value of comma separated integers.
+type intList []int
+
+func (l *intList) String() string {
+	if l == nil {
+		return ""
+	}
+	s := make([]string, len(*l), len(*l))
+	for i, v := range *l {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*l = append(*l, i)
+	}
+	return nil
+}
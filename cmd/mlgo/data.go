@@ -0,0 +1,197 @@
+package main
+
+import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
+	"errors"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"os"
+)
+
+// schemas are the built-in schemas that can be named with -schema.
+var schemas = map[string]mlutil.Schema{
+	"california_housing": mlutil.CaliforniaHousingSchema,
+	"iris":               mlutil.IrisSchema,
+}
+
+// dataset holds the examples of a training run, split into training and validation examples.
+type dataset struct {
+	// Features are the names of the input columns, or nil for images.
+	Features []string
+	TrainX   [][]float64
+	TrainY   []float64
+	ValidX   [][]float64
+	ValidY   []float64
+
+	// TrainDF and ValidDF are the examples as dataframes, for PCA. Preprocessing is the fitted pipeline that was
+	// applied to them, if any.
+	TrainDF, ValidDF dataframe.DataFrame
+	Preprocessing    *mlutil.Pipeline
+}
+
+// loadDataset reads and splits the dataset of cfg. If stratify is set, the split keeps the proportions of the
+// classes of the target.
+func loadDataset(cfg Config, stratify bool) (*dataset, error) {
+	d := cfg.Data
+	if d.Path == "" {
+		return nil, errors.New("no dataset: use -data or data.path")
+	}
+	format := d.Format
+	if format == "" {
+		format = "csv"
+		if fi, err := os.Stat(d.Path); err == nil && fi.IsDir() {
+			format = "mnist"
+		}
+	}
+	switch format {
+	case "csv":
+		return loadCSV(cfg, stratify)
+	case "mnist":
+		return loadMNIST(cfg, stratify)
+	}
+	return nil, fmt.Errorf("unknown dataset format %q", format)
+}
+
+// loadCSV reads a CSV dataset. Rows with missing values in the selected columns are dropped.
+func loadCSV(cfg Config, stratify bool) (*dataset, error) {
+	d := cfg.Data
+	var df dataframe.DataFrame
+	if d.Schema != "" {
+		schema, ok := schemas[d.Schema]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %q", d.Schema)
+		}
+		var err error
+		if df, err = mlutil.LoadCSV(d.Path, schema); err != nil {
+			return nil, err
+		}
+	} else {
+		f, err := os.Open(d.Path)
+		if err != nil {
+			return nil, err
+		}
+		df = dataframe.ReadCSV(f)
+		f.Close()
+		if df.Err != nil {
+			return nil, fmt.Errorf("%s: %v", d.Path, df.Err)
+		}
+	}
+	if d.MaxExamples > 0 && df.Nrow() > d.MaxExamples {
+		df = df.Subset(makeRange(d.MaxExamples))
+	}
+
+	features := d.Features
+	if len(features) == 0 {
+		features = mlutil.FeatureNames(df, d.Target)
+	}
+	cols := features
+	if d.Target != "" {
+		cols = append(append([]string(nil), features...), d.Target)
+	}
+	df = df.Select(cols)
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	df, err := mlutil.DropMissing(df, cols...)
+	if err != nil {
+		return nil, err
+	}
+
+	split := mlutil.SplitConfig{Seed: cfg.Seed}
+	if stratify {
+		split.Stratify = d.Target
+	}
+	train, valid, err := mlutil.SplitWith(df, cfg.TrainFraction, split)
+	if err != nil {
+		return nil, err
+	}
+	ret := &dataset{Features: features}
+	if d.Standardise {
+		ret.Preprocessing = mlutil.NewPipeline(mlutil.NewStandardScaler(features...))
+		if train, err = ret.Preprocessing.FitTransform(train); err != nil {
+			return nil, err
+		}
+		if valid, err = ret.Preprocessing.Transform(valid); err != nil {
+			return nil, err
+		}
+	}
+	ret.TrainDF, ret.ValidDF = train, valid
+	if ret.TrainX, ret.TrainY, err = mlutil.DataFrameToXYs(train, d.Target); err != nil {
+		return nil, err
+	}
+	if ret.ValidX, ret.ValidY, err = mlutil.DataFrameToXYs(valid, d.Target); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// loadMNIST reads an MNIST style set of images, with the pixels mapped onto [0,1] as features and the labels as
+// targets.
+func loadMNIST(cfg Config, stratify bool) (*dataset, error) {
+	d := cfg.Data
+	if d.Standardise {
+		return nil, errors.New("standardise is only supported for CSV datasets")
+	}
+	split := d.Split
+	if split == "" {
+		split = "train"
+	}
+	images, err := mlutil.LoadMNIST(d.Path, split)
+	if err != nil {
+		return nil, err
+	}
+	if d.MaxExamples > 0 {
+		images = images.Slice(0, d.MaxExamples)
+	}
+	cfgSplit := mlutil.SplitConfig{Seed: cfg.Seed}
+	if stratify {
+		cfgSplit.Stratify = "Label"
+	}
+	train, valid, err := images.Split(cfg.TrainFraction, cfgSplit)
+	if err != nil {
+		return nil, err
+	}
+	return &dataset{
+		TrainX: train.Floats(),
+		TrainY: train.LabelFloats(),
+		ValidX: valid.Floats(),
+		ValidY: valid.LabelFloats(),
+	}, nil
+}
+
+// frames returns the training and validation examples as dataframes, naming the pixels of images pixel0, pixel1, ...
+func (d *dataset) frames() (train, valid dataframe.DataFrame) {
+	if d.TrainDF.Ncol() > 0 {
+		return d.TrainDF, d.ValidDF
+	}
+	if len(d.TrainX) > 0 {
+		d.Features = make([]string, len(d.TrainX[0]), len(d.TrainX[0]))
+		for j := range d.Features {
+			d.Features[j] = fmt.Sprintf("pixel%d", j)
+		}
+	}
+	return toDataFrame(d.TrainX, d.Features), toDataFrame(d.ValidX, d.Features)
+}
+
+// toDataFrame returns the rows of x as a dataframe with the given column names.
+func toDataFrame(x [][]float64, names []string) dataframe.DataFrame {
+	cols := make([]series.Series, len(names), len(names))
+	for j, name := range names {
+		v := make([]float64, len(x), len(x))
+		for i := range x {
+			v[i] = x[i][j]
+		}
+		cols[j] = series.New(v, series.Float, name)
+	}
+	return dataframe.New(cols...)
+}
+
+// makeRange returns the integers 0 to n-1.
+func makeRange(n int) []int {
+	ret := make([]int, n, n)
+	for i := range ret {
+		ret[i] = i
+	}
+	return ret
+}
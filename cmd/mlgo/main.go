@@ -0,0 +1,56 @@
+// Command mlgo trains the models of the book from the command line, with the hyper-parameters read from a JSON or
+// YAML config file or from flags instead of being hard-coded:
+//
+//	mlgo train <family> [-config file] [flags]
+//
+// The trained model is written with mlutil.SaveModel, so that it can be served by the Chapter05 prediction server,
+// and a JSON report of its metrics on the held out examples is written next to it. For example
+//
+//	mlgo train forest -data ../datasets/housing/CaliforniaHousing/cal_housing.data -schema california_housing \
+//		-target medianHouseValue -trees 50 -out ../models/housing_forest.json
+//	mlgo train mlp -data ../datasets/mnist -max-examples 1000 -layers 128,128 -iterations 500
+//
+// Run mlgo train <family> -h for the flags. The config file has the same fields, eg.
+//
+//	data:
+//	  path: ../datasets/iris/iris.csv
+//	  schema: iris
+//	  target: species
+//	params:
+//	  k: 3
+//	output: ../models/iris_kmeans.json
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+		usage()
+		return
+	}
+	if os.Args[1] != "train" || len(os.Args) < 3 {
+		usage()
+		os.Exit(2)
+	}
+	if err := train(os.Args[2], os.Args[3:]); err != nil {
+		fmt.Fprintln(os.Stderr, "mlgo:", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the subcommands.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mlgo train <family> [-config file] [flags]\n\nfamilies:")
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, families[name].Usage)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"Machine-Learning-with-Go-Quick-Start-Guide/mlutil"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/cdipaolo/goml/base"
+	"github.com/datastream/libsvm"
+	"github.com/patrikeh/go-deep"
+	"github.com/patrikeh/go-deep/training"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Task is what a model family does, which decides how the data is split and which metrics are reported.
+type Task int
+
+const (
+	Regression Task = iota
+	Classification
+	Clustering
+	Decomposition
+)
+
+// family is a kind of model that mlgo train can fit.
+type family struct {
+	Task Task
+	// Usage is the one line description shown by mlgo help.
+	Usage string
+	// Defaults are the default hyper-parameters, taken from the chapter programs.
+	Defaults Params
+	// New returns an unfitted model configured with p for the dataset d. Decomposition families have no model.
+	New func(p Params, d *dataset) (mlutil.Model, error)
+}
+
+// families are the model families, by subcommand name.
+var families = map[string]family{
+	"linear": {
+		Task:     Regression,
+		Usage:    "goml least squares regression",
+		Defaults: Params{Method: "batch", LearningRate: 1e-2, Regularization: 6, Iterations: 150},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			method, err := optimizationMethod(p.Method)
+			if err != nil {
+				return nil, err
+			}
+			return mlutil.NewLeastSquaresModel(method, p.LearningRate, p.Regularization, p.Iterations), nil
+		},
+	},
+	"logistic": {
+		Task:     Classification,
+		Usage:    "goml binary logistic classifier",
+		Defaults: Params{Method: "batch", LearningRate: 1e-4, Regularization: 1, Iterations: 150},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			method, err := optimizationMethod(p.Method)
+			if err != nil {
+				return nil, err
+			}
+			for _, y := range append(append([]float64(nil), d.TrainY...), d.ValidY...) {
+				if y != 0 && y != 1 {
+					return nil, fmt.Errorf("logistic regression needs labels 0 and 1, found %v: use -positive", y)
+				}
+			}
+			return mlutil.NewLogisticModel(method, p.LearningRate, p.Regularization, p.Iterations), nil
+		},
+	},
+	"softmax": {
+		Task:     Classification,
+		Usage:    "goml multi-class softmax classifier",
+		Defaults: Params{Method: "batch", LearningRate: 1e-4, Regularization: 1, Iterations: 100},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			method, err := optimizationMethod(p.Method)
+			if err != nil {
+				return nil, err
+			}
+			return mlutil.NewSoftmaxModel(method, p.LearningRate, p.Regularization, classes(p, d), p.Iterations), nil
+		},
+	},
+	"forest": {
+		Task:     Regression,
+		Usage:    "RF.go random forest regression",
+		Defaults: Params{Trees: 25, SplitFeatures: 1},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			return mlutil.NewForestModel(p.Trees, p.Samples, p.SplitFeatures), nil
+		},
+	},
+	"svm": {
+		Task:     Classification,
+		Usage:    "libsvm C-SVC classifier",
+		Defaults: Params{Kernel: "rbf", C: 100, Gamma: 0.01, Degree: 3},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			kernels := map[string]int{"linear": libsvm.LINEAR, "poly": libsvm.POLY, "rbf": libsvm.RBF, "sigmoid": libsvm.SIGMOID}
+			kernel, ok := kernels[p.Kernel]
+			if !ok {
+				return nil, fmt.Errorf("unknown SVM kernel %q", p.Kernel)
+			}
+			return mlutil.NewSVMModel(libsvm.SVMParameter{
+				SvmType:     libsvm.CSVC,
+				KernelType:  kernel,
+				C:           p.C,
+				Gamma:       p.Gamma,
+				Degree:      p.Degree,
+				CacheSize:   100,
+				Eps:         0.001,
+				Probability: 1,
+			}), nil
+		},
+	},
+	"mlp": {
+		Task:     Classification,
+		Usage:    "go-deep multi-layer perceptron classifier",
+		Defaults: Params{Layers: []int{128, 128}, LearningRate: 0.006, Momentum: 0.1, Decay: 1e-6, Iterations: 500},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			return mlutil.NewNeuralModel(deep.Config{
+				Layout:     append(append([]int(nil), p.Layers...), classes(p, d)),
+				Activation: deep.ActivationReLU,
+				Mode:       deep.ModeMultiClass,
+				Weight:     deep.NewNormal(0.5, 0.1),
+				Bias:       true,
+			}, training.NewSGD(p.LearningRate, p.Momentum, p.Decay, true), p.Iterations), nil
+		},
+	},
+	"kmeans": {
+		Task:     Clustering,
+		Usage:    "goml K-Means clustering",
+		Defaults: Params{K: 3, Iterations: 30},
+		New: func(p Params, d *dataset) (mlutil.Model, error) {
+			return mlutil.NewKMeansModel(p.K, p.Iterations), nil
+		},
+	},
+	"pca": {
+		Task:     Decomposition,
+		Usage:    "principal component analysis, written as a preprocessing pipeline",
+		Defaults: Params{Components: 2},
+	},
+}
+
+// Report is the metrics report written next to the model.
+type Report struct {
+	Family   string `json:"family"`
+	Model    string `json:"model"`
+	Data     string `json:"data"`
+	Training int    `json:"training_examples"`
+	// Validation is the number of held out examples the metrics were computed on.
+	Validation int                `json:"validation_examples"`
+	Params     Params             `json:"params"`
+	Metrics    map[string]float64 `json:"metrics"`
+	// Details is the full regression or classification report.
+	Details interface{} `json:"details,omitempty"`
+}
+
+// train implements mlgo train <family> [flags].
+func train(name string, args []string) error {
+	f, ok := families[name]
+	if !ok {
+		return fmt.Errorf("unknown model family %q", name)
+	}
+	cfg := Config{Params: f.Defaults, TrainFraction: 0.75, Seed: 42, Output: name + ".json"}
+	cfg.Params.Positive = -1
+
+	// flags override the config file, so find the config file first and then parse the flags again on top of it
+	scratch := cfg
+	fs := flag.NewFlagSet("mlgo train "+name, flag.ContinueOnError)
+	path := bindFlags(fs, &scratch)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *path != "" {
+		if err := loadConfig(*path, &cfg); err != nil {
+			return err
+		}
+	}
+	fs = flag.NewFlagSet("mlgo train "+name, flag.ContinueOnError)
+	bindFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Report == "" {
+		cfg.Report = strings.TrimSuffix(cfg.Output, filepath.Ext(cfg.Output)) + ".metrics.json"
+	}
+	if (f.Task == Regression || f.Task == Classification) && cfg.Data.Target == "" && cfg.Data.Format != "mnist" && !isDir(cfg.Data.Path) {
+		return errors.New("no target: use -target or data.target")
+	}
+
+	d, err := loadDataset(cfg, f.Task == Classification)
+	if err != nil {
+		return err
+	}
+	if cfg.Params.Positive >= 0 {
+		for _, y := range [][]float64{d.TrainY, d.ValidY} {
+			for i := range y {
+				if y[i] == float64(cfg.Params.Positive) {
+					y[i] = 1
+				} else {
+					y[i] = 0
+				}
+			}
+		}
+	}
+	fmt.Printf("%s: %d training and %d validation examples\n", cfg.Data.Path, len(d.TrainX), len(d.ValidX))
+
+	report := Report{Family: name, Model: cfg.Output, Data: cfg.Data.Path, Training: len(d.TrainX), Validation: len(d.ValidX), Params: cfg.Params}
+	if f.Task == Decomposition {
+		err = fitPCA(cfg, d, &report)
+	} else {
+		err = fitModel(cfg, f, d, &report)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeReport(cfg.Report, report); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s and %s\n", cfg.Output, cfg.Report)
+	return nil
+}
+
+// fitModel fits a model of family f, evaluates it on the validation examples and saves it.
+func fitModel(cfg Config, f family, d *dataset, report *Report) error {
+	m, err := f.New(cfg.Params, d)
+	if err != nil {
+		return err
+	}
+	if err := m.Fit(d.TrainX, d.TrainY); err != nil {
+		return err
+	}
+	predictions, err := mlutil.PredictAll(m, d.ValidX)
+	if err != nil {
+		return err
+	}
+
+	var labels []string
+	switch f.Task {
+	case Regression:
+		r, err := mlutil.NewRegressionReport(d.ValidY, predictions, len(d.ValidX[0]))
+		if err != nil {
+			return err
+		}
+		fmt.Print(r)
+		report.Metrics, report.Details = r.Metrics(), r
+	case Classification:
+		labels = cfg.Data.Labels
+		r, err := mlutil.NewClassificationReport(d.ValidY, predictions, labels)
+		if err != nil {
+			return err
+		}
+		fmt.Print(r.Confusion)
+		fmt.Print(r)
+		report.Metrics, report.Details = r.Metrics(), r
+	case Clustering:
+		report.Metrics = clusterMetrics(m.(*mlutil.KMeansModel), d.ValidX, predictions)
+		for _, k := range sortedKeys(report.Metrics) {
+			fmt.Printf("%s: %.4f\n", k, report.Metrics[k])
+		}
+	}
+
+	meta := mlutil.ModelMetadata{Features: d.Features, Target: cfg.Data.Target, Labels: labels, Metrics: report.Metrics}
+	if d.Preprocessing != nil {
+		if meta.Preprocessing, err = json.Marshal(d.Preprocessing); err != nil {
+			return err
+		}
+	}
+	return mlutil.SaveModel(cfg.Output, m, meta)
+}
+
+// fitPCA fits a PCA on the training examples and saves it, after any preprocessing, as a pipeline.
+func fitPCA(cfg Config, d *dataset, report *Report) error {
+	train, _ := d.frames()
+	pca := mlutil.NewPCA(cfg.Params.Components, d.Features...)
+	if err := pca.Fit(train); err != nil {
+		return err
+	}
+	pipeline := mlutil.NewPipeline(pca)
+	if d.Preprocessing != nil {
+		pipeline = mlutil.NewPipeline(append(d.Preprocessing.Steps, pca)...)
+	}
+
+	ratios := pca.ExplainedVarianceRatio()
+	report.Metrics = make(map[string]float64)
+	var kept float64
+	for i := 0; i < cfg.Params.Components && i < len(ratios); i++ {
+		report.Metrics[fmt.Sprintf("explained_variance_pc%d", i+1)] = ratios[i]
+		fmt.Printf("Component %d: %5.3f\n", i+1, ratios[i])
+		kept += ratios[i]
+	}
+	report.Metrics["explained_variance"] = kept
+
+	data, err := json.MarshalIndent(pipeline, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Output), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cfg.Output, append(data, '\n'), 0644)
+}
+
+// clusterMetrics returns the inertia (the mean squared distance of the examples to the centroid of their cluster)
+// and the fraction of the examples in each cluster.
+func clusterMetrics(m *mlutil.KMeansModel, x [][]float64, clusters []float64) map[string]float64 {
+	centroids := m.Centroids()
+	ret := make(map[string]float64)
+	var inertia float64
+	for i := range x {
+		c := int(clusters[i])
+		for j, v := range x[i] {
+			inertia += (v - centroids[c][j]) * (v - centroids[c][j])
+		}
+		ret[fmt.Sprintf("cluster%d_fraction", c)] += 1 / float64(len(x))
+	}
+	ret["inertia"] = inertia / float64(len(x))
+	return ret
+}
+
+// writeReport writes the report as indented JSON.
+func writeReport(path string, r Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// optimizationMethod returns the goml optimisation method called name.
+func optimizationMethod(name string) (base.OptimizationMethod, error) {
+	switch name {
+	case "batch":
+		return base.BatchGA, nil
+	case "stochastic":
+		return base.StochasticGA, nil
+	}
+	return "", fmt.Errorf("unknown optimization method %q", name)
+}
+
+// classes returns the number of classes configured in p, or one more than the largest training label.
+func classes(p Params, d *dataset) int {
+	if p.Classes > 0 {
+		return p.Classes
+	}
+	k := 0.
+	for _, y := range d.TrainY {
+		k = math.Max(k, y+1)
+	}
+	return int(k)
+}
+
+// isDir reports whether path is a directory.
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys(m map[string]float64) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
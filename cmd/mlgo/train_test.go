@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes data to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTrain(t *testing.T) {
+	dir := t.TempDir()
+	var csv strings.Builder
+	csv.WriteString("a,b,y\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&csv, "%d,%d,%d\n", i, i%7, 3*i+i%7)
+	}
+	data := writeFile(t, dir, "points.csv", csv.String())
+	// the config sets the trees and split features, and the flags override the trees
+	config := writeFile(t, dir, "forest.yaml", fmt.Sprintf(`
+data:
+  path: %s
+  target: y
+params:
+  trees: 9
+  split_features: 2
+train_fraction: 0.8
+`, data))
+	out := filepath.Join(dir, "forest.json")
+	if err := train("forest", []string{"-config", config, "-trees", "3", "-out", out}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "forest.metrics.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Params.Trees != 3 || report.Params.SplitFeatures != 2 {
+		t.Errorf("trained %d trees with %d split features, want 3 from the flags and 2 from the config", report.Params.Trees, report.Params.SplitFeatures)
+	}
+	if report.Training != 32 || report.Validation != 8 || report.Metrics["rmse"] == 0 {
+		t.Errorf("report of %d training and %d validation examples with metrics %v, want 32 and 8 with an RMSE",
+			report.Training, report.Validation, report.Metrics)
+	}
+
+	errorTests := []struct {
+		name, family string
+		args         []string
+	}{
+		{"unknown family", "boosting", nil},
+		{"no target", "forest", []string{"-data", data}},
+		{"no data", "forest", []string{"-target", "y"}},
+		{"unknown flag", "forest", []string{"-config", config, "-forests", "3"}},
+	}
+	for _, tt := range errorTests {
+		if err := train(tt.family, append(tt.args, "-out", out)); err == nil {
+			t.Errorf("%s: train() succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Seed: 42, Params: Params{Trees: 25}}
+	path := writeFile(t, dir, "run.json", `{"data": {"features": ["a", "b"]}, "params": {"layers": [64, 32]}}`)
+	if err := loadConfig(path, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Data.Features) != 2 || len(cfg.Params.Layers) != 2 || cfg.Seed != 42 || cfg.Params.Trees != 25 {
+		t.Errorf("config %+v, want the features and layers of the file and the other fields unchanged", cfg)
+	}
+
+	for name, data := range map[string]string{
+		"misspelt.json": `{"params": {"tress": 10}}`,
+		"misspelt.yaml": "params:\n  tress: 10\n",
+		"run.toml":      "seed = 1\n",
+	} {
+		if err := loadConfig(writeFile(t, dir, name, data), &cfg); err == nil {
+			t.Errorf("loadConfig() of %s succeeded, want an error", name)
+		}
+	}
+}
+
+func TestListFlags(t *testing.T) {
+	var s stringList
+	if err := s.Set(" a, b,,c "); err != nil || s.String() != "a,b,c" {
+		t.Errorf("stringList = %q, %v, want a,b,c", s.String(), err)
+	}
+	var l intList
+	if err := l.Set("128, 64"); err != nil || l.String() != "128,64" {
+		t.Errorf("intList = %q, %v, want 128,64", l.String(), err)
+	}
+	if err := l.Set("128,wide"); err == nil {
+		t.Error("intList.Set() of a word succeeded, want an error")
+	}
+}
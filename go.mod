@@ -13,6 +13,7 @@ require (
 	github.com/wamuir/graft v0.4.0
 	gonum.org/v1/gonum v0.13.0
 	gonum.org/v1/plot v0.13.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -24,6 +25,7 @@ require (
 	github.com/go-pdf/fpdf v0.8.0 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/exp v0.0.0-20230510235704-dd950f8aeaea // indirect
 	golang.org/x/image v0.7.0 // indirect
 	golang.org/x/net v0.6.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
@@ -0,0 +1,84 @@
+package mlutil
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// ramp is a 3x4 image whose pixels increase left to right and top to bottom.
+var ramp = []uint8{
+	10, 20, 30, 40,
+	50, 60, 70, 80,
+	90, 100, 110, 120,
+}
+
+func TestAugmentationsWithoutEffect(t *testing.T) {
+	// every augmentation leaves the image unchanged when its strength is zero
+	steps := []Augmentation{
+		Shift{Max: 0},
+		HorizontalFlip{P: 0},
+		Rotation{MaxDegrees: 0},
+		GaussianNoise{Std: 0},
+		ElasticDistortion{Alpha: 0, Sigma: 1},
+		Cutout{Size: 0},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for _, step := range steps {
+		dst := make([]uint8, len(ramp))
+		step.Apply(dst, ramp, 3, 4, rnd)
+		if !bytes.Equal(dst, ramp) {
+			t.Errorf("%T%+v turned %v into %v", step, step, ramp, dst)
+		}
+	}
+}
+
+func TestAugmentations(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	dst := make([]uint8, len(ramp))
+	HorizontalFlip{P: 1}.Apply(dst, ramp, 3, 4, rnd)
+	if want := []uint8{40, 30, 20, 10, 80, 70, 60, 50, 120, 110, 100, 90}; !bytes.Equal(dst, want) {
+		t.Errorf("flip = %v, want %v", dst, want)
+	}
+	// a cutout twice the size of the image covers it from any position
+	Cutout{Size: 8}.Apply(dst, ramp, 3, 4, rnd)
+	if !bytes.Equal(dst, make([]uint8, len(ramp))) {
+		t.Errorf("cutout = %v, want a black image", dst)
+	}
+	// noise saturates at 255 rather than wrapping around
+	bright := bytes.Repeat([]uint8{250}, 12)
+	GaussianNoise{Std: 100}.Apply(dst, bright, 3, 4, rnd)
+	if bytes.Count(dst, []byte{255}) == 0 || bytes.Count(dst, []byte{250}) == 12 {
+		t.Errorf("noise = %v, want some pixels saturated at 255", dst)
+	}
+	// a shift moves the whole image, so the pixels left are a block of the ramp
+	Shift{Max: 1}.Apply(dst, ramp, 3, 4, rnd)
+	zeros := bytes.Count(dst, []byte{0})
+	if zeros != 0 && zeros != 3 && zeros != 4 && zeros != 6 {
+		t.Errorf("shift = %v, want whole rows or columns uncovered", dst)
+	}
+}
+
+func TestAugmenter(t *testing.T) {
+	s := stripes(4)
+	steps := []Augmentation{Rotation{MaxDegrees: 30}, GaussianNoise{Std: 20}, Cutout{Size: 1}}
+	a := NewAugmenter(7, steps...).Augment(s)
+	b := NewAugmenter(7, steps...).Augment(s)
+	if !bytes.Equal(a.Pixels, b.Pixels) {
+		t.Error("augmenters with the same seed gave different images")
+	}
+	if bytes.Equal(a.Pixels, s.Pixels) {
+		t.Error("Augment() returned the original images")
+	}
+	if a.Len() != 4 || a.Labels[3] != 1 || s.At(3, 0, 0) != 3 {
+		t.Errorf("Augment() changed the labels to %v or the original pixels", a.Labels)
+	}
+	// every epoch gets different images from the same augmenter
+	aug := NewAugmenter(7, steps...)
+	if first, second := aug.Augment(s), aug.Augment(s); bytes.Equal(first.Pixels, second.Pixels) {
+		t.Error("two epochs of augmentation gave the same images")
+	}
+	if img := NewAugmenter(7).Image(ramp, 3, 4); !bytes.Equal(img, ramp) || &img[0] == &ramp[0] {
+		t.Error("Image() without steps did not return a copy of the image")
+	}
+}
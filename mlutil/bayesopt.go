@@ -0,0 +1,358 @@
+package mlutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// Bayesian configures Search.Bayesian.
+type Bayesian struct {
+	// InitialTrials are drawn at random before the surrogate model is used, 5 if 0.
+	InitialTrials int
+	// Batch is the number of trials proposed at a time and run in parallel, 1 if 0.
+	Batch int
+	// Candidates is the number of random points of the space at which the expected improvement is computed to
+	// propose a trial, 1000 if 0.
+	Candidates int
+	// Xi is the minimum improvement over the best score, in standard deviations of the scores, that the expected
+	// improvement counts. Larger values explore more. 0.01 if 0.
+	Xi float64
+	// Log is the path of the trial log, if not empty. Every trial is appended to it as a line of JSON as soon as its
+	// batch finishes, and the trials already in it are read first, so that an interrupted search resumes where it
+	// stopped instead of starting again.
+	Log string
+}
+
+// Bayesian runs n trials in total, proposing each one with a Gaussian process model of the score as a function of
+// the hyper-parameters, fitted to the previous trials, where its expected improvement over the best score is
+// largest. It needs far fewer trials than a grid search to find good hyper-parameters, which matters when every
+// trial trains a model on the full dataset. The hyper-parameters are modelled on the scale of their distribution,
+// eg. the logarithm for LogUniform.
+func (s Search) Bayesian(x [][]float64, y []float64, n int, b Bayesian) (SearchResult, error) {
+	ret := SearchResult{ScoreName: s.scoreName(), Minimise: s.Minimise}
+	if err := s.Space.Validate(); err != nil {
+		return ret, err
+	}
+	if b.InitialTrials == 0 {
+		b.InitialTrials = 5
+	}
+	if b.Batch == 0 {
+		b.Batch = 1
+	}
+	if b.Candidates == 0 {
+		b.Candidates = 1000
+	}
+	if b.Xi == 0 {
+		b.Xi = 0.01
+	}
+	if b.Log != "" {
+		trials, err := readTrialLog(b.Log)
+		if err != nil {
+			return ret, err
+		}
+		failNonFinite(trials)
+		ret.Trials = trials
+	}
+
+	for len(ret.Trials) < n {
+		// seed every batch by the number of trials before it, so that a resumed search proposes the same trials as
+		// one that was never interrupted, as long as the batches line up
+		rnd := rand.New(rand.NewSource(s.Seed + int64(len(ret.Trials))))
+		batch := b.Batch
+		if batch > n-len(ret.Trials) {
+			batch = n - len(ret.Trials)
+		}
+		var candidates []Params
+		var err error
+		if len(ret.Trials) < b.InitialTrials {
+			if batch > b.InitialTrials-len(ret.Trials) {
+				batch = b.InitialTrials - len(ret.Trials)
+			}
+			candidates, err = s.Space.Sample(rnd, batch)
+		} else {
+			candidates, err = s.propose(ret.Trials, batch, b, rnd)
+		}
+		if err != nil {
+			return ret, err
+		}
+		r, err := s.run(x, y, candidates, 0, 0)
+		if err != nil && len(r.Trials) == 0 {
+			return ret, err
+		}
+		failNonFinite(r.Trials)
+		// a batch in which every trial failed is still logged, so it is not retried when resuming
+		if b.Log != "" {
+			if err := appendTrialLog(b.Log, r.Trials); err != nil {
+				return ret, err
+			}
+		}
+		ret.Trials = append(ret.Trials, r.Trials...)
+	}
+	sort.SliceStable(ret.Trials, func(i, j int) bool { return ret.better(ret.Trials[i], ret.Trials[j]) })
+	if len(ret.Trials) > 0 && ret.Trials[0].Err != nil {
+		return ret, fmt.Errorf("mlutil: every trial failed, eg. %v", ret.Trials[0].Err)
+	}
+	return ret, nil
+}
+
+// failNonFinite fails the trials whose score is NaN or infinite, such as the error of a diverging model, which
+// would break the surrogate model.
+func failNonFinite(trials []Trial) {
+	for i := range trials {
+		if t := &trials[i]; t.Err == nil && (math.IsNaN(t.Mean) || math.IsInf(t.Mean, 0)) {
+			t.Err = fmt.Errorf("mlutil: score is %v", t.Mean)
+		}
+	}
+}
+
+// propose returns batch sets of hyper-parameters with the largest expected improvement. After each one is chosen,
+// it is added to the model with the best score so far as its outcome (the "constant liar" strategy), so that the
+// rest of the batch is spread out rather than proposing the same point again.
+func (s Search) propose(trials []Trial, batch int, b Bayesian, rnd *rand.Rand) ([]Params, error) {
+	names := s.Space.names()
+
+	// the surrogate maximises, so negate scores that are minimised; failed trials get the worst score seen
+	var xs [][]float64
+	var ys []float64
+	worst, best := math.Inf(1), math.Inf(-1)
+	for _, t := range trials {
+		if t.Err == nil {
+			v := t.Mean
+			if s.Minimise {
+				v = -v
+			}
+			worst, best = math.Min(worst, v), math.Max(best, v)
+		}
+	}
+	if math.IsInf(best, 0) {
+		// nothing to model yet
+		return s.Space.Sample(rnd, batch)
+	}
+	for _, t := range trials {
+		v := worst
+		if t.Err == nil {
+			if v = t.Mean; s.Minimise {
+				v = -v
+			}
+		}
+		xs = append(xs, s.unit(names, t.Params))
+		ys = append(ys, v)
+	}
+
+	var ret []Params
+	for len(ret) < batch {
+		gp, err := fitGP(xs, ys)
+		if err != nil {
+			return nil, err
+		}
+		candidates, err := s.Space.Sample(rnd, b.Candidates)
+		if err != nil {
+			return nil, err
+		}
+		bestEI, bestIndex := -1.0, 0
+		for i, c := range candidates {
+			if ei := gp.expectedImprovement(s.unit(names, c), b.Xi); ei > bestEI {
+				bestEI, bestIndex = ei, i
+			}
+		}
+		ret = append(ret, candidates[bestIndex])
+		xs = append(xs, s.unit(names, candidates[bestIndex]))
+		ys = append(ys, best)
+	}
+	return ret, nil
+}
+
+// unit maps the named hyper-parameters of p onto [0,1] on the scale of their distributions.
+func (s Search) unit(names []string, p Params) []float64 {
+	ret := make([]float64, len(names), len(names))
+	for i, name := range names {
+		v := p[name]
+		switch d := s.Space[name].(type) {
+		case Uniform:
+			ret[i] = safeDivide(v-d.Min, d.Max-d.Min)
+		case LogUniform:
+			ret[i] = safeDivide(math.Log(v/d.Min), math.Log(d.Max/d.Min))
+		case IntRange:
+			ret[i] = safeDivide(v-float64(d.Min), float64(d.Max-d.Min))
+		default:
+			// place the values of a list, or of any other distribution, by their rank in its grid
+			grid := append([]float64(nil), s.Space[name].Grid()...)
+			sort.Float64s(grid)
+			j := sort.SearchFloat64s(grid, v)
+			ret[i] = safeDivide(float64(j), float64(len(grid)-1))
+		}
+	}
+	return ret
+}
+
+// gaussianProcess is a Gaussian process regression with a Matérn 5/2 kernel, fitted to standardised targets.
+type gaussianProcess struct {
+	x           [][]float64
+	alpha       *mat.VecDense
+	chol        mat.Cholesky
+	lengthScale float64
+	mean, std   float64
+	best        float64
+}
+
+// gpNoise is the variance of the observation noise relative to the variance of the targets. Cross-validation
+// scores are noisy, and the noise also keeps the kernel matrix well conditioned.
+const gpNoise = 1e-3
+
+// fitGP fits a Gaussian process to the points x and targets y, choosing the length scale of the kernel that
+// maximises the marginal likelihood.
+func fitGP(x [][]float64, y []float64) (*gaussianProcess, error) {
+	mean, std := 0., 0.
+	for _, v := range y {
+		mean += v
+	}
+	mean /= float64(len(y))
+	for _, v := range y {
+		std += (v - mean) * (v - mean)
+	}
+	std = math.Sqrt(std / float64(len(y)))
+	if std == 0 {
+		std = 1
+	}
+	z := make([]float64, len(y), len(y))
+	best := math.Inf(-1)
+	for i, v := range y {
+		z[i] = (v - mean) / std
+		best = math.Max(best, z[i])
+	}
+
+	var ret *gaussianProcess
+	bestLikelihood := math.Inf(-1)
+	for _, l := range []float64{0.05, 0.1, 0.2, 0.5, 1, 2} {
+		gp := &gaussianProcess{x: x, lengthScale: l, mean: mean, std: std, best: best}
+		k := mat.NewSymDense(len(x), nil)
+		for i := range x {
+			for j := i; j < len(x); j++ {
+				v := gp.kernel(x[i], x[j])
+				if i == j {
+					v += gpNoise
+				}
+				k.SetSym(i, j, v)
+			}
+		}
+		if ok := gp.chol.Factorize(k); !ok {
+			continue
+		}
+		gp.alpha = mat.NewVecDense(len(z), nil)
+		if err := gp.chol.SolveVecTo(gp.alpha, mat.NewVecDense(len(z), z)); err != nil {
+			continue
+		}
+		// log marginal likelihood, up to a constant
+		likelihood := -0.5*mat.Dot(mat.NewVecDense(len(z), z), gp.alpha) - 0.5*gp.chol.LogDet()
+		if likelihood > bestLikelihood {
+			ret, bestLikelihood = gp, likelihood
+		}
+	}
+	if ret == nil {
+		return nil, errors.New("mlutil: cannot fit Gaussian process to the trials")
+	}
+	return ret, nil
+}
+
+// kernel returns the Matérn 5/2 covariance of a and b.
+func (gp *gaussianProcess) kernel(a, b []float64) float64 {
+	var d float64
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	r := math.Sqrt(5*d) / gp.lengthScale
+	return (1 + r + r*r/3) * math.Exp(-r)
+}
+
+// predict returns the mean and standard deviation of the standardised target at x.
+func (gp *gaussianProcess) predict(x []float64) (mu, sigma float64) {
+	k := mat.NewVecDense(len(gp.x), nil)
+	for i := range gp.x {
+		k.SetVec(i, gp.kernel(x, gp.x[i]))
+	}
+	mu = mat.Dot(k, gp.alpha)
+	var v mat.VecDense
+	if err := gp.chol.SolveVecTo(&v, k); err != nil {
+		return mu, 0
+	}
+	return mu, math.Sqrt(math.Max(gp.kernel(x, x)-mat.Dot(k, &v), 0))
+}
+
+// expectedImprovement returns the expected improvement of the standardised target at x over the best target, less
+// xi.
+func (gp *gaussianProcess) expectedImprovement(x []float64, xi float64) float64 {
+	mu, sigma := gp.predict(x)
+	if sigma == 0 {
+		return 0
+	}
+	improvement := mu - gp.best - xi
+	z := improvement / sigma
+	return improvement*distuv.UnitNormal.CDF(z) + sigma*distuv.UnitNormal.Prob(z)
+}
+
+// trialJSON is a line of a trial log. The scores of failed trials may be NaN or infinite, and are written as null.
+type trialJSON struct {
+	Params  Params    `json:"params"`
+	Mean    jsonFloat `json:"mean"`
+	StdDev  jsonFloat `json:"std_dev"`
+	Seconds float64   `json:"seconds"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// readTrialLog reads the trials of a trial log. A missing log has no trials.
+func readTrialLog(path string) ([]Trial, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ret []Trial
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var t trialJSON
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return nil, fmt.Errorf("mlutil: %s, line %d: %v", path, line, err)
+		}
+		trial := Trial{Params: t.Params, Mean: float64(t.Mean), StdDev: float64(t.StdDev), Duration: time.Duration(t.Seconds * float64(time.Second))}
+		if t.Error != "" {
+			trial.Err = errors.New(t.Error)
+		}
+		ret = append(ret, trial)
+	}
+	return ret, scanner.Err()
+}
+
+// appendTrialLog appends trials to a trial log, creating it if necessary.
+func appendTrialLog(path string, trials []Trial) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, t := range trials {
+		line := trialJSON{Params: t.Params, Mean: jsonFloat(t.Mean), StdDev: jsonFloat(t.StdDev), Seconds: t.Duration.Seconds()}
+		if t.Err != nil {
+			line.Error = t.Err.Error()
+		}
+		if err := enc.Encode(line); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
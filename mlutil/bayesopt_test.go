@@ -0,0 +1,136 @@
+package mlutil
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// squaredErrorData returns examples whose targets are all 4, so that the constant search scores c by (c-4)².
+func squaredErrorData() ([][]float64, []float64) {
+	x := make([][]float64, 10, 10)
+	y := make([]float64, 10, 10)
+	for i := range x {
+		x[i], y[i] = []float64{float64(i)}, 4
+	}
+	return x, y
+}
+
+func TestBayesian(t *testing.T) {
+	x, y := squaredErrorData()
+	s := constantSearch()
+	s.Space = ParamSpace{"c": Uniform{Min: 0, Max: 10}}
+	s.Seed = 3
+	r, err := s.Bayesian(x, y, 15, Bayesian{InitialTrials: 4, Candidates: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Trials) != 15 {
+		t.Fatalf("%d trials, want 15", len(r.Trials))
+	}
+	if c := r.Best().Params["c"]; math.Abs(c-4) > 0.5 {
+		t.Errorf("best c = %v, want it within 0.5 of 4", c)
+	}
+	for i := 1; i < len(r.Trials); i++ {
+		if r.Trials[i].Mean < r.Trials[i-1].Mean {
+			t.Fatalf("trial %d ranks after a worse trial", i)
+		}
+	}
+
+	s.Space = ParamSpace{}
+	if _, err := s.Bayesian(x, y, 5, Bayesian{}); err == nil {
+		t.Error("Bayesian() over an empty space succeeded, want an error")
+	}
+}
+
+func TestBayesianLog(t *testing.T) {
+	x, y := squaredErrorData()
+	s := constantSearch()
+	s.Space = ParamSpace{"c": LogUniform{Min: 0.1, Max: 100}}
+	b := Bayesian{InitialTrials: 3, Candidates: 100}
+	whole, err := s.Bayesian(x, y, 8, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stop after 5 trials and resume
+	b.Log = filepath.Join(t.TempDir(), "trials.jsonl")
+	if _, err := s.Bayesian(x, y, 5, b); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := s.Bayesian(x, y, 8, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(b.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 8 {
+		t.Errorf("log of %d trials, want 8", lines)
+	}
+	for i := range whole.Trials {
+		if whole.Trials[i].Params["c"] != resumed.Trials[i].Params["c"] {
+			t.Fatalf("resumed trials %v, want the trials %v of an uninterrupted search", resumed.Trials, whole.Trials)
+		}
+	}
+
+	if err := ioutil.WriteFile(b.Log, []byte("{\"params\": {\"c\": 1}}\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Bayesian(x, y, 8, b); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Bayesian() with a corrupt log returned %v, want an error for line 2", err)
+	}
+}
+
+func TestBayesianNonFiniteScores(t *testing.T) {
+	x, y := squaredErrorData()
+	s := constantSearch()
+	s.Space = ParamSpace{"c": Uniform{Min: 0, Max: 10}}
+	// the constants above 6 diverge to an infinite error, and those above 8 to NaN
+	s.Scorer = func(yTrue, yPred []float64) float64 {
+		switch {
+		case yPred[0] > 8:
+			return math.NaN()
+		case yPred[0] > 6:
+			return math.Inf(1)
+		}
+		return MeanSquaredError(yTrue, yPred)
+	}
+	b := Bayesian{InitialTrials: 6, Candidates: 100, Log: filepath.Join(t.TempDir(), "trials.jsonl")}
+	r, err := s.Bayesian(x, y, 12, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := 0
+	for _, trial := range r.Trials {
+		if diverged := trial.Params["c"] > 6; diverged != (trial.Err != nil) {
+			t.Errorf("trial %+v, want it failed if and only if c > 6", trial)
+		}
+		if trial.Err != nil {
+			failed++
+		}
+	}
+	if failed == 0 || r.Best().Err != nil || math.IsInf(r.Best().Mean, 0) {
+		t.Errorf("%d failed trials and best trial %+v, want some failures ranked after a finite best", failed, r.Best())
+	}
+
+	data, err := ioutil.ReadFile(b.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nulls := strings.Count(string(data), `"mean":null`); nulls != failed {
+		t.Errorf("log with %d null means, want %d:\n%s", nulls, failed, data)
+	}
+	resumed, err := s.Bayesian(x, y, 12, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range r.Trials {
+		if (r.Trials[i].Err != nil) != (resumed.Trials[i].Err != nil) || r.Trials[i].Params["c"] != resumed.Trials[i].Params["c"] {
+			t.Fatalf("trials read back from the log %v, want %v", resumed.Trials, r.Trials)
+		}
+	}
+}
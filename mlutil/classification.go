@@ -0,0 +1,245 @@
+package mlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ConfusionMatrix counts how often each class was predicted as each other class. Counts[i][j] is the number of
+// examples of class i that were predicted to be class j.
+type ConfusionMatrix struct {
+	Labels []string
+	Counts [][]int
+}
+
+// NewConfusionMatrix builds the confusion matrix of the class labels yTrue and the predictions yPred. The class
+// labels must be integers 0, 1, ..., len(labels)-1 and labels gives their names, eg. the Fashion-MNIST categories.
+// If labels is nil the classes are named after their numbers.
+func NewConfusionMatrix(yTrue, yPred []float64, labels []string) (ConfusionMatrix, error) {
+	var c ConfusionMatrix
+	if len(yTrue) != len(yPred) {
+		return c, fmt.Errorf("mlutil: %d true labels but %d predictions", len(yTrue), len(yPred))
+	}
+	if labels == nil {
+		k := 0
+		for _, y := range append(append([]float64{}, yTrue...), yPred...) {
+			if int(y)+1 > k {
+				k = int(y) + 1
+			}
+		}
+		labels = make([]string, k, k)
+		for i := range labels {
+			labels[i] = strconv.Itoa(i)
+		}
+	}
+	c.Labels = labels
+	c.Counts = make([][]int, len(labels), len(labels))
+	for i := range c.Counts {
+		c.Counts[i] = make([]int, len(labels), len(labels))
+	}
+	for i := range yTrue {
+		actual, err := classIndex(yTrue[i], len(labels))
+		if err != nil {
+			return c, err
+		}
+		predicted, err := classIndex(yPred[i], len(labels))
+		if err != nil {
+			return c, err
+		}
+		c.Counts[actual][predicted]++
+	}
+	return c, nil
+}
+
+func classIndex(y float64, k int) (int, error) {
+	if y < 0 || y >= float64(k) || y != math.Trunc(y) {
+		return 0, fmt.Errorf("mlutil: %v is not a class label between 0 and %d", y, k-1)
+	}
+	return int(y), nil
+}
+
+// Total returns the number of examples in the matrix.
+func (c ConfusionMatrix) Total() int {
+	var n int
+	for i := range c.Counts {
+		for j := range c.Counts[i] {
+			n += c.Counts[i][j]
+		}
+	}
+	return n
+}
+
+// String formats the matrix with the actual classes as rows and the predicted classes as columns.
+func (c ConfusionMatrix) String() string {
+	width := len("actual\\pred")
+	for _, l := range c.Labels {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%*s", width, "actual\\pred")
+	for _, l := range c.Labels {
+		fmt.Fprintf(&b, " %*s", width, l)
+	}
+	b.WriteString("\n")
+	for i, row := range c.Counts {
+		fmt.Fprintf(&b, "%*s", width, c.Labels[i])
+		for _, n := range row {
+			fmt.Fprintf(&b, " %*d", width, n)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ClassMetrics holds the precision, recall and F1 score of one class, or an average over the classes.
+type ClassMetrics struct {
+	Label     string
+	Precision float64
+	Recall    float64
+	F1        float64
+	// Support is the number of examples of the class.
+	Support int
+}
+
+// ClassificationReport summarises how well a classifier's predictions match the true class labels.
+type ClassificationReport struct {
+	Confusion ConfusionMatrix
+	Classes   []ClassMetrics
+
+	// Macro averages the per-class metrics, Micro computes them from the pooled counts of all classes (for
+	// single-label problems all three equal the accuracy) and Weighted averages them weighted by support.
+	Macro    ClassMetrics
+	Micro    ClassMetrics
+	Weighted ClassMetrics
+
+	Accuracy         float64
+	BalancedAccuracy float64
+	Kappa            float64
+	MCC              float64
+}
+
+// NewClassificationReport computes the classification metrics for the predictions yPred of the class labels yTrue.
+// See NewConfusionMatrix for the meaning of labels. Precision is 0 for classes that were never predicted, and
+// recall is 0 for classes that do not occur in yTrue.
+func NewClassificationReport(yTrue, yPred []float64, labels []string) (ClassificationReport, error) {
+	var r ClassificationReport
+	if len(yTrue) == 0 {
+		return r, errors.New("mlutil: no predictions to report on")
+	}
+	c, err := NewConfusionMatrix(yTrue, yPred, labels)
+	if err != nil {
+		return r, err
+	}
+	r.Confusion = c
+
+	k := len(c.Labels)
+	total := float64(c.Total())
+	actual := make([]float64, k, k)
+	predicted := make([]float64, k, k)
+	var correct float64
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			actual[i] += float64(c.Counts[i][j])
+			predicted[j] += float64(c.Counts[i][j])
+		}
+		correct += float64(c.Counts[i][i])
+	}
+
+	r.Classes = make([]ClassMetrics, k, k)
+	var recallSum float64
+	var present int
+	for i := 0; i < k; i++ {
+		tp := float64(c.Counts[i][i])
+		m := ClassMetrics{Label: c.Labels[i], Support: int(actual[i])}
+		m.Precision = safeDivide(tp, predicted[i])
+		m.Recall = safeDivide(tp, actual[i])
+		m.F1 = safeDivide(2*m.Precision*m.Recall, m.Precision+m.Recall)
+		r.Classes[i] = m
+
+		r.Macro.Precision += m.Precision / float64(k)
+		r.Macro.Recall += m.Recall / float64(k)
+		r.Macro.F1 += m.F1 / float64(k)
+		r.Weighted.Precision += m.Precision * actual[i] / total
+		r.Weighted.Recall += m.Recall * actual[i] / total
+		r.Weighted.F1 += m.F1 * actual[i] / total
+		if actual[i] > 0 {
+			recallSum += m.Recall
+			present++
+		}
+	}
+	r.Macro.Label, r.Micro.Label, r.Weighted.Label = "macro avg", "micro avg", "weighted avg"
+	r.Macro.Support, r.Micro.Support, r.Weighted.Support = int(total), int(total), int(total)
+
+	r.Accuracy = correct / total
+	r.Micro.Precision, r.Micro.Recall, r.Micro.F1 = r.Accuracy, r.Accuracy, r.Accuracy
+	r.BalancedAccuracy = safeDivide(recallSum, float64(present))
+
+	// Cohen's kappa and the multi-class Matthews correlation coefficient (Gorodkin, 2004) both compare the observed
+	// agreement with the agreement expected by chance from the class frequencies.
+	var chance, sumActual2, sumPredicted2 float64
+	for i := 0; i < k; i++ {
+		chance += actual[i] * predicted[i]
+		sumActual2 += actual[i] * actual[i]
+		sumPredicted2 += predicted[i] * predicted[i]
+	}
+	pe := chance / (total * total)
+	r.Kappa = safeDivide(r.Accuracy-pe, 1-pe)
+	r.MCC = safeDivide(correct*total-chance, math.Sqrt((total*total-sumPredicted2)*(total*total-sumActual2)))
+	return r, nil
+}
+
+// safeDivide returns a/b, or 0 if b is 0.
+func safeDivide(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// Metrics returns the summary metrics of the report, for use as ModelMetadata.Metrics. Undefined metrics are left
+// out.
+func (r ClassificationReport) Metrics() map[string]float64 {
+	return finiteMetrics(map[string]float64{
+		"accuracy":          r.Accuracy,
+		"balanced_accuracy": r.BalancedAccuracy,
+		"macro_precision":   r.Macro.Precision,
+		"macro_recall":      r.Macro.Recall,
+		"macro_f1":          r.Macro.F1,
+		"weighted_f1":       r.Weighted.F1,
+		"kappa":             r.Kappa,
+		"mcc":               r.MCC,
+	})
+}
+
+// String formats the report as a table of per-class and averaged metrics followed by the summary scores.
+func (r ClassificationReport) String() string {
+	width := len("weighted avg")
+	for _, m := range r.Classes {
+		if len(m.Label) > width {
+			width = len(m.Label)
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%*s %9s %9s %9s %9s\n", width, "", "precision", "recall", "f1", "support")
+	line := func(m ClassMetrics) {
+		fmt.Fprintf(&b, "%*s %9.3f %9.3f %9.3f %9d\n", width, m.Label, m.Precision, m.Recall, m.F1, m.Support)
+	}
+	for _, m := range r.Classes {
+		line(m)
+	}
+	b.WriteString("\n")
+	line(r.Micro)
+	line(r.Macro)
+	line(r.Weighted)
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Accuracy:          %5.3f\n", r.Accuracy)
+	fmt.Fprintf(&b, "Balanced accuracy: %5.3f\n", r.BalancedAccuracy)
+	fmt.Fprintf(&b, "Cohen's kappa:     %5.3f\n", r.Kappa)
+	fmt.Fprintf(&b, "MCC:               %5.3f\n", r.MCC)
+	return b.String()
+}
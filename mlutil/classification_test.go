@@ -0,0 +1,72 @@
+package mlutil
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewClassificationReport(t *testing.T) {
+	// 3 true negatives, 1 false positive, 2 false negatives and 4 true positives
+	yTrue := []float64{0, 0, 0, 0, 1, 1, 1, 1, 1, 1}
+	yPred := []float64{0, 0, 0, 1, 1, 1, 1, 1, 0, 0}
+	r, err := NewClassificationReport(yTrue, yPred, []string{"shirt", "trouser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := r.Confusion.Counts; c[0][0] != 3 || c[0][1] != 1 || c[1][0] != 2 || c[1][1] != 4 {
+		t.Errorf("confusion matrix %v, want [[3 1] [2 4]]", c)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"shirt precision", r.Classes[0].Precision, 0.6},
+		{"shirt recall", r.Classes[0].Recall, 0.75},
+		{"shirt F1", r.Classes[0].F1, 2.0 / 3},
+		{"trouser precision", r.Classes[1].Precision, 0.8},
+		{"trouser recall", r.Classes[1].Recall, 2.0 / 3},
+		{"trouser F1", r.Classes[1].F1, 8.0 / 11},
+		{"macro recall", r.Macro.Recall, 17.0 / 24},
+		{"weighted precision", r.Weighted.Precision, 0.4*0.6 + 0.6*0.8},
+		{"micro F1", r.Micro.F1, 0.7},
+		{"accuracy", r.Accuracy, 0.7},
+		{"balanced accuracy", r.BalancedAccuracy, 17.0 / 24},
+		// half the predictions would agree by chance
+		{"kappa", r.Kappa, 0.4},
+		// the binary MCC, (TP*TN - FP*FN) / sqrt((TP+FP)(TP+FN)(TN+FP)(TN+FN))
+		{"MCC", r.MCC, 10 / math.Sqrt(5*6*4*5)},
+	}
+	for _, tt := range tests {
+		if !closeTo(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if r.Classes[1].Support != 6 || r.Macro.Support != 10 {
+		t.Errorf("supports %d and %d, want 6 and 10", r.Classes[1].Support, r.Macro.Support)
+	}
+}
+
+func TestNewConfusionMatrix(t *testing.T) {
+	c, err := NewConfusionMatrix([]float64{0, 2, 2}, []float64{1, 2, 0}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Labels) != 3 || c.Labels[2] != "2" || c.Counts[2][0] != 1 || c.Counts[0][1] != 1 || c.Total() != 3 {
+		t.Errorf("matrix %+v, want 3 classes named after their numbers", c)
+	}
+
+	tests := []struct {
+		name         string
+		yTrue, yPred []float64
+	}{
+		{"label beyond the names", []float64{0, 2}, []float64{0, 1}},
+		{"fractional label", []float64{0, 1}, []float64{0.5, 1}},
+		{"negative label", []float64{-1, 1}, []float64{0, 1}},
+		{"fewer predictions", []float64{0, 1}, []float64{0}},
+	}
+	for _, tt := range tests {
+		if _, err := NewConfusionMatrix(tt.yTrue, tt.yPred, []string{"a", "b"}); err == nil {
+			t.Errorf("%s: NewConfusionMatrix() succeeded, want an error", tt.name)
+		}
+	}
+}
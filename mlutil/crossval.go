@@ -0,0 +1,298 @@
+package mlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"gonum.org/v1/gonum/stat"
+	"math/rand"
+	"sort"
+)
+
+// Predictor returns the prediction of a trained model for a single example.
+type Predictor func(x []float64) (float64, error)
+
+// TrainFunc trains a new model on x and y and returns its Predictor. It is called once per fold, so it must not
+// reuse a model between calls.
+type TrainFunc func(x [][]float64, y []float64) (Predictor, error)
+
+// FoldResult holds the scores of one cross-validation fold.
+type FoldResult struct {
+	Fold      int
+	TrainSize int
+	TestSize  int
+	Scores    map[string]float64
+}
+
+// CVResult holds the per-fold scores of a cross-validation run as well as the mean and standard deviation of each
+// score across the folds.
+type CVResult struct {
+	Folds  []FoldResult
+	Mean   map[string]float64
+	StdDev map[string]float64
+}
+
+// KFold shuffles the rows of df and assigns them to k folds of (almost) equal size, returning the row indices of
+// each fold. If cfg.Stratify is set, every class is spread evenly over the folds.
+func KFold(df dataframe.DataFrame, k int, cfg SplitConfig) ([][]int, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	if k < 2 || k > df.Nrow() {
+		return nil, fmt.Errorf("mlutil: cannot make %d folds from %d rows", k, df.Nrow())
+	}
+	strata, err := strataIndices(df, cfg.Stratify)
+	if err != nil {
+		return nil, err
+	}
+	return dealFolds(strata, k, cfg.rand()), nil
+}
+
+// KFoldXY is like KFold for data that has already been converted to features and targets, shuffling with rnd. If
+// stratify is set, every value of y is spread evenly over the folds.
+func KFoldXY(y []float64, k int, stratify bool, rnd *rand.Rand) ([][]int, error) {
+	if k < 2 || k > len(y) {
+		return nil, fmt.Errorf("mlutil: cannot make %d folds from %d rows", k, len(y))
+	}
+	all := make([]int, len(y), len(y))
+	for i := range all {
+		all[i] = i
+	}
+	strata := [][]int{all}
+	if stratify {
+		byValue := make(map[float64][]int)
+		for i, v := range y {
+			byValue[v] = append(byValue[v], i)
+		}
+		values := make([]float64, 0, len(byValue))
+		for v := range byValue {
+			values = append(values, v)
+		}
+		sort.Float64s(values)
+		strata = strata[:0]
+		for _, v := range values {
+			strata = append(strata, byValue[v])
+		}
+	}
+	return dealFolds(strata, k, rnd), nil
+}
+
+// dealFolds shuffles every stratum of row indices and assigns the rows to k folds.
+func dealFolds(strata [][]int, k int, rng *rand.Rand) [][]int {
+	folds := make([][]int, k, k)
+	next := 0
+	for _, rows := range strata {
+		rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+		// deal the rows out like cards, carrying on from where the previous stratum stopped so the folds stay balanced
+		for _, row := range rows {
+			folds[next] = append(folds[next], row)
+			next = (next + 1) % k
+		}
+	}
+	return folds
+}
+
+// LeaveOneOut returns one fold per row of df, each holding a single row index.
+func LeaveOneOut(df dataframe.DataFrame) ([][]int, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	if df.Nrow() < 2 {
+		return nil, fmt.Errorf("mlutil: cannot leave one out of %d rows", df.Nrow())
+	}
+	folds := make([][]int, df.Nrow(), df.Nrow())
+	for i := range folds {
+		folds[i] = []int{i}
+	}
+	return folds, nil
+}
+
+// CrossValidate trains a model on all folds but one and scores it on the held out fold, once for every fold.
+// yCol is the name of the target column; all other columns of df are used as features. folds is normally created
+// by KFold or LeaveOneOut.
+//
+// With leave-one-out folds every held out fold has a single prediction, so the scores are pooled over all folds
+// instead: the Mean of each score is computed from all the predictions and StdDev is 0.
+func CrossValidate(df dataframe.DataFrame, yCol string, folds [][]int, train TrainFunc, scorers map[string]Scorer) (CVResult, error) {
+	x, y, err := DataFrameToXYs(df, yCol)
+	if err != nil {
+		return CVResult{}, err
+	}
+	if y == nil {
+		return CVResult{}, errors.New("mlutil: cross-validation needs a target column")
+	}
+	return CrossValidateXY(x, y, folds, train, scorers)
+}
+
+// CrossValidateXY is like CrossValidate for data that has already been converted to features and targets, such as
+// the output of ImageSet.Floats. The row indices in folds refer to x and y.
+func CrossValidateXY(x [][]float64, y []float64, folds [][]int, train TrainFunc, scorers map[string]Scorer) (CVResult, error) {
+	if len(x) != len(y) {
+		return CVResult{}, fmt.Errorf("mlutil: %d examples but %d targets", len(x), len(y))
+	}
+	return crossValidate(len(x), folds, scorers, func(trainRows, testRows []int) ([]float64, []float64, error) {
+		trainX := make([][]float64, len(trainRows), len(trainRows))
+		trainY := make([]float64, len(trainRows), len(trainRows))
+		for i, row := range trainRows {
+			trainX[i], trainY[i] = x[row], y[row]
+		}
+		testX := make([][]float64, len(testRows), len(testRows))
+		testY := make([]float64, len(testRows), len(testRows))
+		for i, row := range testRows {
+			testX[i], testY[i] = x[row], y[row]
+		}
+		return trainAndPredict(train, trainX, trainY, testX, testY)
+	})
+}
+
+// CrossValidatePipeline is like CrossValidate, but every fold fits a new pipeline returned by newPipeline on its
+// training rows and transforms both its training and held out rows with it before they are turned into features.
+// Preprocessing such as imputation or scaling then learns nothing from the held out rows, and df may have missing
+// values for the pipeline to fill. The pipeline must keep yCol.
+func CrossValidatePipeline(df dataframe.DataFrame, yCol string, folds [][]int, newPipeline func() *Pipeline, train TrainFunc, scorers map[string]Scorer) (CVResult, error) {
+	if df.Err != nil {
+		return CVResult{}, df.Err
+	}
+	if yCol == "" {
+		return CVResult{}, errors.New("mlutil: cross-validation needs a target column")
+	}
+	return crossValidate(df.Nrow(), folds, scorers, func(trainRows, testRows []int) ([]float64, []float64, error) {
+		p := newPipeline()
+		trainDF, err := p.FitTransform(df.Subset(trainRows))
+		if err != nil {
+			return nil, nil, err
+		}
+		testDF, err := p.Transform(df.Subset(testRows))
+		if err != nil {
+			return nil, nil, err
+		}
+		trainX, trainY, err := DataFrameToXYs(trainDF, yCol)
+		if err != nil {
+			return nil, nil, err
+		}
+		testX, testY, err := DataFrameToXYs(testDF, yCol)
+		if err != nil {
+			return nil, nil, err
+		}
+		return trainAndPredict(train, trainX, trainY, testX, testY)
+	})
+}
+
+// trainAndPredict trains a model on trainX and trainY and returns testY with its predictions for testX.
+func trainAndPredict(train TrainFunc, trainX [][]float64, trainY []float64, testX [][]float64, testY []float64) ([]float64, []float64, error) {
+	predict, err := train(trainX, trainY)
+	if err != nil {
+		return nil, nil, fmt.Errorf("training: %v", err)
+	}
+	yPred := make([]float64, len(testX), len(testX))
+	for i := range testX {
+		if yPred[i], err = predict(testX[i]); err != nil {
+			return nil, nil, fmt.Errorf("predicting: %v", err)
+		}
+	}
+	return testY, yPred, nil
+}
+
+// crossValidate checks that folds hold distinct rows out of n, and scores every fold with the true and predicted
+// values returned by fold, which trains on trainRows and predicts testRows.
+func crossValidate(n int, folds [][]int, scorers map[string]Scorer, fold func(trainRows, testRows []int) (yTrue, yPred []float64, err error)) (CVResult, error) {
+	var result CVResult
+	if len(folds) < 2 {
+		return result, errors.New("mlutil: cross-validation needs at least 2 folds")
+	}
+	if len(scorers) == 0 {
+		return result, errors.New("mlutil: cross-validation needs at least one scorer")
+	}
+
+	inFold := make([]int, n, n)
+	for i := range inFold {
+		inFold[i] = -1
+	}
+	for f, rows := range folds {
+		for _, row := range rows {
+			if row < 0 || row >= n {
+				return result, fmt.Errorf("mlutil: fold %d refers to row %d, but there are only %d examples", f, row, n)
+			}
+			if inFold[row] != -1 {
+				return result, fmt.Errorf("mlutil: row %d is in folds %d and %d", row, inFold[row], f)
+			}
+			inFold[row] = f
+		}
+	}
+
+	pooled := true
+	var allTrue, allPred []float64
+	for f, rows := range folds {
+		if len(rows) != 1 {
+			pooled = false
+		}
+		var trainRows []int
+		for i := range inFold {
+			if inFold[i] != f {
+				trainRows = append(trainRows, i)
+			}
+		}
+		yTrue, yPred, err := fold(trainRows, rows)
+		if err != nil {
+			return result, fmt.Errorf("mlutil: fold %d: %v", f, err)
+		}
+		allTrue = append(allTrue, yTrue...)
+		allPred = append(allPred, yPred...)
+
+		fr := FoldResult{Fold: f, TrainSize: len(trainRows), TestSize: len(rows), Scores: make(map[string]float64)}
+		for name, score := range scorers {
+			fr.Scores[name] = score(yTrue, yPred)
+		}
+		result.Folds = append(result.Folds, fr)
+	}
+
+	result.Mean = make(map[string]float64)
+	result.StdDev = make(map[string]float64)
+	for name, score := range scorers {
+		if pooled {
+			result.Mean[name], result.StdDev[name] = score(allTrue, allPred), 0
+			continue
+		}
+		scores := make([]float64, len(result.Folds), len(result.Folds))
+		for i := range result.Folds {
+			scores[i] = result.Folds[i].Scores[name]
+		}
+		result.Mean[name], result.StdDev[name] = stat.MeanStdDev(scores, nil)
+	}
+	return result, nil
+}
+
+// String formats the result as a table with one row per fold followed by the mean and standard deviation.
+func (r CVResult) String() string {
+	names := make([]string, 0, len(r.Mean))
+	for name := range r.Mean {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%-8s %8s %8s", "Fold", "Train", "Test")
+	for _, name := range names {
+		fmt.Fprintf(&b, " %10s", name)
+	}
+	b.WriteString("\n")
+	for _, f := range r.Folds {
+		fmt.Fprintf(&b, "%-8d %8d %8d", f.Fold+1, f.TrainSize, f.TestSize)
+		for _, name := range names {
+			fmt.Fprintf(&b, " %10.4f", f.Scores[name])
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%-26s", "Mean")
+	for _, name := range names {
+		fmt.Fprintf(&b, " %10.4f", r.Mean[name])
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%-26s", "StdDev")
+	for _, name := range names {
+		fmt.Fprintf(&b, " %10.4f", r.StdDev[name])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
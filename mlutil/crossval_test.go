@@ -0,0 +1,144 @@
+package mlutil
+
+import (
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// trainMean is a TrainFunc whose model predicts the mean target of its training data.
+func trainMean(x [][]float64, y []float64) (Predictor, error) {
+	var sum float64
+	for _, v := range y {
+		sum += v
+	}
+	mean := sum / float64(len(y))
+	return func([]float64) (float64, error) { return mean, nil }, nil
+}
+
+func TestCrossValidate(t *testing.T) {
+	df := dataframe.New(
+		series.New([]float64{0, 0, 0, 0}, series.Float, "x"),
+		series.New([]float64{1, 2, 3, 7}, series.Float, "y"),
+	)
+	scorers := map[string]Scorer{"MSE": MeanSquaredError}
+
+	// the first fold is predicted as 5, the mean of 3 and 7, and the second as 1.5
+	r, err := CrossValidate(df, "y", [][]int{{0, 1}, {2, 3}}, trainMean, scorers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Folds) != 2 || r.Folds[0].Scores["MSE"] != 12.5 || r.Folds[1].Scores["MSE"] != 16.25 {
+		t.Fatalf("fold results %+v, want MSEs of 12.5 and 16.25", r.Folds)
+	}
+	if r.Folds[0].TrainSize != 2 || r.Folds[0].TestSize != 2 {
+		t.Errorf("fold 0 trained on %d rows and tested on %d, want 2 and 2", r.Folds[0].TrainSize, r.Folds[0].TestSize)
+	}
+	if r.Mean["MSE"] != 14.375 || math.Abs(r.StdDev["MSE"]-3.75/math.Sqrt2) > 1e-12 {
+		t.Errorf("MSE %v ± %v, want 14.375 ± %v", r.Mean["MSE"], r.StdDev["MSE"], 3.75/math.Sqrt2)
+	}
+
+	// leave-one-out pools the predictions 4, 11/3, 10/3 and 2
+	folds, err := LeaveOneOut(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = CrossValidate(df, "y", folds, trainMean, scorers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r.Mean["MSE"]-83.0/9) > 1e-12 || r.StdDev["MSE"] != 0 {
+		t.Errorf("pooled MSE %v ± %v, want %v ± 0", r.Mean["MSE"], r.StdDev["MSE"], 83.0/9)
+	}
+
+	invalid := [][][]int{
+		{{0, 1, 2, 3}},
+		{{0, 1}, {1, 2, 3}},
+		{{0, 1}, {2, 4}},
+	}
+	for _, folds := range invalid {
+		if _, err := CrossValidate(df, "y", folds, trainMean, scorers); err == nil {
+			t.Errorf("CrossValidate() with folds %v succeeded, want an error", folds)
+		}
+	}
+}
+
+func TestCrossValidatePipeline(t *testing.T) {
+	df := dataframe.New(
+		series.New([]float64{1, math.NaN(), 3, 5}, series.Float, "x"),
+		series.New([]float64{1, 2, 3, 8}, series.Float, "y"),
+	)
+	identity := func(x [][]float64, y []float64) (Predictor, error) {
+		return func(x []float64) (float64, error) { return x[0], nil }, nil
+	}
+	// the imputer of the first fold only sees rows 2 and 3, so it fills row 1 with 4 rather than the mean of 3 over
+	// the whole column
+	r, err := CrossValidatePipeline(df, "y", [][]int{{0, 1}, {2, 3}}, func() *Pipeline {
+		return NewPipeline(NewImputer(ImputeMean, "x"))
+	}, identity, map[string]Scorer{"MSE": MeanSquaredError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Folds) != 2 || r.Folds[0].Scores["MSE"] != 2 || r.Folds[1].Scores["MSE"] != 4.5 {
+		t.Errorf("fold results %+v, want MSEs of 2 and 4.5", r.Folds)
+	}
+	if _, err := CrossValidatePipeline(df, "", [][]int{{0, 1}, {2, 3}}, func() *Pipeline { return NewPipeline() }, identity,
+		map[string]Scorer{"MSE": MeanSquaredError}); err == nil {
+		t.Error("CrossValidatePipeline() without a target succeeded, want an error")
+	}
+}
+
+func TestKFold(t *testing.T) {
+	df := labelFrame(22)
+	folds, err := KFold(df, 3, SplitConfig{Seed: 5, Stratify: "Label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool)
+	for f, rows := range folds {
+		// 22 rows make folds of 8, 7 and 7, with the 6 positives spread 2 to a fold
+		positives := 0
+		for _, row := range rows {
+			seen[row] = true
+			if row%4 == 0 {
+				positives++
+			}
+		}
+		if len(rows) < 7 || len(rows) > 8 || positives != 2 {
+			t.Errorf("fold %d has %d rows and %d positives, want 7 or 8 rows and 2 positives", f, len(rows), positives)
+		}
+	}
+	if len(seen) != 22 {
+		t.Errorf("folds cover %d rows, want 22", len(seen))
+	}
+	if _, err := KFold(df, 23, SplitConfig{}); err == nil {
+		t.Error("KFold() with more folds than rows succeeded, want an error")
+	}
+	if _, err := KFold(df, 1, SplitConfig{}); err == nil {
+		t.Error("KFold() with a single fold succeeded, want an error")
+	}
+}
+
+func TestKFoldXY(t *testing.T) {
+	// 3 positives among 12 targets
+	y := make([]float64, 12, 12)
+	y[0], y[5], y[10] = 1, 1, 1
+	folds, err := KFoldXY(y, 3, true, rand.New(rand.NewSource(5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for f, rows := range folds {
+		positives := 0
+		for _, row := range rows {
+			positives += int(y[row])
+		}
+		if len(rows) != 4 || positives != 1 {
+			t.Errorf("fold %d has %d rows and %d positives, want 4 rows and 1 positive", f, len(rows), positives)
+		}
+	}
+	if _, err := KFoldXY(y, 13, false, rand.New(rand.NewSource(5))); err == nil {
+		t.Error("KFoldXY() with more folds than rows succeeded, want an error")
+	}
+}
@@ -0,0 +1,233 @@
+package mlutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Curve is a ROC or precision-recall curve. For ROC curves X is the false positive rate and Y the true positive
+// rate; for precision-recall curves X is the recall and Y the precision. Thresholds[i] is the lowest score that was
+// classified as positive to obtain the point (X[i], Y[i]). Area is the area under the ROC curve or the average
+// precision, respectively.
+type Curve struct {
+	Label      string
+	X          []float64
+	Y          []float64
+	Thresholds []float64
+	Area       float64
+}
+
+// rankedCounts sorts the scores in decreasing order and returns, for every distinct score, the score and the
+// cumulative number of true and false positives when everything with at least that score is called positive.
+func rankedCounts(scores []float64, positive []bool) (thresholds, tps, fps []float64) {
+	idx := make([]int, len(scores), len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	var tp, fp float64
+	for n, i := range idx {
+		if positive[i] {
+			tp++
+		} else {
+			fp++
+		}
+		// only emit a point once all examples with the same score have been counted
+		if n == len(idx)-1 || scores[idx[n+1]] != scores[i] {
+			thresholds = append(thresholds, scores[i])
+			tps = append(tps, tp)
+			fps = append(fps, fp)
+		}
+	}
+	return thresholds, tps, fps
+}
+
+// ROCCurve computes the receiver operating characteristic of scores, where higher scores mean the example is more
+// likely to be positive, by sweeping the threshold over every distinct score. The curve starts at (0,0) with an
+// infinite threshold and ends at (1,1). If there are no positive or no negative examples the rates are undefined
+// and the curve is returned with a NaN area.
+func ROCCurve(scores []float64, positive []bool) (Curve, error) {
+	var c Curve
+	if len(scores) != len(positive) {
+		return c, fmt.Errorf("mlutil: %d scores but %d labels", len(scores), len(positive))
+	}
+	thresholds, tps, fps := rankedCounts(scores, positive)
+	c.X = []float64{0}
+	c.Y = []float64{0}
+	c.Thresholds = []float64{math.Inf(1)}
+	if len(tps) == 0 || tps[len(tps)-1] == 0 || fps[len(fps)-1] == 0 {
+		c.Area = math.NaN()
+		return c, nil
+	}
+	p, n := tps[len(tps)-1], fps[len(fps)-1]
+	for i := range thresholds {
+		c.X = append(c.X, fps[i]/n)
+		c.Y = append(c.Y, tps[i]/p)
+		c.Thresholds = append(c.Thresholds, thresholds[i])
+	}
+	c.Area = trapezoid(c.X, c.Y)
+	return c, nil
+}
+
+// PRCurve computes the precision-recall curve of scores by sweeping the threshold over every distinct score. The
+// curve starts at recall 0 and precision 1. Its Area is the average precision: the mean of the precisions at each
+// threshold weighted by the increase in recall, which unlike the trapezoidal area does not interpolate optimistically
+// between points. It is NaN if there are no positive examples.
+func PRCurve(scores []float64, positive []bool) (Curve, error) {
+	var c Curve
+	if len(scores) != len(positive) {
+		return c, fmt.Errorf("mlutil: %d scores but %d labels", len(scores), len(positive))
+	}
+	thresholds, tps, fps := rankedCounts(scores, positive)
+	c.X = []float64{0}
+	c.Y = []float64{1}
+	c.Thresholds = []float64{math.Inf(1)}
+	if len(tps) == 0 || tps[len(tps)-1] == 0 {
+		c.Area = math.NaN()
+		return c, nil
+	}
+	p := tps[len(tps)-1]
+	for i := range thresholds {
+		recall := tps[i] / p
+		precision := tps[i] / (tps[i] + fps[i])
+		c.Area += (recall - c.X[len(c.X)-1]) * precision
+		c.X = append(c.X, recall)
+		c.Y = append(c.Y, precision)
+		c.Thresholds = append(c.Thresholds, thresholds[i])
+	}
+	return c, nil
+}
+
+// trapezoid integrates y over x, which must be non-decreasing.
+func trapezoid(x, y []float64) float64 {
+	var area float64
+	for i := 1; i < len(x); i++ {
+		area += (x[i] - x[i-1]) * (y[i] + y[i-1]) / 2
+	}
+	return area
+}
+
+// CurveReport holds the one-vs-rest ROC and precision-recall curves of every class of a multi-class classifier,
+// together with the micro-averaged curves computed from the pooled (example, class) pairs and the macro averages of
+// the per-class areas.
+type CurveReport struct {
+	ROC      []Curve
+	PR       []Curve
+	MicroROC Curve
+	MicroPR  Curve
+
+	MacroAUC float64
+	MicroAUC float64
+	// MacroAP and MicroAP are the macro and micro averaged average precision.
+	MacroAP float64
+	MicroAP float64
+}
+
+// OneVsRestCurves computes the ROC and precision-recall curves of each class, treating that class as positive and
+// all others as negative. yTrue holds the class labels 0, 1, ..., len(labels)-1 and proba[i][k] is the predicted
+// probability (or any score) of example i belonging to class k, as returned by Model.PredictProba. Classes with no
+// positive or no negative examples have a NaN area and are left out of the macro averages.
+func OneVsRestCurves(yTrue []float64, proba [][]float64, labels []string) (CurveReport, error) {
+	var r CurveReport
+	if len(yTrue) != len(proba) {
+		return r, fmt.Errorf("mlutil: %d true labels but %d predictions", len(yTrue), len(proba))
+	}
+	k := len(labels)
+	var microScores []float64
+	var microPositive []bool
+	scores := make([][]float64, k, k)
+	positive := make([][]bool, k, k)
+	for i := range yTrue {
+		if len(proba[i]) != k {
+			return r, fmt.Errorf("mlutil: example %d has %d probabilities, expected %d", i, len(proba[i]), k)
+		}
+		actual, err := classIndex(yTrue[i], k)
+		if err != nil {
+			return r, err
+		}
+		for j := 0; j < k; j++ {
+			scores[j] = append(scores[j], proba[i][j])
+			positive[j] = append(positive[j], actual == j)
+		}
+		microScores = append(microScores, proba[i]...)
+		for j := 0; j < k; j++ {
+			microPositive = append(microPositive, actual == j)
+		}
+	}
+
+	var aucs, aps []float64
+	for j := 0; j < k; j++ {
+		roc, err := ROCCurve(scores[j], positive[j])
+		if err != nil {
+			return r, err
+		}
+		pr, err := PRCurve(scores[j], positive[j])
+		if err != nil {
+			return r, err
+		}
+		roc.Label, pr.Label = labels[j], labels[j]
+		r.ROC = append(r.ROC, roc)
+		r.PR = append(r.PR, pr)
+		if !math.IsNaN(roc.Area) {
+			aucs = append(aucs, roc.Area)
+		}
+		if !math.IsNaN(pr.Area) {
+			aps = append(aps, pr.Area)
+		}
+	}
+	r.MacroAUC, r.MacroAP = mean(aucs), mean(aps)
+
+	var err error
+	if r.MicroROC, err = ROCCurve(microScores, microPositive); err != nil {
+		return r, err
+	}
+	if r.MicroPR, err = PRCurve(microScores, microPositive); err != nil {
+		return r, err
+	}
+	r.MicroROC.Label, r.MicroPR.Label = "micro avg", "micro avg"
+	r.MicroAUC, r.MicroAP = r.MicroROC.Area, r.MicroPR.Area
+	return r, nil
+}
+
+// mean returns the mean of f, or NaN if it is empty.
+func mean(f []float64) float64 {
+	if len(f) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range f {
+		sum += v
+	}
+	return sum / float64(len(f))
+}
+
+// ROCPoints returns the false and true positive rates of every class's ROC curve, in the form expected by the
+// plotROCBytes functions in Chapter03.
+func (r CurveReport) ROCPoints() (fprs, tprs [][]float64) {
+	for _, c := range r.ROC {
+		fprs = append(fprs, c.X)
+		tprs = append(tprs, c.Y)
+	}
+	return fprs, tprs
+}
+
+// String formats the area under the ROC curve and the average precision of each class and their averages.
+func (r CurveReport) String() string {
+	width := len("micro avg")
+	for _, c := range r.ROC {
+		if len(c.Label) > width {
+			width = len(c.Label)
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%*s %9s %9s\n", width, "", "ROC AUC", "avg prec")
+	for i := range r.ROC {
+		fmt.Fprintf(&b, "%*s %9.3f %9.3f\n", width, r.ROC[i].Label, r.ROC[i].Area, r.PR[i].Area)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%*s %9.3f %9.3f\n", width, "micro avg", r.MicroAUC, r.MicroAP)
+	fmt.Fprintf(&b, "%*s %9.3f %9.3f\n", width, "macro avg", r.MacroAUC, r.MacroAP)
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package mlutil
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCurves(t *testing.T) {
+	tests := []struct {
+		name     string
+		scores   []float64
+		positive []bool
+		auc      float64
+		ap       float64
+		// rocX and rocY are the points of the ROC curve after the initial (0, 0)
+		rocX, rocY []float64
+	}{
+		{
+			name:     "one misranked pair",
+			scores:   []float64{0.1, 0.4, 0.35, 0.8},
+			positive: []bool{false, false, true, true},
+			auc:      0.75,
+			ap:       0.5*1 + 0.5*2.0/3,
+			rocX:     []float64{0, 0.5, 0.5, 1},
+			rocY:     []float64{0.5, 0.5, 1, 1},
+		},
+		{
+			name:     "perfect",
+			scores:   []float64{0.9, 0.8, 0.2, 0.1},
+			positive: []bool{true, true, false, false},
+			auc:      1,
+			ap:       1,
+			rocX:     []float64{0, 0, 0.5, 1},
+			rocY:     []float64{0.5, 1, 1, 1},
+		},
+		{
+			name:     "reversed",
+			scores:   []float64{0.9, 0.8, 0.2, 0.1},
+			positive: []bool{false, false, true, true},
+			auc:      0,
+			ap:       0.5*1.0/3 + 0.5*0.5,
+			rocX:     []float64{0.5, 1, 1, 1},
+			rocY:     []float64{0, 0, 0.5, 1},
+		},
+		{
+			name:     "all scores tied",
+			scores:   []float64{0.5, 0.5},
+			positive: []bool{true, false},
+			auc:      0.5,
+			ap:       0.5,
+			rocX:     []float64{1},
+			rocY:     []float64{1},
+		},
+		{
+			name:     "tie across classes",
+			scores:   []float64{0.8, 0.8, 0.3},
+			positive: []bool{true, false, true},
+			auc:      0.25,
+			ap:       0.5*0.5 + 0.5*2.0/3,
+			rocX:     []float64{1, 1},
+			rocY:     []float64{0.5, 1},
+		},
+		{
+			name:     "no positives",
+			scores:   []float64{0.2, 0.7},
+			positive: []bool{false, false},
+			auc:      math.NaN(),
+			ap:       math.NaN(),
+		},
+		{
+			name:     "no negatives",
+			scores:   []float64{0.2, 0.7},
+			positive: []bool{true, true},
+			auc:      math.NaN(),
+			ap:       1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roc, err := ROCCurve(tt.scores, tt.positive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !closeTo(roc.Area, tt.auc) {
+				t.Errorf("ROC area %v, want %v", roc.Area, tt.auc)
+			}
+			wantX := append([]float64{0}, tt.rocX...)
+			wantY := append([]float64{0}, tt.rocY...)
+			if len(roc.X) != len(wantX) || len(roc.Y) != len(wantY) || len(roc.Thresholds) != len(wantX) {
+				t.Fatalf("ROC curve has %d points and %d thresholds, want %d", len(roc.X), len(roc.Thresholds), len(wantX))
+			}
+			for i := range wantX {
+				if !closeTo(roc.X[i], wantX[i]) || !closeTo(roc.Y[i], wantY[i]) {
+					t.Errorf("ROC point %d is (%v, %v), want (%v, %v)", i, roc.X[i], roc.Y[i], wantX[i], wantY[i])
+				}
+			}
+			pr, err := PRCurve(tt.scores, tt.positive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !closeTo(pr.Area, tt.ap) {
+				t.Errorf("average precision %v, want %v", pr.Area, tt.ap)
+			}
+		})
+	}
+	if _, err := ROCCurve([]float64{0.1}, []bool{true, false}); err == nil {
+		t.Error("ROCCurve() with more labels than scores succeeded, want an error")
+	}
+	if _, err := PRCurve([]float64{0.1, 0.2}, []bool{true}); err == nil {
+		t.Error("PRCurve() with more scores than labels succeeded, want an error")
+	}
+}
+
+func TestOneVsRestCurves(t *testing.T) {
+	// Classes a and b both rank one positive below one negative. Class c has no examples, so its areas are NaN and
+	// left out of the macro averages. The 12 pooled (example, class) pairs give the micro averages.
+	yTrue := []float64{0, 1, 0, 1}
+	proba := [][]float64{{0.7, 0.2, 0.1}, {0.3, 0.6, 0.1}, {0.4, 0.5, 0.1}, {0.6, 0.3, 0.1}}
+	r, err := OneVsRestCurves(yTrue, proba, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"a AUC", r.ROC[0].Area, 0.75},
+		{"a AP", r.PR[0].Area, 0.5 + 0.5*2.0/3},
+		{"b AUC", r.ROC[1].Area, 0.75},
+		{"b AP", r.PR[1].Area, 0.5 + 0.5*2.0/3},
+		{"c AUC", r.ROC[2].Area, math.NaN()},
+		{"c AP", r.PR[2].Area, math.NaN()},
+		{"macro AUC", r.MacroAUC, 0.75},
+		{"macro AP", r.MacroAP, 0.5 + 0.5*2.0/3},
+		{"micro AUC", r.MicroAUC, 0.84375},
+		{"micro AP", r.MicroAP, 0.25 + 0.25*2.0/3 + 0.25*3.0/5 + 0.25*4.0/7},
+	}
+	for _, tt := range tests {
+		if !closeTo(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if r.ROC[1].Label != "b" || r.MicroROC.Label != "micro avg" {
+		t.Errorf("curves labelled %q and %q, want %q and %q", r.ROC[1].Label, r.MicroROC.Label, "b", "micro avg")
+	}
+
+	errorTests := []struct {
+		name  string
+		yTrue []float64
+		proba [][]float64
+	}{
+		{"fewer predictions", []float64{0, 1}, [][]float64{{1, 0, 0}}},
+		{"missing class", []float64{0}, [][]float64{{1, 0}}},
+		{"unknown class", []float64{3}, [][]float64{{1, 0, 0}}},
+	}
+	for _, tt := range errorTests {
+		if _, err := OneVsRestCurves(tt.yTrue, tt.proba, []string{"a", "b", "c"}); err == nil {
+			t.Errorf("%s: OneVsRestCurves() succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package mlutil contains the data handling helpers shared by the chapter programs: converting gota dataframes
+// into the slices expected by goml, go-deep, libsvm and friends, splitting data into training and validation sets
+// and reading the Fashion-MNIST images.
+package mlutil
+
+import (
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+)
+
+// Divide divides two series and returns a series with the given name. The series must have the same length.
+// Division by zero gives NaN rather than an infinity, so that the result is treated as a missing value by
+// MissingValues and the imputers.
+func Divide(s1 series.Series, s2 series.Series, name string) (series.Series, error) {
+	if s1.Len() != s2.Len() {
+		return series.Series{}, fmt.Errorf("mlutil: cannot divide series of length %d by series of length %d", s1.Len(), s2.Len())
+	}
+
+	ret := make([]float64, s1.Len(), s1.Len())
+	for i := 0; i < s1.Len(); i++ {
+		d := s2.Elem(i).Float()
+		if d == 0 {
+			ret[i] = math.NaN()
+			continue
+		}
+		ret[i] = s1.Elem(i).Float() / d
+	}
+	s := series.Floats(ret)
+	s.Name = name
+	return s, nil
+}
+
+// MultiplyConst multiplies the series by a constant and returns another series with the same name.
+func MultiplyConst(s series.Series, f float64) series.Series {
+	ret := make([]float64, s.Len(), s.Len())
+	for i := 0; i < s.Len(); i++ {
+		ret[i] = s.Elem(i).Float() * f
+	}
+	ss := series.Floats(ret)
+	ss.Name = s.Name
+	return ss
+}
+
+// DataFrameToXYs converts a dataframe with float64 columns to a slice of independent variable columns as floats
+// and the dependent variable (yCol). This can then be used with eg. goml's linear ML algorithms.
+// yCol is optional - if it is empty only the x (independent) variables are returned and y is nil. A non-empty yCol
+// that is not in the dataframe is an error, and so are missing values (NaN or infinite after conversion to float),
+// which should be dropped or imputed first.
+func DataFrameToXYs(df dataframe.DataFrame, yCol string) ([][]float64, []float64, error) {
+	if df.Err != nil {
+		return nil, nil, df.Err
+	}
+	var (
+		x      [][]float64
+		y      []float64
+		yColIx = -1
+	)
+
+	//find dependent variable column index
+	if yCol != "" {
+		for i, col := range df.Names() {
+			if col == yCol {
+				yColIx = i
+				break
+			}
+		}
+		if yColIx == -1 {
+			return nil, nil, fmt.Errorf("mlutil: dependent variable %q not found in dataframe", yCol)
+		}
+		y = make([]float64, df.Nrow())
+	}
+	x = make([][]float64, df.Nrow(), df.Nrow())
+	for i := 0; i < df.Nrow(); i++ {
+		xx := make([]float64, 0, df.Ncol())
+		for j := 0; j < df.Ncol(); j++ {
+			v := df.Elem(i, j).Float()
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, nil, fmt.Errorf("mlutil: missing or non-numeric value in column %q, row %d", df.Names()[j], i)
+			}
+			if j == yColIx {
+				y[i] = v
+				continue
+			}
+			xx = append(xx, v)
+		}
+		x[i] = xx
+	}
+	return x, y, nil
+}
+
+// FeatureNames returns the names of the columns DataFrameToXYs turns into x, in the same order.
+func FeatureNames(df dataframe.DataFrame, yCol string) []string {
+	var ret []string
+	for _, col := range df.Names() {
+		if col != yCol {
+			ret = append(ret, col)
+		}
+	}
+	return ret
+}
+
+// EqualsInt returns a series of ints which are 1 where the corresponding element of s equals to and 0 otherwise.
+// It can be used to turn a multi-class label column into a binary one.
+func EqualsInt(s series.Series, to int) (*series.Series, error) {
+	eq := make([]int, s.Len(), s.Len())
+	ints, err := s.Int()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ints {
+		if ints[i] == to {
+			eq[i] = 1
+		}
+	}
+	ret := series.Ints(eq)
+	return &ret, nil
+}
+
+// MaxIndex returns the index of the largest value in f, or -1 if f is empty. It is used to turn the output of a
+// multi-class model into a class label.
+func MaxIndex(f []float64) int {
+	if len(f) == 0 {
+		return -1
+	}
+	ix := 0
+	for i := range f {
+		if f[i] > f[ix] {
+			ix = i
+		}
+	}
+	return ix
+}
@@ -0,0 +1,71 @@
+package mlutil
+
+import (
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"testing"
+)
+
+func TestDataFrameToXYs(t *testing.T) {
+	df := dataframe.New(
+		series.New([]float64{1, 2, 3}, series.Float, "a"),
+		series.New([]float64{10, 20, 30}, series.Float, "y"),
+		series.New([]int{4, 5, 6}, series.Int, "b"),
+	)
+	x, y, err := DataFrameToXYs(df, "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantX := [][]float64{{1, 4}, {2, 5}, {3, 6}}
+	wantY := []float64{10, 20, 30}
+	for i := range wantX {
+		if len(x[i]) != 2 || x[i][0] != wantX[i][0] || x[i][1] != wantX[i][1] || y[i] != wantY[i] {
+			t.Fatalf("got x %v and y %v, want %v and %v", x, y, wantX, wantY)
+		}
+	}
+
+	// without a dependent variable every column is a feature
+	x, y, err = DataFrameToXYs(df, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y != nil || len(x[0]) != 3 || x[0][1] != 10 {
+		t.Errorf("got x %v and y %v, want 3 features per row and no y", x, y)
+	}
+
+	if _, _, err := DataFrameToXYs(df, "z"); err == nil {
+		t.Error("DataFrameToXYs() with a missing dependent variable succeeded, want an error")
+	}
+}
+
+func TestDivide(t *testing.T) {
+	s, err := Divide(series.Floats([]float64{1, 9}), series.Floats([]float64{2, 3}), "ratio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "ratio" || s.Elem(0).Float() != 0.5 || s.Elem(1).Float() != 3 {
+		t.Errorf("Divide() = %v named %q, want [0.5 3] named ratio", s.Float(), s.Name)
+	}
+	if _, err := Divide(series.Floats([]float64{1}), series.Floats([]float64{1, 2}), "ratio"); err == nil {
+		t.Error("Divide() of series of different lengths succeeded, want an error")
+	}
+}
+
+func TestMaxIndex(t *testing.T) {
+	if i := MaxIndex([]float64{0.1, 0.7, 0.7, 0.2}); i != 1 {
+		t.Errorf("MaxIndex() = %d, want the first of the ties, 1", i)
+	}
+	if i := MaxIndex(nil); i != -1 {
+		t.Errorf("MaxIndex(nil) = %d, want -1", i)
+	}
+}
+
+func TestEqualsInt(t *testing.T) {
+	s, err := EqualsInt(series.Ints([]int{1, 3, 1, 0}), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Records(); len(got) != 4 || got[0] != "1" || got[1] != "0" || got[2] != "1" || got[3] != "0" {
+		t.Errorf("EqualsInt() = %v, want [1 0 1 0]", got)
+	}
+}
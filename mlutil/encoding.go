@@ -0,0 +1,589 @@
+package mlutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"sort"
+)
+
+// UnknownPolicy selects what an encoder does with categories it did not see during Fit.
+type UnknownPolicy string
+
+const (
+	// UnknownError makes Transform fail on unseen categories.
+	UnknownError UnknownPolicy = "error"
+	// UnknownIgnore encodes unseen categories as all zeros.
+	UnknownIgnore UnknownPolicy = "ignore"
+	// UnknownBucket encodes unseen categories in an extra "<column>_unknown" column.
+	UnknownBucket UnknownPolicy = "bucket"
+)
+
+// check returns an error if p is not one of the policies. The empty policy is UnknownError.
+func (p UnknownPolicy) check() error {
+	switch p {
+	case "", UnknownError, UnknownIgnore, UnknownBucket:
+		return nil
+	}
+	return fmt.Errorf("mlutil: unknown category policy %q", p)
+}
+
+// OneHotEncoder replaces categorical columns by one 0/1 column per category, named "<column>_<category>". The
+// categories are sorted, so the columns come out in the same order on every run.
+type OneHotEncoder struct {
+	// Columns are the columns to encode. If empty, Fit encodes every string column.
+	Columns []string `json:"columns,omitempty"`
+	// DropFirst leaves out the column of the first category, which is then encoded as all zeros, to avoid the
+	// collinearity of a full set of indicators in linear models.
+	DropFirst bool `json:"drop_first"`
+	// Unknown is the policy for categories not seen during Fit. The zero value is UnknownError.
+	Unknown UnknownPolicy `json:"unknown,omitempty"`
+	// MinFrequency groups the categories seen fewer than MinFrequency times during Fit into a single
+	// "<column>_other" column.
+	MinFrequency int `json:"min_frequency,omitempty"`
+
+	// Categories are the sorted categories of each column that get their own indicator, and Infrequent the ones
+	// grouped into "other".
+	Categories map[string][]string `json:"categories"`
+	Infrequent map[string][]string `json:"infrequent,omitempty"`
+}
+
+// NewOneHotEncoder returns an encoder for the given columns that fails on unseen categories.
+func NewOneHotEncoder(cols ...string) *OneHotEncoder {
+	return &OneHotEncoder{Columns: cols, Unknown: UnknownError}
+}
+
+// Fit learns the categories of each column from df.
+func (e *OneHotEncoder) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	if err := e.Unknown.check(); err != nil {
+		return err
+	}
+	cols := e.Columns
+	if len(cols) == 0 {
+		cols = stringColumns(df)
+	}
+	categories := make(map[string][]string, len(cols))
+	infrequent := make(map[string][]string)
+	for _, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return c.Err
+		}
+		counts := make(map[string]int)
+		for _, val := range c.Records() {
+			counts[val]++
+		}
+		frequent := []string{}
+		for val, n := range counts {
+			if n < e.MinFrequency {
+				infrequent[col] = append(infrequent[col], val)
+			} else {
+				frequent = append(frequent, val)
+			}
+		}
+		sort.Strings(frequent)
+		sort.Strings(infrequent[col])
+		categories[col] = frequent
+	}
+	e.Categories, e.Infrequent = categories, infrequent
+	return nil
+}
+
+// Transform replaces each fitted column of df by its indicator columns, which are appended after the remaining
+// columns.
+func (e *OneHotEncoder) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if e.Categories == nil {
+		return df, ErrNotFitted
+	}
+	if df.Err != nil {
+		return df, df.Err
+	}
+	for _, col := range e.fittedColumns() {
+		c := df.Col(col)
+		if c.Err != nil {
+			return df, c.Err
+		}
+		names := e.OutputColumns(col)
+		indicators := make([][]int, len(names), len(names))
+		for i := range indicators {
+			indicators[i] = make([]int, c.Len(), c.Len())
+		}
+		index := make(map[string]int, len(names))
+		for i, val := range e.Categories[col] {
+			index[val] = i
+		}
+		other, unknown := -1, -1
+		if len(e.Infrequent[col]) > 0 {
+			other = len(e.Categories[col])
+			for _, val := range e.Infrequent[col] {
+				index[val] = other
+			}
+		}
+		if e.Unknown == UnknownBucket {
+			unknown = len(e.Categories[col])
+			if other >= 0 {
+				unknown++
+			}
+		}
+		first := 0
+		if e.DropFirst && len(e.Categories[col]) > 0 {
+			first = 1
+		}
+
+		for j, val := range c.Records() {
+			i, ok := index[val]
+			if !ok {
+				switch e.Unknown {
+				case UnknownIgnore:
+					continue
+				case UnknownBucket:
+					i = unknown
+				default:
+					return df, fmt.Errorf("mlutil: unseen category %q in column %q, row %d", val, col, j)
+				}
+			}
+			if i-first >= 0 {
+				indicators[i-first][j] = 1
+			}
+		}
+
+		df = df.Drop(col)
+		for i := range names {
+			df = df.Mutate(series.New(indicators[i], series.Int, names[i]))
+		}
+	}
+	return df, df.Err
+}
+
+// OutputColumns returns the names of the indicator columns col is encoded into.
+func (e *OneHotEncoder) OutputColumns(col string) []string {
+	var ret []string
+	for i, val := range e.Categories[col] {
+		if i == 0 && e.DropFirst {
+			continue
+		}
+		ret = append(ret, col+"_"+val)
+	}
+	if len(e.Infrequent[col]) > 0 {
+		ret = append(ret, col+"_other")
+	}
+	if e.Unknown == UnknownBucket {
+		ret = append(ret, col+"_unknown")
+	}
+	return ret
+}
+
+// fittedColumns returns the encoded columns in a fixed order.
+func (e *OneHotEncoder) fittedColumns() []string {
+	if len(e.Columns) > 0 {
+		return e.Columns
+	}
+	return sortedColumns(e.Categories)
+}
+
+func (e *OneHotEncoder) transformerKind() string {
+	return "onehot"
+}
+
+// stringColumns returns the names of the string columns of df.
+func stringColumns(df dataframe.DataFrame) []string {
+	var ret []string
+	types := df.Types()
+	for i, name := range df.Names() {
+		if types[i] == series.String {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// OrdinalEncoder replaces categorical columns by the position of each category in an order, 0, 1, 2, ...
+type OrdinalEncoder struct {
+	// Columns are the columns to encode. If empty, Fit encodes every string column.
+	Columns []string `json:"columns,omitempty"`
+	// Order gives the order of the categories of a column, e.g. {"Size": {"small", "medium", "large"}}. Columns
+	// without an order have their categories sorted. Fit fails if a column has categories missing from its order.
+	Order map[string][]string `json:"order,omitempty"`
+	// Unknown is the policy for categories not seen during Fit: UnknownIgnore encodes them as -1 and UnknownBucket
+	// as the number of categories, one past the last. The zero value is UnknownError.
+	Unknown UnknownPolicy `json:"unknown,omitempty"`
+
+	// Categories are the ordered categories of each column.
+	Categories map[string][]string `json:"categories"`
+}
+
+// NewOrdinalEncoder returns an encoder for the given columns with their categories in the given order.
+func NewOrdinalEncoder(order map[string][]string, cols ...string) *OrdinalEncoder {
+	return &OrdinalEncoder{Columns: cols, Order: order, Unknown: UnknownError}
+}
+
+// Fit learns the categories of each column from df.
+func (e *OrdinalEncoder) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	if err := e.Unknown.check(); err != nil {
+		return err
+	}
+	cols := e.Columns
+	if len(cols) == 0 {
+		cols = stringColumns(df)
+	}
+	categories := make(map[string][]string, len(cols))
+	for _, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return c.Err
+		}
+		order, ok := e.Order[col]
+		if !ok {
+			categories[col] = uniqueSorted(c.Records())
+			continue
+		}
+		known := make(map[string]bool, len(order))
+		for _, val := range order {
+			known[val] = true
+		}
+		for j, val := range c.Records() {
+			if !known[val] {
+				return fmt.Errorf("mlutil: category %q in column %q, row %d, is not in the given order", val, col, j)
+			}
+		}
+		categories[col] = order
+	}
+	e.Categories = categories
+	return nil
+}
+
+// Transform replaces the categories of each fitted column by their position.
+func (e *OrdinalEncoder) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if e.Categories == nil {
+		return df, ErrNotFitted
+	}
+	for _, col := range sortedColumns(e.Categories) {
+		index := make(map[string]int, len(e.Categories[col]))
+		for i, val := range e.Categories[col] {
+			index[val] = i
+		}
+		unknown := -1
+		if e.Unknown == UnknownBucket {
+			unknown = len(e.Categories[col])
+		}
+		var err error
+		df, err = mapColumn(df, col, func(val string, row int) (float64, error) {
+			if i, ok := index[val]; ok {
+				return float64(i), nil
+			}
+			if e.Unknown == UnknownIgnore || e.Unknown == UnknownBucket {
+				return float64(unknown), nil
+			}
+			return 0, fmt.Errorf("mlutil: unseen category %q in column %q, row %d", val, col, row)
+		})
+		if err != nil {
+			return df, err
+		}
+	}
+	return df, nil
+}
+
+func (e *OrdinalEncoder) transformerKind() string {
+	return "ordinal"
+}
+
+// FrequencyEncoder replaces categorical columns by the number of times each category occurs in the training data,
+// or by its share of the rows if Normalize is set. Unseen categories are encoded as 0.
+type FrequencyEncoder struct {
+	// Columns are the columns to encode. If empty, Fit encodes every string column.
+	Columns   []string `json:"columns,omitempty"`
+	Normalize bool     `json:"normalize"`
+
+	// Frequencies are the encodings of the categories of each column.
+	Frequencies map[string]map[string]float64 `json:"frequencies"`
+}
+
+// NewFrequencyEncoder returns an encoder replacing the categories of the given columns by their counts.
+func NewFrequencyEncoder(cols ...string) *FrequencyEncoder {
+	return &FrequencyEncoder{Columns: cols}
+}
+
+// Fit counts the categories of each column of df.
+func (e *FrequencyEncoder) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	cols := e.Columns
+	if len(cols) == 0 {
+		cols = stringColumns(df)
+	}
+	frequencies := make(map[string]map[string]float64, len(cols))
+	for _, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return c.Err
+		}
+		counts := make(map[string]float64)
+		for _, val := range c.Records() {
+			counts[val]++
+		}
+		if e.Normalize && c.Len() > 0 {
+			for val := range counts {
+				counts[val] /= float64(c.Len())
+			}
+		}
+		frequencies[col] = counts
+	}
+	e.Frequencies = frequencies
+	return nil
+}
+
+// Transform replaces the categories of each fitted column by their frequencies.
+func (e *FrequencyEncoder) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if e.Frequencies == nil {
+		return df, ErrNotFitted
+	}
+	for _, col := range sortedStatColumns(e.Frequencies) {
+		counts := e.Frequencies[col]
+		var err error
+		df, err = mapColumn(df, col, func(val string, row int) (float64, error) {
+			return counts[val], nil
+		})
+		if err != nil {
+			return df, err
+		}
+	}
+	return df, nil
+}
+
+func (e *FrequencyEncoder) transformerKind() string {
+	return "frequency"
+}
+
+// TargetEncoder replaces categorical columns by the mean of a numeric target column over the rows of each category,
+// smoothed towards the overall mean of the target:
+//
+//	(sum of the target over the category + Smoothing * Prior) / (rows of the category + Smoothing)
+//
+// Unseen categories are encoded as the Prior. Encoding the training rows with statistics that include their own
+// targets leaks the target into the features, so FitTransform encodes each training row with the statistics of
+// the other folds.
+type TargetEncoder struct {
+	// Columns are the columns to encode. If empty, Fit encodes every string column.
+	Columns []string `json:"columns,omitempty"`
+	// Target is the column whose mean is taken. It is not needed by Transform.
+	Target string `json:"target"`
+	// Smoothing is the weight, in rows, given to the Prior in the encoding of every category.
+	Smoothing float64 `json:"smoothing"`
+	// Folds is the number of folds FitTransform uses, at most one per row. Values below 2 encode the training data
+	// with the statistics of all of it, like Transform.
+	Folds int `json:"folds"`
+	// Seed seeds the assignment of the rows to folds.
+	Seed int64 `json:"seed"`
+
+	// Prior is the mean of the target over the training data, and Means the encoding of each category.
+	Prior float64                       `json:"prior"`
+	Means map[string]map[string]float64 `json:"means"`
+}
+
+// NewTargetEncoder returns an encoder of the given columns by the mean of target, encoding the training data over 5
+// folds.
+func NewTargetEncoder(target string, smoothing float64, cols ...string) *TargetEncoder {
+	return &TargetEncoder{Columns: cols, Target: target, Smoothing: smoothing, Folds: 5}
+}
+
+// Fit learns the smoothed mean of the target for the categories of each column of df.
+func (e *TargetEncoder) Fit(df dataframe.DataFrame) error {
+	cols, y, err := e.columns(df)
+	if err != nil {
+		return err
+	}
+	rows := make([]int, len(y), len(y))
+	for i := range rows {
+		rows[i] = i
+	}
+	means := make(map[string]map[string]float64, len(cols))
+	var prior float64
+	for _, col := range cols {
+		means[col], prior = e.means(df.Col(col).Records(), y, rows)
+	}
+	e.Prior, e.Means = prior, means
+	return nil
+}
+
+// FitTransform fits the encoder on df, and returns df with every row encoded by the statistics of the folds it is
+// not in.
+func (e *TargetEncoder) FitTransform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if err := e.Fit(df); err != nil {
+		return df, err
+	}
+	k := e.Folds
+	if k > df.Nrow() {
+		k = df.Nrow()
+	}
+	if k < 2 {
+		return e.Transform(df)
+	}
+	cols, y, err := e.columns(df)
+	if err != nil {
+		return df, err
+	}
+	folds, err := KFold(df, k, SplitConfig{Seed: e.Seed})
+	if err != nil {
+		return df, err
+	}
+	for _, col := range cols {
+		records := df.Col(col).Records()
+		encoded := make([]float64, len(records), len(records))
+		for f := range folds {
+			var rest []int
+			for g := range folds {
+				if g != f {
+					rest = append(rest, folds[g]...)
+				}
+			}
+			means, prior := e.means(records, y, rest)
+			for _, row := range folds[f] {
+				if m, ok := means[records[row]]; ok {
+					encoded[row] = m
+				} else {
+					encoded[row] = prior
+				}
+			}
+		}
+		df = df.Mutate(series.New(encoded, series.Float, col))
+	}
+	return df, df.Err
+}
+
+// Transform replaces the categories of each fitted column by their smoothed target means.
+func (e *TargetEncoder) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if e.Means == nil {
+		return df, ErrNotFitted
+	}
+	for _, col := range sortedStatColumns(e.Means) {
+		means := e.Means[col]
+		var err error
+		df, err = mapColumn(df, col, func(val string, row int) (float64, error) {
+			if m, ok := means[val]; ok {
+				return m, nil
+			}
+			return e.Prior, nil
+		})
+		if err != nil {
+			return df, err
+		}
+	}
+	return df, nil
+}
+
+// columns returns the columns to encode and the target values of df.
+func (e *TargetEncoder) columns(df dataframe.DataFrame) ([]string, []float64, error) {
+	if df.Err != nil {
+		return nil, nil, df.Err
+	}
+	if e.Smoothing < 0 {
+		return nil, nil, fmt.Errorf("mlutil: negative smoothing %v", e.Smoothing)
+	}
+	target := df.Col(e.Target)
+	if target.Err != nil {
+		return nil, nil, fmt.Errorf("mlutil: target column %q: %v", e.Target, target.Err)
+	}
+	if target.Len() == 0 {
+		return nil, nil, errors.New("mlutil: cannot fit target encoder on empty dataframe")
+	}
+	cols := e.Columns
+	if len(cols) == 0 {
+		for _, col := range stringColumns(df) {
+			if col != e.Target {
+				cols = append(cols, col)
+			}
+		}
+	}
+	for _, col := range cols {
+		if c := df.Col(col); c.Err != nil {
+			return nil, nil, c.Err
+		}
+	}
+	return cols, target.Float(), nil
+}
+
+// means returns the smoothed target mean of each category over the given rows, and the overall mean of the target
+// over them.
+func (e *TargetEncoder) means(records []string, y []float64, rows []int) (map[string]float64, float64) {
+	sums := make(map[string]float64)
+	counts := make(map[string]float64)
+	var total float64
+	for _, row := range rows {
+		sums[records[row]] += y[row]
+		counts[records[row]]++
+		total += y[row]
+	}
+	prior := total / float64(len(rows))
+	ret := make(map[string]float64, len(sums))
+	for val := range sums {
+		ret[val] = (sums[val] + e.Smoothing*prior) / (counts[val] + e.Smoothing)
+	}
+	return ret, prior
+}
+
+func (e *TargetEncoder) transformerKind() string {
+	return "target"
+}
+
+// mapColumn replaces col of df by a float column holding f of each of its values.
+func mapColumn(df dataframe.DataFrame, col string, f func(val string, row int) (float64, error)) (dataframe.DataFrame, error) {
+	if df.Err != nil {
+		return df, df.Err
+	}
+	c := df.Col(col)
+	if c.Err != nil {
+		return df, c.Err
+	}
+	records := c.Records()
+	v := make([]float64, len(records), len(records))
+	for i, val := range records {
+		var err error
+		if v[i], err = f(val, i); err != nil {
+			return df, err
+		}
+	}
+	df = df.Mutate(series.New(v, series.Float, col))
+	return df, df.Err
+}
+
+// uniqueSorted returns the distinct values of vals in increasing order.
+func uniqueSorted(vals []string) []string {
+	seen := make(map[string]bool)
+	ret := []string{}
+	for _, val := range vals {
+		if !seen[val] {
+			seen[val] = true
+			ret = append(ret, val)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sortedColumns returns the columns of a per-column map of categories in increasing order.
+func sortedColumns(m map[string][]string) []string {
+	ret := make([]string, 0, len(m))
+	for col := range m {
+		ret = append(ret, col)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sortedStatColumns returns the columns of a per-column map of category statistics in increasing order.
+func sortedStatColumns(m map[string]map[string]float64) []string {
+	ret := make([]string, 0, len(m))
+	for col := range m {
+		ret = append(ret, col)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,312 @@
+package mlutil
+
+import (
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"testing"
+)
+
+// colorFrame returns a dataframe of a string column color with the given values and a float column size.
+func colorFrame(colors ...string) dataframe.DataFrame {
+	size := make([]float64, len(colors), len(colors))
+	for i := range size {
+		size[i] = float64(i + 1)
+	}
+	return dataframe.New(series.New(colors, series.String, "color"), series.New(size, series.Float, "size"))
+}
+
+// sameStrings reports whether a and b hold the same strings in the same order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// indicator returns the expected records of a 0/1 column.
+func indicator(name string, records ...string) wantColumn {
+	return wantColumn{name: name, typ: series.Int, records: records}
+}
+
+func TestOneHotEncoder(t *testing.T) {
+	training := colorFrame("red", "blue", "red", "green")
+	tests := []struct {
+		name    string
+		encoder *OneHotEncoder
+		colors  []string
+		names   []string
+		want    []wantColumn
+	}{
+		{
+			name:    "sorted categories",
+			encoder: NewOneHotEncoder("color"),
+			colors:  []string{"green", "red", "blue"},
+			names:   []string{"size", "color_blue", "color_green", "color_red"},
+			want: []wantColumn{
+				indicator("color_blue", "0", "0", "1"),
+				indicator("color_green", "1", "0", "0"),
+				indicator("color_red", "0", "1", "0"),
+			},
+		},
+		{
+			name:    "every string column",
+			encoder: &OneHotEncoder{},
+			colors:  []string{"blue"},
+			names:   []string{"size", "color_blue", "color_green", "color_red"},
+			want:    []wantColumn{indicator("color_blue", "1"), indicator("color_red", "0")},
+		},
+		{
+			name:    "drop first",
+			encoder: &OneHotEncoder{Columns: []string{"color"}, DropFirst: true},
+			colors:  []string{"blue", "green", "red"},
+			names:   []string{"size", "color_green", "color_red"},
+			want: []wantColumn{
+				indicator("color_green", "0", "1", "0"),
+				indicator("color_red", "0", "0", "1"),
+			},
+		},
+		{
+			name:    "infrequent categories",
+			encoder: &OneHotEncoder{Columns: []string{"color"}, MinFrequency: 2},
+			colors:  []string{"red", "green", "blue"},
+			names:   []string{"size", "color_red", "color_other"},
+			want: []wantColumn{
+				indicator("color_red", "1", "0", "0"),
+				indicator("color_other", "0", "1", "1"),
+			},
+		},
+		{
+			name:    "ignore unknown",
+			encoder: &OneHotEncoder{Columns: []string{"color"}, Unknown: UnknownIgnore},
+			colors:  []string{"purple", "red"},
+			names:   []string{"size", "color_blue", "color_green", "color_red"},
+			want: []wantColumn{
+				indicator("color_blue", "0", "0"),
+				indicator("color_green", "0", "0"),
+				indicator("color_red", "0", "1"),
+			},
+		},
+		{
+			name:    "bucket unknown",
+			encoder: &OneHotEncoder{Columns: []string{"color"}, Unknown: UnknownBucket, MinFrequency: 2},
+			colors:  []string{"purple", "blue", "red"},
+			names:   []string{"size", "color_red", "color_other", "color_unknown"},
+			want: []wantColumn{
+				indicator("color_red", "0", "0", "1"),
+				indicator("color_other", "0", "1", "0"),
+				indicator("color_unknown", "1", "0", "0"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.encoder.Transform(training); err != ErrNotFitted {
+				t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+			}
+			if err := tt.encoder.Fit(training); err != nil {
+				t.Fatal(err)
+			}
+			out, err := tt.encoder.Transform(colorFrame(tt.colors...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameStrings(out.Names(), tt.names) {
+				t.Errorf("columns %q, want %q", out.Names(), tt.names)
+			}
+			checkColumns(t, out, tt.want...)
+			// the size column comes first, then the indicators
+			if got := tt.encoder.OutputColumns("color"); !sameStrings(got, tt.names[1:]) {
+				t.Errorf("OutputColumns() = %q, want %q", got, tt.names[1:])
+			}
+		})
+	}
+
+	e := NewOneHotEncoder("color")
+	if err := e.Fit(training); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Transform(colorFrame("purple")); err == nil {
+		t.Error("Transform() of an unseen category succeeded, want an error")
+	}
+	if err := (&OneHotEncoder{Unknown: UnknownPolicy("skip")}).Fit(training); err == nil {
+		t.Error("Fit() with an unknown category policy succeeded, want an error")
+	}
+	if err := NewOneHotEncoder("shape").Fit(training); err == nil {
+		t.Error("Fit() of a missing column succeeded, want an error")
+	}
+}
+
+func TestOrdinalEncoder(t *testing.T) {
+	training := colorFrame("red", "blue", "red", "green")
+	tests := []struct {
+		name    string
+		encoder *OrdinalEncoder
+		colors  []string
+		want    []float64
+	}{
+		{"sorted categories", NewOrdinalEncoder(nil, "color"), []string{"green", "red", "blue"}, []float64{1, 2, 0}},
+		{"every string column", &OrdinalEncoder{}, []string{"green", "red", "blue"}, []float64{1, 2, 0}},
+		{
+			name:    "given order",
+			encoder: NewOrdinalEncoder(map[string][]string{"color": {"red", "green", "blue", "purple"}}, "color"),
+			colors:  []string{"green", "red", "blue", "purple"},
+			want:    []float64{1, 0, 2, 3},
+		},
+		{
+			name:    "ignore unknown",
+			encoder: &OrdinalEncoder{Columns: []string{"color"}, Unknown: UnknownIgnore},
+			colors:  []string{"purple", "red"},
+			want:    []float64{-1, 2},
+		},
+		{
+			name:    "bucket unknown",
+			encoder: &OrdinalEncoder{Columns: []string{"color"}, Unknown: UnknownBucket},
+			colors:  []string{"purple", "red"},
+			want:    []float64{3, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.encoder.Transform(training); err != ErrNotFitted {
+				t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+			}
+			if err := tt.encoder.Fit(training); err != nil {
+				t.Fatal(err)
+			}
+			out, err := tt.encoder.Transform(colorFrame(tt.colors...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out, wantColumn{name: "color", typ: series.Float, floats: tt.want})
+		})
+	}
+
+	e := NewOrdinalEncoder(nil, "color")
+	if err := e.Fit(training); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Transform(colorFrame("purple")); err == nil {
+		t.Error("Transform() of an unseen category succeeded, want an error")
+	}
+	if err := NewOrdinalEncoder(map[string][]string{"color": {"red", "blue"}}, "color").Fit(training); err == nil {
+		t.Error("Fit() with a category missing from the order succeeded, want an error")
+	}
+	if err := (&OrdinalEncoder{Unknown: UnknownPolicy("skip")}).Fit(training); err == nil {
+		t.Error("Fit() with an unknown category policy succeeded, want an error")
+	}
+}
+
+func TestFrequencyEncoder(t *testing.T) {
+	training := colorFrame("red", "blue", "red", "green")
+	tests := []struct {
+		name    string
+		encoder *FrequencyEncoder
+		want    []float64
+	}{
+		{"counts", NewFrequencyEncoder("color"), []float64{1, 2, 0}},
+		{"every string column", &FrequencyEncoder{}, []float64{1, 2, 0}},
+		{"shares", &FrequencyEncoder{Columns: []string{"color"}, Normalize: true}, []float64{0.25, 0.5, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.encoder.Transform(training); err != ErrNotFitted {
+				t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+			}
+			if err := tt.encoder.Fit(training); err != nil {
+				t.Fatal(err)
+			}
+			// purple was not seen during Fit
+			out, err := tt.encoder.Transform(colorFrame("green", "red", "purple"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out, wantColumn{name: "color", typ: series.Float, floats: tt.want})
+		})
+	}
+	if err := NewFrequencyEncoder("shape").Fit(training); err == nil {
+		t.Error("Fit() of a missing column succeeded, want an error")
+	}
+}
+
+// targetFrame returns colorFrame(colors...) with a target column y.
+func targetFrame(colors []string, y []float64) dataframe.DataFrame {
+	return colorFrame(colors...).Mutate(series.New(y, series.Float, "y"))
+}
+
+func TestTargetEncoder(t *testing.T) {
+	// the target has a mean of 4; red has a mean of 2 over two rows, blue 4 and green 8 over one each
+	training := targetFrame([]string{"red", "blue", "red", "green"}, []float64{1, 4, 3, 8})
+	tests := []struct {
+		name    string
+		encoder *TargetEncoder
+		// means are the encodings of red, blue and green after Fit
+		means []float64
+		// fitTransform is the encoding of the training rows by FitTransform, the same as by Transform below 2 folds
+		fitTransform []float64
+	}{
+		{
+			name:         "no smoothing",
+			encoder:      &TargetEncoder{Columns: []string{"color"}, Target: "y"},
+			means:        []float64{2, 4, 8},
+			fitTransform: []float64{2, 4, 2, 8},
+		},
+		{
+			name:         "smoothed",
+			encoder:      &TargetEncoder{Target: "y", Smoothing: 2},
+			means:        []float64{(4 + 2*4) / 4.0, (4 + 2*4) / 3.0, (8 + 2*4) / 3.0},
+			fitTransform: []float64{3, 4, 3, 16.0 / 3},
+		},
+		{
+			// The folds are clamped to one per row, so every row is encoded by the other three. Blue and green are unseen there, so
+			// they are encoded as the mean of the other rows' targets, 12/3 and 8/3. Red is smoothed towards the
+			// means of the other rows, 15/3 and 13/3.
+			name:         "more folds than rows",
+			encoder:      &TargetEncoder{Columns: []string{"color"}, Target: "y", Smoothing: 1, Folds: 10, Seed: 7},
+			means:        []float64{(4 + 4) / 3.0, (4 + 4) / 2.0, (8 + 4) / 2.0},
+			fitTransform: []float64{(3 + 5) / 2.0, 4, (1 + 13.0/3) / 2, 8.0 / 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.encoder.Transform(training); err != ErrNotFitted {
+				t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+			}
+			out, err := tt.encoder.FitTransform(training)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out,
+				wantColumn{name: "color", typ: series.Float, floats: tt.fitTransform},
+				wantColumn{name: "y", typ: series.Float, floats: []float64{1, 4, 3, 8}},
+			)
+			if tt.encoder.Prior != 4 {
+				t.Errorf("Prior = %v, want 4", tt.encoder.Prior)
+			}
+			// unseen categories are encoded as the prior, and Transform needs no target
+			out, err = tt.encoder.Transform(colorFrame("red", "blue", "green", "purple"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out, wantColumn{name: "color", typ: series.Float, floats: append(tt.means, 4)})
+		})
+	}
+
+	errorTests := []struct {
+		name    string
+		encoder *TargetEncoder
+	}{
+		{"negative smoothing", NewTargetEncoder("y", -1, "color")},
+		{"missing target", NewTargetEncoder("z", 1, "color")},
+		{"missing column", NewTargetEncoder("y", 1, "shape")},
+	}
+	for _, tt := range errorTests {
+		if _, err := tt.encoder.FitTransform(training); err == nil {
+			t.Errorf("%s: FitTransform() succeeded, want an error", tt.name)
+		}
+	}
+}
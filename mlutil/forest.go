@@ -0,0 +1,91 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/fxsjy/RF.go/RF/Regression"
+)
+
+// ForestModel adapts RF.go's regression forest to the Model interface.
+type ForestModel struct {
+	Trees int
+	// Samples is the number of examples drawn for each tree; 0 uses as many as there are training examples.
+	Samples int
+	// Features is the number of features considered at each split.
+	Features int
+
+	forest *Regression.Forest
+}
+
+// NewForestModel returns an unfitted regression forest with the given number of trees, samples per tree and
+// features per split, in the same order as Regression.BuildForest.
+func NewForestModel(trees, samples, features int) *ForestModel {
+	return &ForestModel{Trees: trees, Samples: samples, Features: features}
+}
+
+// WrapForest returns an adapter for a forest that has already been built.
+func WrapForest(forest *Regression.Forest) *ForestModel {
+	return &ForestModel{Trees: len(forest.Trees), forest: forest}
+}
+
+// Fit trains the forest on x and y.
+func (m *ForestModel) Fit(x [][]float64, y []float64) error {
+	if len(x) == 0 {
+		return errors.New("mlutil: no training examples")
+	}
+	ix := make([][]interface{}, len(x), len(x))
+	for i := range x {
+		ix[i] = FloatsToInterfaces(x[i])
+	}
+	samples := m.Samples
+	if samples == 0 {
+		samples = len(x)
+	}
+	m.forest = Regression.BuildForest(ix, y, m.Trees, samples, m.Features)
+	return nil
+}
+
+// Predict returns the average prediction of the trees for x.
+func (m *ForestModel) Predict(x []float64) (float64, error) {
+	if m.forest == nil {
+		return 0, ErrNotFitted
+	}
+	return m.forest.Predicate(FloatsToInterfaces(x)), nil
+}
+
+// PredictProba always returns ErrNotClassifier.
+func (m *ForestModel) PredictProba(x []float64) ([]float64, error) {
+	return nil, ErrNotClassifier
+}
+
+// forestState is the persisted form of ForestModel. RF.go's trees encode to JSON as they are; the split values of the
+// numeric features decode back to float64, which is what Predicate expects.
+type forestState struct {
+	Trees    int                `json:"trees"`
+	Samples  int                `json:"samples"`
+	Features int                `json:"features"`
+	Forest   *Regression.Forest `json:"forest"`
+}
+
+func (m *ForestModel) modelKind() (string, string) {
+	return "forest", "RF.go"
+}
+
+func (m *ForestModel) marshalModel() ([]byte, error) {
+	if m.forest == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(forestState{m.Trees, m.Samples, m.Features, m.forest})
+}
+
+func (m *ForestModel) unmarshalModel(data []byte) error {
+	var s forestState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s.Forest == nil || len(s.Forest.Trees) == 0 {
+		return errors.New("forest has no trees")
+	}
+	*m = ForestModel{Trees: s.Trees, Samples: s.Samples, Features: s.Features, forest: s.Forest}
+	return nil
+}
@@ -0,0 +1,789 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Loss is the loss function a gradient boosted model minimises.
+type Loss int
+
+const (
+	// SquaredLoss is the squared error, for regression.
+	SquaredLoss Loss = iota
+	// AbsoluteLoss is the absolute error, for regression robust to outliers in the targets.
+	AbsoluteLoss
+	// HuberLoss is squared for small errors and absolute for large ones, for regression.
+	HuberLoss
+	// LogisticLoss is the log loss of binary classification, with labels 0 and 1.
+	LogisticLoss
+	// SoftmaxLoss is the cross-entropy of multi-class classification, with labels 0, 1, ... Every round grows a tree
+	// per class.
+	SoftmaxLoss
+)
+
+var lossNames = []string{"squared", "absolute", "huber", "logistic", "softmax"}
+
+// String returns the name of the loss, eg. "huber".
+func (l Loss) String() string {
+	if l < 0 || int(l) >= len(lossNames) {
+		return fmt.Sprintf("Loss(%d)", int(l))
+	}
+	return lossNames[l]
+}
+
+// ParseLoss returns the loss with the given name.
+func ParseLoss(name string) (Loss, error) {
+	for i, n := range lossNames {
+		if n == name {
+			return Loss(i), nil
+		}
+	}
+	return 0, fmt.Errorf("mlutil: unknown loss %q", name)
+}
+
+// classification reports whether l is a loss for classification.
+func (l Loss) classification() bool {
+	return l == LogisticLoss || l == SoftmaxLoss
+}
+
+// GBDTModel is a gradient boosted decision tree model. Every round fits a small tree to the gradient of the loss of
+// the trees so far, with leaf values from a Newton step, and adds it shrunk by LearningRate. Splits are found on
+// histograms of the features, bucketed once into at most Bins quantiles, so the cost of a split does not depend on
+// the number of distinct values. Missing values (NaN) always go right.
+type GBDTModel struct {
+	Loss Loss
+	// Rounds is the maximum number of boosting rounds, at least 1.
+	Rounds int
+	// LearningRate shrinks every tree, 0.1 if 0. Smaller values need more rounds but generalise better.
+	LearningRate float64
+	// MaxDepth is the depth of the trees, 3 if 0. MinSamplesLeaf is the number of examples each leaf needs, 20 if 0.
+	MaxDepth       int
+	MinSamplesLeaf int
+	// Lambda is the L2 regularisation of the leaf values.
+	Lambda float64
+	// Subsample is the fraction of the training examples, drawn without replacement, that each round is fitted to,
+	// 1 if 0. Values around 0.5 to 0.8 add randomness that reduces overfitting.
+	Subsample float64
+	// Bins is the maximum number of bins per feature, at most 256; 256 if 0.
+	Bins int
+	// HuberAlpha is the quantile of the absolute errors above which HuberLoss is linear, 0.9 if 0.
+	HuberAlpha float64
+	// EarlyStoppingRounds stops the training when the loss on the validation examples has not improved for that
+	// many rounds, and keeps the trees up to the best round. 0 grows every round. Fit holds out ValidationFraction
+	// of the training examples as validation examples, 0.1 if 0; FitValidation is given them.
+	EarlyStoppingRounds int
+	ValidationFraction  float64
+	// Workers is the number of goroutines that build the histograms, the number of CPUs if 0.
+	Workers int
+	Seed    int64
+
+	init              []float64
+	trees             [][]*TreeNode
+	features, classes int
+	trainLoss         []float64
+	validLoss         []float64
+}
+
+// NewGBDTModel returns an unfitted gradient boosted model with the given loss, maximum number of rounds, learning
+// rate and tree depth.
+func NewGBDTModel(loss Loss, rounds int, learningRate float64, maxDepth int) *GBDTModel {
+	return &GBDTModel{Loss: loss, Rounds: rounds, LearningRate: learningRate, MaxDepth: maxDepth}
+}
+
+// Fit trains the model on x and y. If EarlyStoppingRounds is set, ValidationFraction of the examples are held out,
+// at random with Seed, to decide when to stop.
+func (m *GBDTModel) Fit(x [][]float64, y []float64) error {
+	if m.EarlyStoppingRounds <= 0 {
+		return m.FitValidation(x, y, nil, nil)
+	}
+	if len(x) != len(y) {
+		return fmt.Errorf("mlutil: %d examples but %d targets", len(x), len(y))
+	}
+	fraction := m.ValidationFraction
+	if fraction == 0 {
+		fraction = 0.1
+	}
+	n := int(math.Round(fraction * float64(len(x))))
+	if n < 1 || n >= len(x) {
+		return fmt.Errorf("mlutil: cannot hold out %v of %d examples for early stopping", fraction, len(x))
+	}
+	perm := rand.New(rand.NewSource(m.Seed)).Perm(len(x))
+	var tx, vx [][]float64
+	var ty, vy []float64
+	for k, i := range perm {
+		if k < n {
+			vx, vy = append(vx, x[i]), append(vy, y[i])
+		} else {
+			tx, ty = append(tx, x[i]), append(ty, y[i])
+		}
+	}
+	return m.FitValidation(tx, ty, vx, vy)
+}
+
+// FitValidation trains the model on x and y, recording the loss on validX and validY after every round, which may
+// be nil. If EarlyStoppingRounds is set, the validation loss decides when to stop.
+func (m *GBDTModel) FitValidation(x [][]float64, y []float64, validX [][]float64, validY []float64) error {
+	if len(x) == 0 {
+		return errors.New("mlutil: no training examples")
+	}
+	if m.Rounds < 1 {
+		return fmt.Errorf("mlutil: gradient boosted model needs at least 1 round, has %d", m.Rounds)
+	}
+	if len(x) != len(y) || len(validX) != len(validY) {
+		return errors.New("mlutil: the numbers of examples and targets differ")
+	}
+	if m.Loss < SquaredLoss || m.Loss > SoftmaxLoss {
+		return fmt.Errorf("mlutil: unknown loss %d", int(m.Loss))
+	}
+	if m.EarlyStoppingRounds > 0 && len(validX) == 0 {
+		return errors.New("mlutil: early stopping needs validation examples")
+	}
+	m.classes = 0
+	if m.Loss.classification() {
+		for _, labels := range [][]float64{y, validY} {
+			for i, v := range labels {
+				if v < 0 || v != math.Trunc(v) || (m.Loss == LogisticLoss && v > 1) {
+					return fmt.Errorf("mlutil: label %v of example %d is not a class index for %v loss", v, i, m.Loss)
+				}
+				if int(v)+1 > m.classes {
+					m.classes = int(v) + 1
+				}
+			}
+		}
+		if m.Loss == LogisticLoss {
+			m.classes = 2
+		}
+	}
+	outputs := 1
+	if m.Loss == SoftmaxLoss {
+		outputs = m.classes
+	}
+
+	b := m.newBuilder(x)
+	rnd := rand.New(rand.NewSource(m.Seed))
+	m.features = len(x[0])
+	m.init = m.initialScores(y, outputs)
+	m.trees, m.trainLoss, m.validLoss = nil, nil, nil
+	raw := m.rawScores(x, outputs)
+	validRaw := m.rawScores(validX, outputs)
+	best, bestLoss := 0, math.Inf(1)
+
+	idx := make([]int, len(x), len(x))
+	samples := len(x)
+	if m.Subsample > 0 && m.Subsample < 1 {
+		if samples = int(math.Round(m.Subsample * float64(len(x)))); samples < 1 {
+			samples = 1
+		}
+	}
+	residuals := make([]float64, len(x), len(x))
+	// HuberLoss re-estimates delta every round to fit the trees, but losses computed with different deltas cannot
+	// be compared, so the recorded losses use the delta of the initial scores throughout
+	var lossDelta float64
+	if m.Loss == HuberLoss {
+		for i := range idx {
+			idx[i] = i
+		}
+		lossDelta = m.huberDelta(y, raw, idx)
+	}
+	for round := 0; round < m.Rounds; round++ {
+		for i := range idx {
+			idx[i] = i
+		}
+		if samples < len(x) {
+			rnd.Shuffle(len(idx), func(i, j int) { idx[i], idx[j] = idx[j], idx[i] })
+		}
+		sample := idx[:samples]
+
+		var delta float64
+		if m.Loss == HuberLoss {
+			delta = m.huberDelta(y, raw, sample)
+		}
+		var proba [][]float64
+		if m.Loss == SoftmaxLoss {
+			proba = make([][]float64, len(x), len(x))
+			for _, i := range sample {
+				proba[i] = softmax(raw[i])
+			}
+		}
+		trees := make([]*TreeNode, outputs, outputs)
+		for k := range trees {
+			for _, i := range sample {
+				var p []float64
+				if proba != nil {
+					p = proba[i]
+				}
+				b.g[i], b.h[i] = m.gradient(y[i], raw[i], k, p, delta)
+				residuals[i] = y[i] - raw[i][0]
+			}
+			switch m.Loss {
+			case AbsoluteLoss:
+				b.leafValue = func(leaf []int) float64 { return median(gather(residuals, leaf)) }
+			case HuberLoss:
+				b.leafValue = func(leaf []int) float64 { return huberLeaf(residuals, leaf, delta) }
+			case SoftmaxLoss:
+				scale := float64(outputs-1) / float64(outputs)
+				b.leafValue = func(leaf []int) float64 { return scale * b.newton(leaf) }
+			default:
+				b.leafValue = b.newton
+			}
+			trees[k] = b.grow(sample, b.histogram(sample), 0)
+		}
+		m.trees = append(m.trees, trees)
+		for i := range x {
+			addTrees(raw[i], trees, x[i])
+		}
+		for i := range validX {
+			addTrees(validRaw[i], trees, validX[i])
+		}
+
+		m.trainLoss = append(m.trainLoss, m.loss(y, raw, lossDelta))
+		if len(validX) == 0 {
+			continue
+		}
+		loss := m.loss(validY, validRaw, lossDelta)
+		m.validLoss = append(m.validLoss, loss)
+		if loss < bestLoss {
+			best, bestLoss = round, loss
+		}
+		if m.EarlyStoppingRounds > 0 && round-best >= m.EarlyStoppingRounds {
+			break
+		}
+	}
+	if m.EarlyStoppingRounds > 0 && len(m.trees) > best+1 {
+		m.trees = m.trees[:best+1]
+	}
+	return nil
+}
+
+// FittedRounds returns the number of rounds of trees the model kept, which is less than Rounds if training stopped
+// early.
+func (m *GBDTModel) FittedRounds() int {
+	return len(m.trees)
+}
+
+// LossHistory returns the loss on the training examples and on the validation examples after every round. The
+// validation losses are nil if there were no validation examples. For HuberLoss every loss uses the delta of the
+// initial scores on the training examples, so that they are comparable across rounds.
+func (m *GBDTModel) LossHistory() (train, valid []float64) {
+	return m.trainLoss, m.validLoss
+}
+
+// Predict returns the predicted value for regression losses, and the most probable class for classification.
+func (m *GBDTModel) Predict(x []float64) (float64, error) {
+	raw, err := m.raw(x)
+	if err != nil {
+		return 0, err
+	}
+	switch m.Loss {
+	case LogisticLoss:
+		if raw[0] > 0 {
+			return 1, nil
+		}
+		return 0, nil
+	case SoftmaxLoss:
+		return float64(MaxIndex(raw)), nil
+	}
+	return raw[0], nil
+}
+
+// PredictProba returns the probability of each class. It returns ErrNotClassifier for regression losses.
+func (m *GBDTModel) PredictProba(x []float64) ([]float64, error) {
+	if m.trees != nil && !m.Loss.classification() {
+		return nil, ErrNotClassifier
+	}
+	raw, err := m.raw(x)
+	if err != nil {
+		return nil, err
+	}
+	if m.Loss == LogisticLoss {
+		p := sigmoid(raw[0])
+		return []float64{1 - p, p}, nil
+	}
+	return softmax(raw), nil
+}
+
+// raw returns the sum of the initial scores and the trees for x.
+func (m *GBDTModel) raw(x []float64) ([]float64, error) {
+	if m.trees == nil {
+		return nil, ErrNotFitted
+	}
+	if len(x) < m.features {
+		return nil, fmt.Errorf("mlutil: %d features, the model was fitted on %d", len(x), m.features)
+	}
+	ret := append([]float64(nil), m.init...)
+	for _, trees := range m.trees {
+		addTrees(ret, trees, x)
+	}
+	return ret, nil
+}
+
+// rawScores returns the initial scores for every example of x.
+func (m *GBDTModel) rawScores(x [][]float64, outputs int) [][]float64 {
+	ret := make([][]float64, len(x), len(x))
+	for i := range ret {
+		ret[i] = append(make([]float64, 0, outputs), m.init...)
+	}
+	return ret
+}
+
+// addTrees adds the leaf values of the trees of a round for x to raw.
+func addTrees(raw []float64, trees []*TreeNode, x []float64) {
+	for k, n := range trees {
+		for !n.Leaf() {
+			if x[n.Feature] <= n.Threshold {
+				n = n.Left
+			} else {
+				n = n.Right
+			}
+		}
+		raw[k] += n.Value
+	}
+}
+
+// initialScores returns the constant prediction that minimises the loss on y.
+func (m *GBDTModel) initialScores(y []float64, outputs int) []float64 {
+	ret := make([]float64, outputs, outputs)
+	switch m.Loss {
+	case SquaredLoss:
+		for _, v := range y {
+			ret[0] += v / float64(len(y))
+		}
+	case AbsoluteLoss, HuberLoss:
+		ret[0] = median(y)
+	case LogisticLoss, SoftmaxLoss:
+		counts := make([]float64, m.classes, m.classes)
+		for _, v := range y {
+			counts[int(v)]++
+		}
+		for k := range counts {
+			counts[k] = math.Max(counts[k]/float64(len(y)), 1e-15)
+		}
+		if m.Loss == LogisticLoss {
+			ret[0] = math.Log(counts[1] / counts[0])
+		} else {
+			for k := range ret {
+				ret[k] = math.Log(counts[k])
+			}
+		}
+	}
+	return ret
+}
+
+// gradient returns the first and second derivatives of the loss of the example with label y and raw scores raw
+// with respect to raw[k]. proba are the class probabilities for SoftmaxLoss, and delta the threshold of HuberLoss.
+func (m *GBDTModel) gradient(y float64, raw []float64, k int, proba []float64, delta float64) (g, h float64) {
+	switch m.Loss {
+	case AbsoluteLoss:
+		if raw[0] > y {
+			return 1, 1
+		}
+		return -1, 1
+	case HuberLoss:
+		r := y - raw[0]
+		if math.Abs(r) <= delta {
+			return -r, 1
+		}
+		return -delta * math.Copysign(1, r), 1
+	case LogisticLoss:
+		p := sigmoid(raw[0])
+		return p - y, math.Max(p*(1-p), 1e-16)
+	case SoftmaxLoss:
+		p := proba[k]
+		if int(y) == k {
+			return p - 1, math.Max(p*(1-p), 1e-16)
+		}
+		return p, math.Max(p*(1-p), 1e-16)
+	}
+	return raw[0] - y, 1
+}
+
+// loss returns the mean loss of the raw scores for the labels y: the mean squared error for SquaredLoss, the mean
+// absolute error for AbsoluteLoss, the Huber loss with threshold delta for HuberLoss and the log loss for
+// classification.
+func (m *GBDTModel) loss(y []float64, raw [][]float64, delta float64) float64 {
+	var sum float64
+	for i := range y {
+		switch m.Loss {
+		case SquaredLoss:
+			sum += (y[i] - raw[i][0]) * (y[i] - raw[i][0])
+		case AbsoluteLoss:
+			sum += math.Abs(y[i] - raw[i][0])
+		case HuberLoss:
+			if r := math.Abs(y[i] - raw[i][0]); r <= delta {
+				sum += r * r / 2
+			} else {
+				sum += delta * (r - delta/2)
+			}
+		case LogisticLoss:
+			p := sigmoid(raw[i][0])
+			if y[i] == 0 {
+				p = 1 - p
+			}
+			sum -= math.Log(math.Max(p, 1e-15))
+		case SoftmaxLoss:
+			sum -= math.Log(math.Max(softmax(raw[i])[int(y[i])], 1e-15))
+		}
+	}
+	return sum / float64(len(y))
+}
+
+// huberDelta returns the HuberAlpha quantile of the absolute errors of the examples idx.
+func (m *GBDTModel) huberDelta(y []float64, raw [][]float64, idx []int) float64 {
+	alpha := m.HuberAlpha
+	if alpha == 0 {
+		alpha = 0.9
+	}
+	errs := make([]float64, len(idx), len(idx))
+	for k, i := range idx {
+		errs[k] = math.Abs(y[i] - raw[i][0])
+	}
+	sort.Float64s(errs)
+	return errs[int(alpha*float64(len(errs)-1))]
+}
+
+// gather returns the values v of the examples idx.
+func gather(v []float64, idx []int) []float64 {
+	ret := make([]float64, len(idx), len(idx))
+	for k, i := range idx {
+		ret[k] = v[i]
+	}
+	return ret
+}
+
+// huberLeaf returns the value of a leaf for HuberLoss: the median residual of its examples, corrected by the mean of
+// their differences from it clipped to delta.
+func huberLeaf(residuals []float64, idx []int, delta float64) float64 {
+	med := median(gather(residuals, idx))
+	var sum float64
+	for _, i := range idx {
+		d := residuals[i] - med
+		sum += math.Copysign(math.Min(math.Abs(d), delta), d)
+	}
+	return med + sum/float64(len(idx))
+}
+
+func sigmoid(v float64) float64 {
+	return 1 / (1 + math.Exp(-v))
+}
+
+// softmax returns the probabilities for the raw scores v.
+func softmax(v []float64) []float64 {
+	max := v[0]
+	for _, x := range v {
+		max = math.Max(max, x)
+	}
+	ret := make([]float64, len(v), len(v))
+	var sum float64
+	for k, x := range v {
+		ret[k] = math.Exp(x - max)
+		sum += ret[k]
+	}
+	for k := range ret {
+		ret[k] /= sum
+	}
+	return ret
+}
+
+// histBin accumulates the gradients, hessians and number of the examples whose feature falls in a bin.
+type histBin struct {
+	g, h float64
+	n    int
+}
+
+// gbBuilder grows the trees of a gradient boosted model on the binned training examples.
+type gbBuilder struct {
+	m *GBDTModel
+	// binned holds the bin of every example, by feature. A feature value falls in bin b if it is at most edges[b]
+	// and greater than edges[b-1]; values above the last edge, and NaN, fall in the last bin.
+	binned            [][]uint8
+	edges             [][]float64
+	bins              int
+	g, h              []float64
+	lambda, rate      float64
+	maxDepth, minLeaf int
+	workers           int
+	leafValue         func(idx []int) float64
+}
+
+// newBuilder bins the features of x.
+func (m *GBDTModel) newBuilder(x [][]float64) *gbBuilder {
+	b := &gbBuilder{
+		m:        m,
+		bins:     m.Bins,
+		g:        make([]float64, len(x), len(x)),
+		h:        make([]float64, len(x), len(x)),
+		lambda:   m.Lambda,
+		rate:     m.LearningRate,
+		maxDepth: m.MaxDepth,
+		minLeaf:  m.MinSamplesLeaf,
+		workers:  m.Workers,
+	}
+	if b.bins < 2 || b.bins > 256 {
+		b.bins = 256
+	}
+	if b.rate == 0 {
+		b.rate = 0.1
+	}
+	if b.maxDepth == 0 {
+		b.maxDepth = 3
+	}
+	if b.minLeaf == 0 {
+		b.minLeaf = 20
+	}
+	if b.workers < 1 {
+		b.workers = runtime.NumCPU()
+	}
+	features := len(x[0])
+	b.binned = make([][]uint8, features, features)
+	b.edges = make([][]float64, features, features)
+	b.parallel(features, func(f int) {
+		values := make([]float64, 0, len(x))
+		for i := range x {
+			if !math.IsNaN(x[i][f]) {
+				values = append(values, x[i][f])
+			}
+		}
+		sort.Float64s(values)
+		var distinct []float64
+		for i, v := range values {
+			if i == 0 || v != values[i-1] {
+				distinct = append(distinct, v)
+			}
+		}
+		var edges []float64
+		if len(distinct) <= b.bins {
+			// a bin per value, split half way between them
+			for i := 1; i < len(distinct); i++ {
+				edges = append(edges, distinct[i-1]+(distinct[i]-distinct[i-1])/2)
+			}
+		} else {
+			for q := 1; q < b.bins; q++ {
+				v := values[q*len(values)/b.bins]
+				if len(edges) == 0 || v > edges[len(edges)-1] {
+					edges = append(edges, v)
+				}
+			}
+		}
+		b.edges[f] = edges
+		b.binned[f] = make([]uint8, len(x), len(x))
+		for i := range x {
+			b.binned[f][i] = uint8(sort.SearchFloat64s(edges, x[i][f]))
+		}
+	})
+	return b
+}
+
+// parallel calls f for every feature, splitting the features between the workers of b.
+func (b *gbBuilder) parallel(features int, f func(feature int)) {
+	workers := b.workers
+	if workers > features {
+		workers = features
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			for j := from; j < to; j++ {
+				f(j)
+			}
+		}(w*features/workers, (w+1)*features/workers)
+	}
+	wg.Wait()
+}
+
+// histogram returns the histograms of the gradients of the examples idx, bins entries per feature.
+func (b *gbBuilder) histogram(idx []int) []histBin {
+	hist := make([]histBin, len(b.binned)*b.bins, len(b.binned)*b.bins)
+	b.parallel(len(b.binned), func(f int) {
+		col, hf := b.binned[f], hist[f*b.bins:(f+1)*b.bins]
+		for _, i := range idx {
+			bin := &hf[col[i]]
+			bin.g += b.g[i]
+			bin.h += b.h[i]
+			bin.n++
+		}
+	})
+	return hist
+}
+
+// newton returns the leaf value that minimises the second order approximation of the loss of the examples idx.
+func (b *gbBuilder) newton(idx []int) float64 {
+	var g, h float64
+	for _, i := range idx {
+		g += b.g[i]
+		h += b.h[i]
+	}
+	return -g / math.Max(h+b.lambda, 1e-16)
+}
+
+// grow returns the tree fitted to the gradients of the examples idx, whose histograms are hist. It reorders idx
+// and reuses hist.
+func (b *gbBuilder) grow(idx []int, hist []histBin, depth int) *TreeNode {
+	n := &TreeNode{Samples: len(idx), Value: b.rate * b.leafValue(idx)}
+	if depth >= b.maxDepth || len(idx) < 2*b.minLeaf {
+		return n
+	}
+	feature, bin, ok := b.split(hist, len(idx))
+	if !ok {
+		return n
+	}
+	col := b.binned[feature]
+	i, j := 0, len(idx)-1
+	for i <= j {
+		if col[idx[i]] <= bin {
+			i++
+		} else {
+			idx[i], idx[j] = idx[j], idx[i]
+			j--
+		}
+	}
+	left, right := idx[:i], idx[i:]
+	// build the histograms of the smaller side and subtract them from the parent's for the larger one, unless the
+	// children are too deep to be split
+	var leftHist, rightHist []histBin
+	if depth+1 < b.maxDepth {
+		small := left
+		if len(right) < len(left) {
+			small = right
+		}
+		smallHist := b.histogram(small)
+		for k := range hist {
+			hist[k].g -= smallHist[k].g
+			hist[k].h -= smallHist[k].h
+			hist[k].n -= smallHist[k].n
+		}
+		leftHist, rightHist = smallHist, hist
+		if len(left) > len(right) {
+			leftHist, rightHist = hist, smallHist
+		}
+	}
+	n.Feature, n.Threshold = feature, b.edges[feature][bin]
+	n.Left = b.grow(left, leftHist, depth+1)
+	n.Right = b.grow(right, rightHist, depth+1)
+	return n
+}
+
+// split returns the feature and bin of the split of the examples with histograms hist, n of them, that most
+// reduces the second order approximation of the loss. ok is false if no split reduces it.
+func (b *gbBuilder) split(hist []histBin, n int) (feature int, bin uint8, ok bool) {
+	var g, h float64
+	for _, hb := range hist[:b.bins] {
+		g += hb.g
+		h += hb.h
+	}
+	score := func(g, h float64) float64 {
+		if h += b.lambda; h < 1e-16 {
+			h = 1e-16
+		}
+		return g * g / h
+	}
+	parent := score(g, h)
+	best := 1e-12 * math.Max(1, parent)
+	for f := range b.binned {
+		hf := hist[f*b.bins : (f+1)*b.bins]
+		var gl, hl float64
+		nl := 0
+		for k := 0; k < len(b.edges[f]); k++ {
+			// an empty bin gives the same split as the one before it
+			if hf[k].n == 0 {
+				continue
+			}
+			gl, hl, nl = gl+hf[k].g, hl+hf[k].h, nl+hf[k].n
+			if nl < b.minLeaf {
+				continue
+			}
+			if n-nl < b.minLeaf {
+				break
+			}
+			if gain := score(gl, hl) + score(g-gl, h-hl) - parent; gain > best {
+				best, feature, bin, ok = gain, f, uint8(k), true
+			}
+		}
+	}
+	return feature, bin, ok
+}
+
+// gbdtState is the persisted form of GBDTModel.
+type gbdtState struct {
+	Loss                string        `json:"loss"`
+	Rounds              int           `json:"rounds"`
+	LearningRate        float64       `json:"learning_rate"`
+	MaxDepth            int           `json:"max_depth"`
+	MinSamplesLeaf      int           `json:"min_samples_leaf"`
+	Lambda              float64       `json:"lambda"`
+	Subsample           float64       `json:"subsample"`
+	Bins                int           `json:"bins"`
+	HuberAlpha          float64       `json:"huber_alpha"`
+	EarlyStoppingRounds int           `json:"early_stopping_rounds"`
+	ValidationFraction  float64       `json:"validation_fraction"`
+	Seed                int64         `json:"seed"`
+	Features            int           `json:"features"`
+	Classes             int           `json:"classes"`
+	Init                []float64     `json:"init"`
+	Trees               [][]*TreeNode `json:"trees"`
+}
+
+func (m *GBDTModel) modelKind() (string, string) {
+	return "gbdt", "mlutil"
+}
+
+func (m *GBDTModel) marshalModel() ([]byte, error) {
+	if m.trees == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(gbdtState{
+		m.Loss.String(), m.Rounds, m.LearningRate, m.MaxDepth, m.MinSamplesLeaf, m.Lambda, m.Subsample, m.Bins,
+		m.HuberAlpha, m.EarlyStoppingRounds, m.ValidationFraction, m.Seed, m.features, m.classes, m.init, m.trees,
+	})
+}
+
+func (m *GBDTModel) unmarshalModel(data []byte) error {
+	var s gbdtState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	loss, err := ParseLoss(s.Loss)
+	if err != nil {
+		return err
+	}
+	if len(s.Trees) == 0 || len(s.Init) == 0 {
+		return errors.New("model has no trees")
+	}
+	for _, trees := range s.Trees {
+		if len(trees) != len(s.Init) {
+			return errors.New("a round has the wrong number of trees")
+		}
+		for _, t := range trees {
+			if t == nil {
+				return errors.New("model has an empty tree")
+			}
+		}
+	}
+	*m = GBDTModel{
+		Loss:                loss,
+		Rounds:              s.Rounds,
+		LearningRate:        s.LearningRate,
+		MaxDepth:            s.MaxDepth,
+		MinSamplesLeaf:      s.MinSamplesLeaf,
+		Lambda:              s.Lambda,
+		Subsample:           s.Subsample,
+		Bins:                s.Bins,
+		HuberAlpha:          s.HuberAlpha,
+		EarlyStoppingRounds: s.EarlyStoppingRounds,
+		ValidationFraction:  s.ValidationFraction,
+		Seed:                s.Seed,
+		init:                s.Init,
+		trees:               s.Trees,
+		features:            s.Features,
+		classes:             s.Classes,
+	}
+	return nil
+}
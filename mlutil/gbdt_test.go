@@ -0,0 +1,188 @@
+package mlutil
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// noiseData returns n examples of two uniform random features with uniform random targets.
+func noiseData(n int, seed int64) ([][]float64, []float64) {
+	rnd := rand.New(rand.NewSource(seed))
+	x := make([][]float64, n, n)
+	y := make([]float64, n, n)
+	for i := range x {
+		x[i] = []float64{rnd.Float64(), rnd.Float64()}
+		y[i] = rnd.Float64()
+	}
+	return x, y
+}
+
+func TestGBDTModelFit(t *testing.T) {
+	tests := []struct {
+		name   string
+		loss   Loss
+		rounds int
+		rate   float64
+		depth  int
+		parts  int
+		scale  float64
+	}{
+		// a single stump with a full Newton step fits a step function exactly for every regression loss
+		{"squared", SquaredLoss, 1, 1, 1, 2, 10},
+		{"absolute", AbsoluteLoss, 1, 1, 1, 2, 10},
+		{"huber", HuberLoss, 1, 1, 1, 2, 10},
+		{"squared three steps", SquaredLoss, 1, 1, 2, 3, 1},
+		{"logistic", LogisticLoss, 20, 0.5, 1, 2, 1},
+		{"softmax", SoftmaxLoss, 20, 0.5, 2, 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := stepData(60, tt.parts, tt.scale)
+			m := &GBDTModel{Loss: tt.loss, Rounds: tt.rounds, LearningRate: tt.rate, MaxDepth: tt.depth, MinSamplesLeaf: 1}
+			if err := m.Fit(x, y); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.FittedRounds(); got != tt.rounds {
+				t.Errorf("FittedRounds() = %d, want %d", got, tt.rounds)
+			}
+			for i := range x {
+				got, err := m.Predict(x[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(got-y[i]) > 1e-9 {
+					t.Errorf("Predict(%v) = %v, want %v", x[i], got, y[i])
+				}
+			}
+			train, valid := m.LossHistory()
+			if len(train) != tt.rounds || valid != nil {
+				t.Errorf("LossHistory() has %d training and %d validation losses, want %d and 0", len(train), len(valid), tt.rounds)
+			}
+			_, err := m.PredictProba(x[0])
+			if tt.loss.classification() != (err == nil) {
+				t.Errorf("PredictProba() error = %v for %v loss", err, tt.loss)
+			}
+		})
+	}
+}
+
+func TestGBDTModelFitErrors(t *testing.T) {
+	x, y := stepData(10, 2, 1)
+	tests := []struct {
+		name   string
+		m      GBDTModel
+		x      [][]float64
+		y      []float64
+		validX [][]float64
+		validY []float64
+	}{
+		{"no rounds", GBDTModel{Rounds: 0}, x, y, nil, nil},
+		{"negative rounds", GBDTModel{Rounds: -1}, x, y, nil, nil},
+		{"no examples", GBDTModel{Rounds: 1}, nil, nil, nil, nil},
+		{"missing targets", GBDTModel{Rounds: 1}, x, y[1:], nil, nil},
+		{"unknown loss", GBDTModel{Loss: Loss(99), Rounds: 1}, x, y, nil, nil},
+		{"logistic label 2", GBDTModel{Loss: LogisticLoss, Rounds: 1}, x, []float64{0, 1, 2, 0, 1, 2, 0, 1, 2, 0}, nil, nil},
+		{"fractional class", GBDTModel{Loss: SoftmaxLoss, Rounds: 1}, x, []float64{0, 1, 0.5, 0, 1, 0, 0, 1, 0, 0}, nil, nil},
+		{"early stopping without validation", GBDTModel{Rounds: 1, EarlyStoppingRounds: 1}, x, y, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.m.FitValidation(tt.x, tt.y, tt.validX, tt.validY); err == nil {
+				t.Error("FitValidation() succeeded, want an error")
+			}
+		})
+	}
+	m := &GBDTModel{Rounds: 0, EarlyStoppingRounds: 2}
+	if err := m.Fit(x, y); err == nil {
+		t.Error("Fit() with no rounds and early stopping succeeded, want an error")
+	}
+}
+
+func TestGBDTModelEarlyStopping(t *testing.T) {
+	// deep trees on random targets fit nothing but noise, so the validation loss soon stops improving
+	x, y := noiseData(200, 1)
+	validX, validY := noiseData(100, 2)
+	for _, loss := range []Loss{SquaredLoss, AbsoluteLoss, HuberLoss} {
+		m := &GBDTModel{Loss: loss, Rounds: 500, LearningRate: 0.5, MaxDepth: 6, MinSamplesLeaf: 1, EarlyStoppingRounds: 4, Seed: 1}
+		if err := m.FitValidation(x, y, validX, validY); err != nil {
+			t.Fatal(err)
+		}
+		train, valid := m.LossHistory()
+		if len(valid) != len(train) {
+			t.Fatalf("%v: LossHistory() has %d training and %d validation losses", loss, len(train), len(valid))
+		}
+		best := 0
+		for round := range valid {
+			if valid[round] < valid[best] {
+				best = round
+			}
+		}
+		if len(valid) != best+5 || m.FittedRounds() != best+1 {
+			t.Errorf("%v: stopped after %d rounds keeping %d, want 4 rounds after the best round %d and to keep %d",
+				loss, len(valid), m.FittedRounds(), best, best+1)
+		}
+		if train[len(train)-1] >= train[0] {
+			t.Errorf("%v: training loss went from %v to %v, want it to decrease", loss, train[0], train[len(train)-1])
+		}
+	}
+}
+
+func TestGBDTModelSaveLoad(t *testing.T) {
+	// a softmax model that holds out a fifth of its examples to stop early
+	x, y := stepData(90, 3, 1)
+	want := &GBDTModel{Loss: SoftmaxLoss, Rounds: 50, MinSamplesLeaf: 2, Subsample: 0.8, EarlyStoppingRounds: 5, ValidationFraction: 0.2, Seed: 1}
+	if err := want.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "gbdt.json")
+	if err := SaveModel(path, want, ModelMetadata{Target: "y"}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := loaded.(*GBDTModel)
+	if !ok {
+		t.Fatalf("LoadModel() returned a %T, want a *GBDTModel", loaded)
+	}
+	if m.Loss != want.Loss || m.FittedRounds() != want.FittedRounds() {
+		t.Errorf("loaded %v loss with %d rounds, want %v with %d", m.Loss, m.FittedRounds(), want.Loss, want.FittedRounds())
+	}
+	for i := range x {
+		wantProba, _ := want.PredictProba(x[i])
+		gotProba, err := m.PredictProba(x[i])
+		if err != nil || !reflect.DeepEqual(gotProba, wantProba) {
+			t.Fatalf("loaded PredictProba(%v) = %v, %v, want %v", x[i], gotProba, err, wantProba)
+		}
+	}
+
+	// the loaded model keeps every hyper-parameter, so refitting it trains the same model again
+	if m.EarlyStoppingRounds != 5 || m.ValidationFraction != 0.2 || m.Seed != 1 || m.Subsample != 0.8 {
+		t.Errorf("loaded %+v, want the hyper-parameters of %+v", m, want)
+	}
+	if err := m.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	if m.FittedRounds() != want.FittedRounds() {
+		t.Errorf("refitted %d rounds, want %d", m.FittedRounds(), want.FittedRounds())
+	}
+	huber := &GBDTModel{Loss: HuberLoss, Rounds: 5, MinSamplesLeaf: 2, HuberAlpha: 0.75}
+	if err := huber.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	data, err := huber.marshalModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded GBDTModel
+	if err := decoded.unmarshalModel(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.HuberAlpha != 0.75 {
+		t.Errorf("decoded HuberAlpha %v, want 0.75", decoded.HuberAlpha)
+	}
+}
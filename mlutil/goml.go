@@ -0,0 +1,257 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/cdipaolo/goml/base"
+	"github.com/cdipaolo/goml/linear"
+	"math"
+)
+
+// LeastSquaresModel adapts goml's linear.LeastSquares regression to the Model interface.
+type LeastSquaresModel struct {
+	Method         base.OptimizationMethod
+	LearningRate   float64
+	Regularization float64
+	MaxIterations  int
+
+	model *linear.LeastSquares
+}
+
+// NewLeastSquaresModel returns an unfitted goml least squares regression with the given hyper-parameters, in the
+// same order as linear.NewLeastSquares.
+func NewLeastSquaresModel(method base.OptimizationMethod, alpha, regularization float64, maxIterations int) *LeastSquaresModel {
+	return &LeastSquaresModel{Method: method, LearningRate: alpha, Regularization: regularization, MaxIterations: maxIterations}
+}
+
+// WrapLeastSquares returns an adapter for a least squares regression that has already been trained, so that it can
+// be evaluated or saved with SaveModel.
+func WrapLeastSquares(model *linear.LeastSquares) *LeastSquaresModel {
+	return &LeastSquaresModel{LearningRate: model.LearningRate(), MaxIterations: model.MaxIterations(), model: model}
+}
+
+// Fit trains the model on x and y.
+func (m *LeastSquaresModel) Fit(x [][]float64, y []float64) error {
+	model := linear.NewLeastSquares(m.Method, m.LearningRate, m.Regularization, m.MaxIterations, x, y)
+	if err := model.Learn(); err != nil {
+		return err
+	}
+	m.model = model
+	return nil
+}
+
+// Predict returns the predicted value for x.
+func (m *LeastSquaresModel) Predict(x []float64) (float64, error) {
+	if m.model == nil {
+		return 0, ErrNotFitted
+	}
+	p, err := m.model.Predict(x)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// PredictProba always returns ErrNotClassifier.
+func (m *LeastSquaresModel) PredictProba(x []float64) ([]float64, error) {
+	return nil, ErrNotClassifier
+}
+
+// LogisticModel adapts goml's binary linear.Logistic classifier to the Model interface. The labels must be 0 or 1.
+type LogisticModel struct {
+	Method         base.OptimizationMethod
+	LearningRate   float64
+	Regularization float64
+	MaxIterations  int
+
+	model *linear.Logistic
+}
+
+// NewLogisticModel returns an unfitted goml logistic classifier with the given hyper-parameters, in the same order as
+// linear.NewLogistic.
+func NewLogisticModel(method base.OptimizationMethod, alpha, regularization float64, maxIterations int) *LogisticModel {
+	return &LogisticModel{Method: method, LearningRate: alpha, Regularization: regularization, MaxIterations: maxIterations}
+}
+
+// WrapLogistic returns an adapter for a logistic classifier that has already been trained.
+func WrapLogistic(model *linear.Logistic) *LogisticModel {
+	return &LogisticModel{LearningRate: model.LearningRate(), MaxIterations: model.MaxIterations(), model: model}
+}
+
+// Fit trains the model on x and the 0/1 labels y.
+func (m *LogisticModel) Fit(x [][]float64, y []float64) error {
+	model := linear.NewLogistic(m.Method, m.LearningRate, m.Regularization, m.MaxIterations, x, y)
+	if err := model.Learn(); err != nil {
+		return err
+	}
+	m.model = model
+	return nil
+}
+
+// Predict returns 1 if the probability of the positive class is at least 0.5 and 0 otherwise.
+func (m *LogisticModel) Predict(x []float64) (float64, error) {
+	p, err := m.PredictProba(x)
+	if err != nil {
+		return 0, err
+	}
+	return math.Round(p[1]), nil
+}
+
+// PredictProba returns the probabilities of the negative and positive class.
+func (m *LogisticModel) PredictProba(x []float64) ([]float64, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	p, err := m.model.Predict(x)
+	if err != nil {
+		return nil, err
+	}
+	return []float64{1 - p[0], p[0]}, nil
+}
+
+// SoftmaxModel adapts goml's multi-class linear.Softmax classifier to the Model interface.
+type SoftmaxModel struct {
+	Method         base.OptimizationMethod
+	LearningRate   float64
+	Regularization float64
+	Classes        int
+	MaxIterations  int
+
+	model *linear.Softmax
+}
+
+// NewSoftmaxModel returns an unfitted goml softmax classifier for k classes with the given hyper-parameters, in the
+// same order as linear.NewSoftmax.
+func NewSoftmaxModel(method base.OptimizationMethod, alpha, regularization float64, k, maxIterations int) *SoftmaxModel {
+	return &SoftmaxModel{Method: method, LearningRate: alpha, Regularization: regularization, Classes: k, MaxIterations: maxIterations}
+}
+
+// WrapSoftmax returns an adapter for a softmax classifier that has already been trained.
+func WrapSoftmax(model *linear.Softmax) *SoftmaxModel {
+	return &SoftmaxModel{LearningRate: model.LearningRate(), Classes: len(model.Parameters), MaxIterations: model.MaxIterations(), model: model}
+}
+
+// Fit trains the model on x and the class labels y.
+func (m *SoftmaxModel) Fit(x [][]float64, y []float64) error {
+	model := linear.NewSoftmax(m.Method, m.LearningRate, m.Regularization, m.Classes, m.MaxIterations, x, y)
+	if err := model.Learn(); err != nil {
+		return err
+	}
+	m.model = model
+	return nil
+}
+
+// Predict returns the most probable class label.
+func (m *SoftmaxModel) Predict(x []float64) (float64, error) {
+	p, err := m.PredictProba(x)
+	if err != nil {
+		return 0, err
+	}
+	return float64(MaxIndex(p)), nil
+}
+
+// PredictProba returns the probability of each class.
+func (m *SoftmaxModel) PredictProba(x []float64) ([]float64, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	return m.model.Predict(x)
+}
+
+// gomlState is the persisted form of the goml adapters. Theta holds a single parameter vector for least squares and
+// logistic regression, and one per class for softmax.
+type gomlState struct {
+	Method         base.OptimizationMethod `json:"method"`
+	LearningRate   float64                 `json:"learning_rate"`
+	Regularization float64                 `json:"regularization"`
+	Classes        int                     `json:"classes,omitempty"`
+	MaxIterations  int                     `json:"max_iterations"`
+	Theta          [][]float64             `json:"theta"`
+}
+
+// readGomlState decodes data and checks that it holds n parameter vectors of the same, non-zero length. n < 0 takes
+// the number of vectors from the number of classes.
+func readGomlState(data []byte, n int) (gomlState, error) {
+	var s gomlState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, err
+	}
+	if n < 0 {
+		n = s.Classes
+	}
+	if len(s.Theta) != n || n == 0 {
+		return s, errors.New("wrong number of parameter vectors")
+	}
+	for i := range s.Theta {
+		if len(s.Theta[i]) == 0 || len(s.Theta[i]) != len(s.Theta[0]) {
+			return s, errors.New("parameter vectors are empty or of different lengths")
+		}
+	}
+	return s, nil
+}
+
+func (m *LeastSquaresModel) modelKind() (string, string) {
+	return "least_squares", "goml"
+}
+
+func (m *LeastSquaresModel) marshalModel() ([]byte, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(gomlState{m.Method, m.LearningRate, m.Regularization, 0, m.MaxIterations, [][]float64{m.model.Parameters}})
+}
+
+func (m *LeastSquaresModel) unmarshalModel(data []byte) error {
+	s, err := readGomlState(data, 1)
+	if err != nil {
+		return err
+	}
+	*m = *NewLeastSquaresModel(s.Method, s.LearningRate, s.Regularization, s.MaxIterations)
+	m.model = linear.NewLeastSquares(s.Method, s.LearningRate, s.Regularization, s.MaxIterations, nil, nil, len(s.Theta[0])-1)
+	m.model.Parameters = s.Theta[0]
+	return nil
+}
+
+func (m *LogisticModel) modelKind() (string, string) {
+	return "logistic", "goml"
+}
+
+func (m *LogisticModel) marshalModel() ([]byte, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(gomlState{m.Method, m.LearningRate, m.Regularization, 0, m.MaxIterations, [][]float64{m.model.Parameters}})
+}
+
+func (m *LogisticModel) unmarshalModel(data []byte) error {
+	s, err := readGomlState(data, 1)
+	if err != nil {
+		return err
+	}
+	*m = *NewLogisticModel(s.Method, s.LearningRate, s.Regularization, s.MaxIterations)
+	m.model = linear.NewLogistic(s.Method, s.LearningRate, s.Regularization, s.MaxIterations, nil, nil, len(s.Theta[0])-1)
+	m.model.Parameters = s.Theta[0]
+	return nil
+}
+
+func (m *SoftmaxModel) modelKind() (string, string) {
+	return "softmax", "goml"
+}
+
+func (m *SoftmaxModel) marshalModel() ([]byte, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(gomlState{m.Method, m.LearningRate, m.Regularization, m.Classes, m.MaxIterations, m.model.Parameters})
+}
+
+func (m *SoftmaxModel) unmarshalModel(data []byte) error {
+	s, err := readGomlState(data, -1)
+	if err != nil {
+		return err
+	}
+	*m = *NewSoftmaxModel(s.Method, s.LearningRate, s.Regularization, s.Classes, s.MaxIterations)
+	m.model = linear.NewSoftmax(s.Method, s.LearningRate, s.Regularization, s.Classes, s.MaxIterations, nil, nil, len(s.Theta[0])-1)
+	m.model.Parameters = s.Theta
+	return nil
+}
@@ -0,0 +1,350 @@
+package mlutil
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IDXType is the type of the values of an IDX file.
+type IDXType byte
+
+const (
+	IDXUint8   IDXType = 0x08
+	IDXInt8    IDXType = 0x09
+	IDXInt16   IDXType = 0x0B
+	IDXInt32   IDXType = 0x0C
+	IDXFloat32 IDXType = 0x0D
+	IDXFloat64 IDXType = 0x0E
+)
+
+// Size returns the number of bytes of a value of type t, or 0 if t is not a valid type.
+func (t IDXType) Size() int {
+	switch t {
+	case IDXUint8, IDXInt8:
+		return 1
+	case IDXInt16:
+		return 2
+	case IDXInt32, IDXFloat32:
+		return 4
+	case IDXFloat64:
+		return 8
+	}
+	return 0
+}
+
+// IDX is an array of any rank read from or written to an IDX file, the format of the MNIST and Fashion-MNIST
+// datasets. Data holds the values in row-major order as they are stored in the file, big-endian, so that a large
+// set of images takes a byte per pixel in memory.
+type IDX struct {
+	Type IDXType
+	Dims []int
+	Data []byte
+}
+
+// NewIDX returns an array of the given type and dimensions holding values, which are converted to the type.
+func NewIDX(t IDXType, dims []int, values []float64) (*IDX, error) {
+	x := &IDX{Type: t, Dims: dims}
+	if err := x.check(); err != nil {
+		return nil, err
+	}
+	if len(values) != x.Len() {
+		return nil, fmt.Errorf("mlutil: %d values for IDX dimensions %v", len(values), dims)
+	}
+	x.Data = make([]byte, len(values)*t.Size())
+	for i, v := range values {
+		x.Set(i, v)
+	}
+	return x, nil
+}
+
+// check checks the type and dimensions of x, and that the size of its data in bytes is an int.
+func (x *IDX) check() error {
+	if x.Type.Size() == 0 {
+		return fmt.Errorf("mlutil: invalid IDX type %#x", byte(x.Type))
+	}
+	if len(x.Dims) == 0 || len(x.Dims) > 255 {
+		return fmt.Errorf("mlutil: invalid IDX rank %d", len(x.Dims))
+	}
+	size := x.Type.Size()
+	for _, d := range x.Dims {
+		if d < 0 || d > math.MaxInt32 {
+			return fmt.Errorf("mlutil: invalid IDX dimension %d", d)
+		}
+		if d != 0 && size > math.MaxInt/d {
+			return fmt.Errorf("mlutil: IDX dimensions %v are too large", x.Dims)
+		}
+		size *= d
+	}
+	return nil
+}
+
+// Len returns the number of values of the array.
+func (x *IDX) Len() int {
+	n := 1
+	for _, d := range x.Dims {
+		n *= d
+	}
+	return n
+}
+
+// At returns value i of the array in row-major order.
+func (x *IDX) At(i int) float64 {
+	b := x.Data[i*x.Type.Size():]
+	switch x.Type {
+	case IDXUint8:
+		return float64(b[0])
+	case IDXInt8:
+		return float64(int8(b[0]))
+	case IDXInt16:
+		return float64(int16(binary.BigEndian.Uint16(b)))
+	case IDXInt32:
+		return float64(int32(binary.BigEndian.Uint32(b)))
+	case IDXFloat32:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	default:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+}
+
+// Set sets value i of the array in row-major order to v, converted to the type of the array. Values out of the range
+// of an integer type are clamped to it.
+func (x *IDX) Set(i int, v float64) {
+	b := x.Data[i*x.Type.Size():]
+	switch x.Type {
+	case IDXUint8:
+		b[0] = uint8(clamp(v, 0, math.MaxUint8))
+	case IDXInt8:
+		b[0] = uint8(int8(clamp(v, math.MinInt8, math.MaxInt8)))
+	case IDXInt16:
+		binary.BigEndian.PutUint16(b, uint16(int16(clamp(v, math.MinInt16, math.MaxInt16))))
+	case IDXInt32:
+		binary.BigEndian.PutUint32(b, uint32(int32(clamp(v, math.MinInt32, math.MaxInt32))))
+	case IDXFloat32:
+		binary.BigEndian.PutUint32(b, math.Float32bits(float32(v)))
+	default:
+		binary.BigEndian.PutUint64(b, math.Float64bits(v))
+	}
+}
+
+// Floats returns all the values of the array in row-major order.
+func (x *IDX) Floats() []float64 {
+	ret := make([]float64, x.Len(), x.Len())
+	for i := range ret {
+		ret[i] = x.At(i)
+	}
+	return ret
+}
+
+// IDXReader reads the values of an IDX file a record at a time, where a record is a slice of the array along its
+// first dimension, such as an image of a set of images.
+type IDXReader struct {
+	Type IDXType
+	Dims []int
+
+	r *bufio.Reader
+}
+
+// NewIDXReader reads the header of the IDX data in r, which may be gzipped.
+func NewIDXReader(r io.Reader) (*IDXReader, error) {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(2); err == nil && b[0] == 0x1f && b[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, fmt.Errorf("mlutil: reading IDX header: %v", err)
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, errors.New("mlutil: not an IDX file")
+	}
+	ret := &IDXReader{Type: IDXType(magic[2]), Dims: make([]int, magic[3]), r: br}
+	for i := range ret.Dims {
+		var d uint32
+		if err := binary.Read(br, binary.BigEndian, &d); err != nil {
+			return nil, fmt.Errorf("mlutil: reading IDX header: %v", err)
+		}
+		ret.Dims[i] = int(d)
+	}
+	x := IDX{Type: ret.Type, Dims: ret.Dims}
+	if err := x.check(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// RecordSize returns the number of bytes of a record.
+func (r *IDXReader) RecordSize() int {
+	n := r.Type.Size()
+	for _, d := range r.Dims[1:] {
+		n *= d
+	}
+	return n
+}
+
+// ReadRecord reads the next record into buf, which must be RecordSize bytes long.
+func (r *IDXReader) ReadRecord(buf []byte) error {
+	_, err := io.ReadFull(r.r, buf)
+	return err
+}
+
+// Skip discards the next n records.
+func (r *IDXReader) Skip(n int) error {
+	_, err := r.r.Discard(n * r.RecordSize())
+	return err
+}
+
+// ReadIDX reads a whole IDX array from r, which may be gzipped.
+func ReadIDX(r io.Reader) (*IDX, error) {
+	ir, err := NewIDXReader(r)
+	if err != nil {
+		return nil, err
+	}
+	x := &IDX{Type: ir.Type, Dims: ir.Dims}
+	// the buffer grows with the data read rather than being allocated from the header, which may be corrupt
+	n := x.Len() * x.Type.Size()
+	if x.Data, err = io.ReadAll(io.LimitReader(ir.r, int64(n))); err != nil {
+		return nil, fmt.Errorf("mlutil: reading IDX data: %v", err)
+	}
+	if len(x.Data) != n {
+		return nil, fmt.Errorf("mlutil: IDX header declares %d bytes of data, found %d", n, len(x.Data))
+	}
+	return x, nil
+}
+
+// LoadIDX reads the IDX file at path, which may be gzipped.
+func LoadIDX(path string) (*IDX, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x, err := ReadIDX(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s)", err, path)
+	}
+	return x, nil
+}
+
+// WriteIDX writes x to w in the IDX format, uncompressed.
+func WriteIDX(w io.Writer, x *IDX) error {
+	if err := x.check(); err != nil {
+		return err
+	}
+	if len(x.Data) != x.Len()*x.Type.Size() {
+		return fmt.Errorf("mlutil: %d bytes of data for IDX dimensions %v", len(x.Data), x.Dims)
+	}
+	header := []byte{0, 0, byte(x.Type), byte(len(x.Dims))}
+	for _, d := range x.Dims {
+		header = append(header, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(header[len(header)-4:], uint32(d))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(x.Data)
+	return err
+}
+
+// SaveIDX writes x to the file at path, creating any missing directories. The file is gzipped if path ends in
+// ".gz".
+func SaveIDX(path string, x *IDX) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := io.Writer(f)
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	err = WriteIDX(w, x)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// MNISTFiles returns the image and label files of a split of the MNIST style dataset in dir, as written by
+// download-fashion-mnist.sh: "train" for the 60,000 training images and "t10k" for the 10,000 test images.
+func MNISTFiles(dir, split string) (images, labels string, err error) {
+	switch split {
+	case "train":
+		return filepath.Join(dir, "images.gz"), filepath.Join(dir, "labels.gz"), nil
+	case "t10k":
+		return filepath.Join(dir, "t10k-images.gz"), filepath.Join(dir, "t10k-labels.gz"), nil
+	}
+	return "", "", fmt.Errorf("mlutil: unknown MNIST split %q", split)
+}
+
+// LoadMNIST reads a split of the MNIST style dataset in dir, as named by MNISTFiles.
+func LoadMNIST(dir, split string) (*ImageSet, error) {
+	images, labels, err := MNISTFiles(dir, split)
+	if err != nil {
+		return nil, err
+	}
+	return LoadImageSet(images, labels)
+}
+
+// LoadImageSet reads an ImageSet from an IDX file of unsigned byte images of rank 3 and an IDX file of unsigned byte
+// labels of rank 1.
+func LoadImageSet(imagesPath, labelsPath string) (*ImageSet, error) {
+	images, err := LoadIDX(imagesPath)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := LoadIDX(labelsPath)
+	if err != nil {
+		return nil, err
+	}
+	if images.Type != IDXUint8 || len(images.Dims) != 3 {
+		return nil, fmt.Errorf("mlutil: %s does not hold unsigned byte images", imagesPath)
+	}
+	if labels.Type != IDXUint8 || len(labels.Dims) != 1 {
+		return nil, fmt.Errorf("mlutil: %s does not hold unsigned byte labels", labelsPath)
+	}
+	l := make([]int, labels.Len(), labels.Len())
+	for i := range l {
+		l[i] = int(labels.Data[i])
+	}
+	return NewImageSet(images.Dims[1], images.Dims[2], images.Data, l)
+}
+
+// SaveIDX writes the images and labels of the set to IDX files of unsigned bytes, eg. to export augmented or
+// synthetic data. The files are gzipped if their names end in ".gz".
+func (s *ImageSet) SaveIDX(imagesPath, labelsPath string) error {
+	labels := make([]byte, s.Len(), s.Len())
+	for i, l := range s.Labels {
+		if l < 0 || l > math.MaxUint8 {
+			return fmt.Errorf("mlutil: label %d of image %d does not fit in a byte", l, i)
+		}
+		labels[i] = byte(l)
+	}
+	if err := SaveIDX(imagesPath, &IDX{Type: IDXUint8, Dims: []int{s.Len(), s.Rows, s.Cols}, Data: s.Pixels}); err != nil {
+		return err
+	}
+	return SaveIDX(labelsPath, &IDX{Type: IDXUint8, Dims: []int{s.Len()}, Data: labels})
+}
+
+// clamp returns v limited to the range [min, max].
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
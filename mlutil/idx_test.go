@@ -0,0 +1,175 @@
+package mlutil
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestIDXRoundTrip(t *testing.T) {
+	values := []float64{-1.5, 0, 2.7, 300, -200, 1}
+	tests := []struct {
+		name string
+		t    IDXType
+		dims []int
+		// want are values after conversion to the type
+		want []float64
+	}{
+		{"uint8", IDXUint8, []int{6}, []float64{0, 0, 2, 255, 0, 1}},
+		{"int8", IDXInt8, []int{2, 3}, []float64{-1, 0, 2, 127, -128, 1}},
+		{"int16", IDXInt16, []int{3, 2}, []float64{-1, 0, 2, 300, -200, 1}},
+		{"int32", IDXInt32, []int{1, 2, 3}, []float64{-1, 0, 2, 300, -200, 1}},
+		{"float32", IDXFloat32, []int{6, 1}, []float64{-1.5, 0, float64(float32(2.7)), 300, -200, 1}},
+		{"float64", IDXFloat64, []int{6}, values},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := NewIDX(tt.t, tt.dims, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := WriteIDX(&b, x); err != nil {
+				t.Fatal(err)
+			}
+			if want := 4 + 4*len(tt.dims) + len(values)*tt.t.Size(); b.Len() != want {
+				t.Errorf("wrote %d bytes, want %d", b.Len(), want)
+			}
+			fromBuffer, err := ReadIDX(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read := []*IDX{fromBuffer}
+			// and through files, plain and gzipped
+			dir := t.TempDir()
+			for _, name := range []string{"values.idx", "sub/values.idx.gz"} {
+				path := filepath.Join(dir, name)
+				if err := SaveIDX(path, x); err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := LoadIDX(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				read = append(read, loaded)
+			}
+			for _, got := range read {
+				if got.Type != tt.t || len(got.Dims) != len(tt.dims) {
+					t.Fatalf("read type %#x with dimensions %v, want %#x with %v", byte(got.Type), got.Dims, byte(tt.t), tt.dims)
+				}
+				for i := range tt.dims {
+					if got.Dims[i] != tt.dims[i] {
+						t.Errorf("read dimensions %v, want %v", got.Dims, tt.dims)
+					}
+				}
+				floats := got.Floats()
+				for i := range tt.want {
+					if floats[i] != tt.want[i] {
+						t.Errorf("read values %v, want %v", floats, tt.want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestIDXHeader(t *testing.T) {
+	x, err := NewIDX(IDXUint8, []int{2, 3}, []float64{1, 2, 3, 4, 5, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteIDX(&b, x); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 0, 0x08, 2, 0, 0, 0, 2, 0, 0, 0, 3, 1, 2, 3, 4, 5, 6}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("wrote % x, want % x", b.Bytes(), want)
+	}
+
+	r, err := NewIDXReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RecordSize() != 3 {
+		t.Errorf("RecordSize() = %d, want 3", r.RecordSize())
+	}
+	if err := r.Skip(1); err != nil {
+		t.Fatal(err)
+	}
+	record := make([]byte, r.RecordSize())
+	if err := r.ReadRecord(record); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(record, []byte{4, 5, 6}) {
+		t.Errorf("second record % x, want 04 05 06", record)
+	}
+	if err := r.ReadRecord(record); err == nil {
+		t.Error("ReadRecord() past the last record succeeded, want an error")
+	}
+}
+
+func TestIDXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not IDX", []byte{1, 2, 0x08, 1, 0, 0, 0, 1, 7}},
+		{"invalid type", []byte{0, 0, 0x07, 1, 0, 0, 0, 1, 7}},
+		{"rank 0", []byte{0, 0, 0x08, 0}},
+		{"truncated header", []byte{0, 0, 0x08, 2, 0, 0, 0, 1}},
+		{"truncated data", []byte{0, 0, 0x0C, 1, 0, 0, 0, 2, 0, 0, 0, 1}},
+		// without allocating the 2GB the header declares
+		{"huge dimension", []byte{0, 0, 0x08, 1, 0x7f, 0xff, 0xff, 0xff, 1, 2, 3}},
+		{"overflowing dimensions", []byte{0, 0, 0x0E, 3, 0x7f, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if _, err := ReadIDX(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: ReadIDX() succeeded, want an error", tt.name)
+		}
+	}
+	overflow := []byte{0, 0, 0x08, 3, 0x7f, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff}
+	if _, err := NewIDXReader(bytes.NewReader(overflow)); err == nil {
+		t.Error("NewIDXReader() of a header whose size overflows succeeded, want an error")
+	}
+	if _, err := NewIDX(IDXType(0x07), []int{1}, []float64{1}); err == nil {
+		t.Error("NewIDX() with an invalid type succeeded, want an error")
+	}
+	if _, err := NewIDX(IDXUint8, []int{2, 2}, []float64{1, 2, 3}); err == nil {
+		t.Error("NewIDX() with too few values succeeded, want an error")
+	}
+	if err := WriteIDX(&bytes.Buffer{}, &IDX{Type: IDXInt16, Dims: []int{2}, Data: []byte{1, 2}}); err == nil {
+		t.Error("WriteIDX() with too little data succeeded, want an error")
+	}
+}
+
+func TestImageSetSaveIDX(t *testing.T) {
+	pixels := []uint8{0, 1, 2, 3, 4, 5, 250, 251, 252, 253, 254, 255}
+	s, err := NewImageSet(2, 3, pixels, []int{7, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	images, labels := filepath.Join(dir, "images.gz"), filepath.Join(dir, "labels.gz")
+	if err := s.SaveIDX(images, labels); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadImageSet(images, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Rows != 2 || got.Cols != 3 || !bytes.Equal(got.Pixels, pixels) || len(got.Labels) != 2 || got.Labels[0] != 7 || got.Labels[1] != 9 {
+		t.Errorf("loaded %dx%d images %v with labels %v, want 2x3 images %v with labels [7 9]", got.Rows, got.Cols, got.Pixels, got.Labels, pixels)
+	}
+
+	s.Labels[1] = 256
+	if err := s.SaveIDX(images, labels); err == nil {
+		t.Error("SaveIDX() with a label of 256 succeeded, want an error")
+	}
+	// labels are not images
+	if _, err := LoadImageSet(labels, labels); err == nil {
+		t.Error("LoadImageSet() of a label file as images succeeded, want an error")
+	}
+}
@@ -0,0 +1,249 @@
+package mlutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/datastream/libsvm"
+	"github.com/patrikeh/go-deep/training"
+	mnist "github.com/petar/GoMNIST"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"sort"
+)
+
+// ImageSet is a dense set of greyscale images of the same size and their class labels. The pixels of all images are
+// kept in a single slice, image after image and row after row, so that Image returns a view of an image rather than
+// a copy.
+type ImageSet struct {
+	Rows, Cols int
+	Pixels     []uint8
+	Labels     []int
+}
+
+// NewImageSet returns a set of the images of rows x cols pixels in pixels, with the given labels.
+func NewImageSet(rows, cols int, pixels []uint8, labels []int) (*ImageSet, error) {
+	if rows < 1 || cols < 1 {
+		return nil, fmt.Errorf("mlutil: invalid image size %dx%d", rows, cols)
+	}
+	if len(pixels) != len(labels)*rows*cols {
+		return nil, fmt.Errorf("mlutil: %d pixels for %d images of %dx%d pixels", len(pixels), len(labels), rows, cols)
+	}
+	return &ImageSet{Rows: rows, Cols: cols, Pixels: pixels, Labels: labels}, nil
+}
+
+// MNISTSetToImageSet copies at most maxExamples images of the set to an ImageSet.
+func MNISTSetToImageSet(st *mnist.Set, maxExamples int) (*ImageSet, error) {
+	if st == nil {
+		return nil, errors.New("mlutil: nil MNIST set")
+	}
+	n := maxExamples
+	if n > len(st.Images) {
+		n = len(st.Images)
+	}
+	size := st.NRow * st.NCol
+	pixels := make([]uint8, n*size, n*size)
+	labels := make([]int, n, n)
+	for i := 0; i < n; i++ {
+		if len(st.Images[i]) != size {
+			return nil, fmt.Errorf("mlutil: image %d has %d pixels, expected %d", i, len(st.Images[i]), size)
+		}
+		copy(pixels[i*size:], st.Images[i])
+		labels[i] = int(st.Labels[i])
+	}
+	return NewImageSet(st.NRow, st.NCol, pixels, labels)
+}
+
+// Len returns the number of images in the set.
+func (s *ImageSet) Len() int {
+	return len(s.Labels)
+}
+
+// Size returns the number of pixels of an image.
+func (s *ImageSet) Size() int {
+	return s.Rows * s.Cols
+}
+
+// Image returns the pixels of image i. The slice shares the memory of the set.
+func (s *ImageSet) Image(i int) []uint8 {
+	size := s.Size()
+	return s.Pixels[i*size : (i+1)*size : (i+1)*size]
+}
+
+// At returns the pixel of image i at the given row and column.
+func (s *ImageSet) At(i, row, col int) uint8 {
+	return s.Pixels[i*s.Size()+row*s.Cols+col]
+}
+
+// Slice returns the images i to j-1 of the set, without copying them. i and j are clamped to the range [0, Len], so
+// the set returned is empty if i is past the end or j is not after i.
+func (s *ImageSet) Slice(i, j int) *ImageSet {
+	clamp := func(k int) int {
+		if k < 0 {
+			return 0
+		}
+		if k > s.Len() {
+			return s.Len()
+		}
+		return k
+	}
+	i, j = clamp(i), clamp(j)
+	if j < i {
+		j = i
+	}
+	size := s.Size()
+	return &ImageSet{Rows: s.Rows, Cols: s.Cols, Pixels: s.Pixels[i*size : j*size], Labels: s.Labels[i:j]}
+}
+
+// Subset returns a new set of copies of the images with the given indices.
+func (s *ImageSet) Subset(indices []int) *ImageSet {
+	size := s.Size()
+	ret := &ImageSet{Rows: s.Rows, Cols: s.Cols, Pixels: make([]uint8, len(indices)*size), Labels: make([]int, len(indices))}
+	for j, i := range indices {
+		copy(ret.Pixels[j*size:], s.Image(i))
+		ret.Labels[j] = s.Labels[i]
+	}
+	return ret
+}
+
+// Split splits the set into training and validation subsets like SplitWith. If cfg.Stratify is not empty, the split
+// is stratified by label.
+func (s *ImageSet) Split(trainFraction float64, cfg SplitConfig) (training, validation *ImageSet, err error) {
+	fractions := []float64{trainFraction, 1 - trainFraction}
+	if err := checkFractions(fractions); err != nil {
+		return nil, nil, err
+	}
+	var strata [][]int
+	if cfg.Stratify == "" {
+		all := make([]int, s.Len(), s.Len())
+		for i := range all {
+			all[i] = i
+		}
+		strata = [][]int{all}
+	} else {
+		byLabel := make(map[int][]int)
+		for i, l := range s.Labels {
+			byLabel[l] = append(byLabel[l], i)
+		}
+		labels := make([]int, 0, len(byLabel))
+		for l := range byLabel {
+			labels = append(labels, l)
+		}
+		sort.Ints(labels)
+		for _, l := range labels {
+			strata = append(strata, byLabel[l])
+		}
+	}
+	parts := splitStrata(strata, fractions, cfg.rand())
+	return s.Subset(parts[0]), s.Subset(parts[1]), nil
+}
+
+// Floats returns the images with their pixels mapped onto the range [0,1], as inputs for goml and go-deep.
+func (s *ImageSet) Floats() [][]float64 {
+	ret := make([][]float64, s.Len(), s.Len())
+	for i := range ret {
+		ret[i] = NormalizeBytes(s.Image(i))
+	}
+	return ret
+}
+
+// MeanStd returns the mean and standard deviation of all the pixels of the set, mapped onto the range [0,1].
+func (s *ImageSet) MeanStd() (mean, std float64) {
+	if len(s.Pixels) == 0 {
+		return 0, 0
+	}
+	var sum, sumSquares float64
+	for _, p := range s.Pixels {
+		f := float64(p) / 255.
+		sum += f
+		sumSquares += f * f
+	}
+	n := float64(len(s.Pixels))
+	mean = sum / n
+	return mean, math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+}
+
+// Standardised returns the images with their pixels mapped onto the range [0,1] and then standardised with the given
+// mean and standard deviation, usually those returned by MeanStd for the training set.
+func (s *ImageSet) Standardised(mean, std float64) [][]float64 {
+	if std == 0 {
+		std = 1
+	}
+	ret := s.Floats()
+	for i := range ret {
+		for j := range ret[i] {
+			ret[i][j] = (ret[i][j] - mean) / std
+		}
+	}
+	return ret
+}
+
+// Matrix returns the images as the rows of a matrix, with their pixels mapped onto the range [0,1], for use with
+// gonum, eg. for principal component analysis.
+func (s *ImageSet) Matrix() *mat.Dense {
+	if s.Len() == 0 {
+		return &mat.Dense{}
+	}
+	data := make([]float64, len(s.Pixels), len(s.Pixels))
+	for i, p := range s.Pixels {
+		data[i] = float64(p) / 255.
+	}
+	return mat.NewDense(s.Len(), s.Size(), data)
+}
+
+// Ints returns the raw pixel values (0-255) of the images, as expected by the Python models in Chapter05.
+func (s *ImageSet) Ints() [][]int {
+	ret := make([][]int, s.Len(), s.Len())
+	for i := range ret {
+		img := s.Image(i)
+		ret[i] = make([]int, len(img), len(img))
+		for j := range img {
+			ret[i][j] = int(img[j])
+		}
+	}
+	return ret
+}
+
+// LabelFloats returns the labels as floats, as the targets of goml, libsvm and the Model adapters.
+func (s *ImageSet) LabelFloats() []float64 {
+	ret := make([]float64, s.Len(), s.Len())
+	for i, l := range s.Labels {
+		ret[i] = float64(l)
+	}
+	return ret
+}
+
+// OneHotLabels returns the labels as one-hot vectors of the given number of classes, as the responses of go-deep.
+func (s *ImageSet) OneHotLabels(classes int) ([][]float64, error) {
+	ret := make([][]float64, s.Len(), s.Len())
+	for i, l := range s.Labels {
+		if l < 0 || l >= classes {
+			return nil, fmt.Errorf("mlutil: label %d of image %d is not one of %d classes", l, i, classes)
+		}
+		ret[i] = make([]float64, classes, classes)
+		ret[i][l] = 1
+	}
+	return ret, nil
+}
+
+// Examples returns the images and their one-hot labels as go-deep training examples.
+func (s *ImageSet) Examples(classes int) (training.Examples, error) {
+	responses, err := s.OneHotLabels(classes)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(training.Examples, s.Len(), s.Len())
+	for i := range ret {
+		ret[i] = training.Example{Input: NormalizeBytes(s.Image(i)), Response: responses[i]}
+	}
+	return ret, nil
+}
+
+// SVMProblem returns the images, with their pixels mapped onto the range [0,1], and their labels as a libsvm
+// problem.
+func (s *ImageSet) SVMProblem() libsvm.SVMProblem {
+	ret := libsvm.SVMProblem{L: s.Len(), Y: s.LabelFloats(), X: make([][]libsvm.SVMNode, s.Len(), s.Len())}
+	for i := range ret.X {
+		ret.X[i] = FloatsToSVMNode(NormalizeBytes(s.Image(i)))
+	}
+	return ret
+}
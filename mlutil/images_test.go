@@ -0,0 +1,136 @@
+package mlutil
+
+import (
+	mnist "github.com/petar/GoMNIST"
+	"math"
+	"testing"
+)
+
+// stripes returns a set of n 2x2 images, image i having every pixel equal to i and label i%2.
+func stripes(n int) *ImageSet {
+	pixels := make([]uint8, 4*n)
+	labels := make([]int, n)
+	for i := range labels {
+		for j := 0; j < 4; j++ {
+			pixels[4*i+j] = uint8(i)
+		}
+		labels[i] = i % 2
+	}
+	s, _ := NewImageSet(2, 2, pixels, labels)
+	return s
+}
+
+func TestImageSet(t *testing.T) {
+	s := stripes(10)
+	if s.Len() != 10 || s.Size() != 4 || s.At(3, 1, 1) != 3 {
+		t.Fatalf("set of %d images of %d pixels with pixel %d, want 10 of 4 with pixel 3", s.Len(), s.Size(), s.At(3, 1, 1))
+	}
+	// Image shares the pixels of the set but cannot grow into the next image
+	img := s.Image(2)
+	img[0] = 200
+	if s.At(2, 0, 0) != 200 || cap(img) != 4 {
+		t.Errorf("Image() is not a view of the set of capacity 4")
+	}
+	img[0] = 2
+
+	sub := s.Subset([]int{7, 1})
+	sub.Pixels[0] = 0
+	if sub.Len() != 2 || sub.Labels[0] != 1 || sub.At(1, 0, 1) != 1 || s.At(7, 0, 0) != 7 {
+		t.Errorf("Subset() = %+v, want copies of images 7 and 1", sub)
+	}
+
+	slices := []struct {
+		i, j, want int
+	}{
+		{2, 5, 3},
+		{8, 1000, 2},
+		{-3, 2, 2},
+		{12, 20, 0},
+		{6, 4, 0},
+	}
+	for _, tt := range slices {
+		sl := s.Slice(tt.i, tt.j)
+		if sl.Len() != tt.want || len(sl.Pixels) != tt.want*4 {
+			t.Errorf("Slice(%d, %d) has %d images and %d pixels, want %d images", tt.i, tt.j, sl.Len(), len(sl.Pixels), tt.want)
+		}
+	}
+	if sl := s.Slice(8, 1000); sl.At(0, 0, 0) != 8 {
+		t.Errorf("Slice(8, 1000) starts with image %d, want 8", sl.At(0, 0, 0))
+	}
+
+	training, validation, err := s.Split(0.6, SplitConfig{Seed: 1, Stratify: "label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones := 0
+	for _, l := range training.Labels {
+		ones += l
+	}
+	if training.Len() != 6 || validation.Len() != 4 || ones != 3 {
+		t.Errorf("split of %d and %d images with %d ones in training, want 6 and 4 with 3", training.Len(), validation.Len(), ones)
+	}
+
+	if _, err := NewImageSet(2, 2, make([]uint8, 7), []int{0, 1}); err == nil {
+		t.Error("NewImageSet() with a missing pixel succeeded, want an error")
+	}
+	if _, err := NewImageSet(0, 2, nil, nil); err == nil {
+		t.Error("NewImageSet() of images without rows succeeded, want an error")
+	}
+}
+
+func TestImageSetConversions(t *testing.T) {
+	s, err := NewImageSet(1, 2, []uint8{0, 255, 51, 102}, []int{2, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := s.Floats(); f[0][1] != 1 || f[1][0] != 0.2 {
+		t.Errorf("Floats() = %v, want [[0 1] [0.2 0.4]]", f)
+	}
+	// the pixels are 0, 1, 0.2 and 0.4
+	mean, std := s.MeanStd()
+	if !closeTo(mean, 0.4) || !closeTo(std, math.Sqrt(0.14)) {
+		t.Errorf("MeanStd() = %v, %v, want 0.4, %v", mean, std, math.Sqrt(0.14))
+	}
+	if st := s.Standardised(mean, std); !closeTo(st[0][0], -0.4/std) {
+		t.Errorf("Standardised() = %v, want a first pixel of %v", st, -0.4/std)
+	}
+	if m := s.Matrix(); m.At(1, 1) != 0.4 {
+		t.Errorf("Matrix() has %v at (1, 1), want 0.4", m.At(1, 1))
+	}
+	if ints := s.Ints(); ints[1][1] != 102 {
+		t.Errorf("Ints() = %v, want the raw pixels", ints)
+	}
+	examples, err := s.Examples(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := examples[0].Response; r[0] != 0 || r[2] != 1 {
+		t.Errorf("response of the first example %v, want [0 0 1]", r)
+	}
+	if _, err := s.OneHotLabels(2); err == nil {
+		t.Error("OneHotLabels() with a label beyond the classes succeeded, want an error")
+	}
+	if p := s.SVMProblem(); p.L != 2 || p.Y[0] != 2 {
+		t.Errorf("SVMProblem() of %d examples labelled %v, want 2 labelled [2 0]", p.L, p.Y)
+	}
+}
+
+func TestMNISTSetToImageSet(t *testing.T) {
+	set := &mnist.Set{
+		NRow:   1,
+		NCol:   2,
+		Images: []mnist.RawImage{{1, 2}, {3, 4}, {5, 6}},
+		Labels: []mnist.Label{7, 8, 9},
+	}
+	s, err := MNISTSetToImageSet(set, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 2 || s.At(1, 0, 1) != 4 || s.Labels[1] != 8 {
+		t.Errorf("MNISTSetToImageSet() = %+v, want the first two images", s)
+	}
+	set.Images[0] = mnist.RawImage{1}
+	if _, err := MNISTSetToImageSet(set, 3); err == nil {
+		t.Error("MNISTSetToImageSet() of an image of the wrong size succeeded, want an error")
+	}
+}
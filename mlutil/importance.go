@@ -0,0 +1,113 @@
+package mlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gonum.org/v1/gonum/stat"
+	"math/rand"
+	"sort"
+)
+
+// FeatureImportance is the importance of a feature, with its standard deviation over repeated measurements if it
+// was measured more than once.
+type FeatureImportance struct {
+	Feature    string
+	Importance float64
+	StdDev     float64
+}
+
+// ImportanceReport lists the importance of every feature of a model, most important first.
+type ImportanceReport []FeatureImportance
+
+// NewImportanceReport ranks the importances of the named features. stdDevs may be nil.
+func NewImportanceReport(names []string, importances, stdDevs []float64) (ImportanceReport, error) {
+	if len(names) != len(importances) || (stdDevs != nil && len(stdDevs) != len(importances)) {
+		return nil, fmt.Errorf("mlutil: %d feature names for %d importances", len(names), len(importances))
+	}
+	r := make(ImportanceReport, len(names), len(names))
+	for j, name := range names {
+		r[j] = FeatureImportance{Feature: name, Importance: importances[j]}
+		if stdDevs != nil {
+			r[j].StdDev = stdDevs[j]
+		}
+	}
+	sort.SliceStable(r, func(i, j int) bool { return r[i].Importance > r[j].Importance })
+	return r, nil
+}
+
+// String formats the report as a table with one row per feature.
+func (r ImportanceReport) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%-20s %12s %10s\n", "Feature", "Importance", "StdDev")
+	for _, f := range r {
+		fmt.Fprintf(&b, "%-20s %12.4f %10.4f\n", f.Feature, f.Importance, f.StdDev)
+	}
+	return b.String()
+}
+
+// PermutationImportance measures how much the score of the fitted model m on x and y gets worse when the values of
+// each feature are shuffled across the examples, which breaks its relation to the target. The importance is the
+// mean loss of score over repeats shuffles; it is positive for features the model relies on whether scorer is a
+// score, or an error such as MeanSquaredError with minimise set. Unlike impurity-based importances it works for any
+// model, and on held out examples it measures what the model learned that generalises.
+func PermutationImportance(m Model, x [][]float64, y []float64, names []string, scorer Scorer, minimise bool, repeats int, seed int64) (ImportanceReport, error) {
+	if len(x) == 0 {
+		return nil, errors.New("mlutil: no examples")
+	}
+	if repeats < 1 {
+		repeats = 1
+	}
+	if names == nil {
+		names = make([]string, len(x[0]), len(x[0]))
+		for j := range names {
+			names[j] = fmt.Sprintf("x[%d]", j)
+		}
+	}
+	score := func(x [][]float64) (float64, error) {
+		pred := make([]float64, len(x), len(x))
+		for i := range x {
+			var err error
+			if pred[i], err = m.Predict(x[i]); err != nil {
+				return 0, err
+			}
+		}
+		return scorer(y, pred), nil
+	}
+	baseline, err := score(x)
+	if err != nil {
+		return nil, err
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	shuffled := make([][]float64, len(x), len(x))
+	for i := range x {
+		shuffled[i] = append([]float64(nil), x[i]...)
+	}
+	means := make([]float64, len(names), len(names))
+	stdDevs := make([]float64, len(names), len(names))
+	losses := make([]float64, repeats, repeats)
+	for j := range names {
+		for r := range losses {
+			perm := rnd.Perm(len(x))
+			for i := range x {
+				shuffled[i][j] = x[perm[i]][j]
+			}
+			s, err := score(shuffled)
+			if err != nil {
+				return nil, err
+			}
+			if losses[r] = baseline - s; minimise {
+				losses[r] = -losses[r]
+			}
+		}
+		for i := range x {
+			shuffled[i][j] = x[i][j]
+		}
+		means[j], stdDevs[j] = stat.MeanStdDev(losses, nil)
+		if repeats == 1 {
+			stdDevs[j] = 0
+		}
+	}
+	return NewImportanceReport(names, means, stdDevs)
+}
@@ -0,0 +1,95 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/cdipaolo/goml/cluster"
+)
+
+// KMeansModel adapts goml's K-Means clustering to the Model interface. The labels passed to Fit are ignored and
+// Predict returns the index of the nearest centroid.
+type KMeansModel struct {
+	K             int
+	MaxIterations int
+
+	model *cluster.KMeans
+}
+
+// NewKMeansModel returns an unfitted K-Means clustering of k clusters, in the same order as cluster.NewKMeans.
+func NewKMeansModel(k, maxIterations int) *KMeansModel {
+	return &KMeansModel{K: k, MaxIterations: maxIterations}
+}
+
+// WrapKMeans returns an adapter for a clustering that has already been learned.
+func WrapKMeans(model *cluster.KMeans) *KMeansModel {
+	return &KMeansModel{K: len(model.Centroids), MaxIterations: model.MaxIterations(), model: model}
+}
+
+// Fit clusters x. y is ignored and may be nil.
+func (m *KMeansModel) Fit(x [][]float64, y []float64) error {
+	if len(x) == 0 {
+		return errors.New("mlutil: no training examples")
+	}
+	model := cluster.NewKMeans(m.K, m.MaxIterations, x)
+	if err := model.Learn(); err != nil {
+		return err
+	}
+	m.model = model
+	return nil
+}
+
+// Predict returns the index of the centroid nearest to x.
+func (m *KMeansModel) Predict(x []float64) (float64, error) {
+	if m.model == nil {
+		return 0, ErrNotFitted
+	}
+	p, err := m.model.Predict(x)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// PredictProba always returns ErrNotClassifier.
+func (m *KMeansModel) PredictProba(x []float64) ([]float64, error) {
+	return nil, ErrNotClassifier
+}
+
+// Centroids returns the centre of each cluster, or nil if the model has not been fitted.
+func (m *KMeansModel) Centroids() [][]float64 {
+	if m.model == nil {
+		return nil
+	}
+	return m.model.Centroids
+}
+
+func (m *KMeansModel) modelKind() (string, string) {
+	return "kmeans", "goml"
+}
+
+func (m *KMeansModel) marshalModel() ([]byte, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(struct {
+		MaxIterations int         `json:"max_iterations"`
+		Centroids     [][]float64 `json:"centroids"`
+	}{m.MaxIterations, m.model.Centroids})
+}
+
+func (m *KMeansModel) unmarshalModel(data []byte) error {
+	var s struct {
+		MaxIterations int         `json:"max_iterations"`
+		Centroids     [][]float64 `json:"centroids"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s.Centroids) == 0 || len(s.Centroids[0]) == 0 {
+		return errors.New("no centroids")
+	}
+	model := cluster.NewKMeans(len(s.Centroids), s.MaxIterations, nil)
+	model.Centroids = s.Centroids
+	*m = KMeansModel{K: len(s.Centroids), MaxIterations: s.MaxIterations, model: model}
+	return nil
+}
@@ -0,0 +1,259 @@
+package mlutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Scorer computes a single score, such as the mean squared error or the accuracy, from the true values and the
+// model's predictions. The slices must have the same length.
+type Scorer func(yTrue, yPred []float64) float64
+
+// MeanSquaredError returns the mean of the squared differences between yTrue and yPred.
+func MeanSquaredError(yTrue, yPred []float64) float64 {
+	errors := make([]float64, len(yTrue), len(yTrue))
+	for i := range yTrue {
+		errors[i] = (yPred[i] - yTrue[i]) * (yPred[i] - yTrue[i])
+	}
+	return stat.Mean(errors, nil)
+}
+
+// RootMeanSquaredError returns the square root of the mean squared error, in the units of the target.
+func RootMeanSquaredError(yTrue, yPred []float64) float64 {
+	return math.Sqrt(MeanSquaredError(yTrue, yPred))
+}
+
+// MeanAbsoluteError returns the mean of the absolute differences between yTrue and yPred.
+func MeanAbsoluteError(yTrue, yPred []float64) float64 {
+	errors := make([]float64, len(yTrue), len(yTrue))
+	for i := range yTrue {
+		errors[i] = math.Abs(yPred[i] - yTrue[i])
+	}
+	return stat.Mean(errors, nil)
+}
+
+// MedianAbsoluteError returns the median of the absolute differences between yTrue and yPred, which unlike the mean
+// is not dominated by a few large errors.
+func MedianAbsoluteError(yTrue, yPred []float64) float64 {
+	if len(yTrue) == 0 {
+		return math.NaN()
+	}
+	errors := make([]float64, len(yTrue), len(yTrue))
+	for i := range yTrue {
+		errors[i] = math.Abs(yPred[i] - yTrue[i])
+	}
+	sort.Float64s(errors)
+	n := len(errors)
+	if n%2 == 1 {
+		return errors[n/2]
+	}
+	return (errors[n/2-1] + errors[n/2]) / 2
+}
+
+// R2 returns the coefficient of determination: the fraction of the variance of yTrue that is explained by yPred.
+// It is 1 for perfect predictions and 0 for always predicting the mean, and can be negative for worse models.
+func R2(yTrue, yPred []float64) float64 {
+	return stat.RSquaredFrom(yPred, yTrue, nil)
+}
+
+// ExplainedVariance returns 1 - Var(yTrue - yPred) / Var(yTrue). It equals R2 when the residuals have zero mean, and
+// unlike R2 it does not penalise a constant bias in the predictions.
+func ExplainedVariance(yTrue, yPred []float64) float64 {
+	residuals := make([]float64, len(yTrue), len(yTrue))
+	for i := range yTrue {
+		residuals[i] = yTrue[i] - yPred[i]
+	}
+	_, varResiduals := stat.PopMeanVariance(residuals, nil)
+	_, varTrue := stat.PopMeanVariance(yTrue, nil)
+	return 1 - varResiduals/varTrue
+}
+
+// MeanAbsolutePercentageError returns the mean of |yTrue - yPred| / |yTrue| as a fraction (multiply by 100 for a
+// percentage). Examples with yTrue == 0 are skipped; if there are none left the result is NaN.
+func MeanAbsolutePercentageError(yTrue, yPred []float64) float64 {
+	var (
+		sum float64
+		n   int
+	)
+	for i := range yTrue {
+		if yTrue[i] == 0 {
+			continue
+		}
+		sum += math.Abs((yTrue[i] - yPred[i]) / yTrue[i])
+		n++
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+// Accuracy returns the fraction of predictions that equal the true class label.
+func Accuracy(yTrue, yPred []float64) float64 {
+	if len(yTrue) == 0 {
+		return 0
+	}
+	var correct float64
+	for i := range yTrue {
+		if yPred[i] == yTrue[i] {
+			correct++
+		}
+	}
+	return correct / float64(len(yTrue))
+}
+
+// Residual is a single row of a residuals table.
+type Residual struct {
+	Actual    float64 `json:"actual"`
+	Predicted float64 `json:"predicted"`
+	Residual  float64 `json:"residual"`
+}
+
+// RegressionReport summarises how well a regression model's predictions match the true values.
+type RegressionReport struct {
+	N        int
+	Features int
+
+	MSE               float64
+	RMSE              float64
+	MAE               float64
+	MedianAE          float64
+	R2                float64
+	AdjustedR2        float64
+	ExplainedVariance float64
+	MAPE              float64
+
+	Residuals []Residual
+}
+
+// NewRegressionReport computes the regression metrics for the predictions yPred of the true values yTrue. features
+// is the number of independent variables the model used, which is needed for the adjusted R². Metrics that are not
+// defined for the data, such as the adjusted R² with too few examples, are NaN.
+func NewRegressionReport(yTrue, yPred []float64, features int) (RegressionReport, error) {
+	var r RegressionReport
+	if len(yTrue) != len(yPred) {
+		return r, fmt.Errorf("mlutil: %d true values but %d predictions", len(yTrue), len(yPred))
+	}
+	if len(yTrue) == 0 {
+		return r, errors.New("mlutil: no predictions to report on")
+	}
+	r.N = len(yTrue)
+	r.Features = features
+	r.MSE = MeanSquaredError(yTrue, yPred)
+	r.RMSE = math.Sqrt(r.MSE)
+	r.MAE = MeanAbsoluteError(yTrue, yPred)
+	r.MedianAE = MedianAbsoluteError(yTrue, yPred)
+	r.R2 = R2(yTrue, yPred)
+	r.AdjustedR2 = math.NaN()
+	if r.N-features-1 > 0 {
+		r.AdjustedR2 = 1 - (1-r.R2)*float64(r.N-1)/float64(r.N-features-1)
+	}
+	r.ExplainedVariance = ExplainedVariance(yTrue, yPred)
+	r.MAPE = MeanAbsolutePercentageError(yTrue, yPred)
+
+	r.Residuals = make([]Residual, len(yTrue), len(yTrue))
+	for i := range yTrue {
+		r.Residuals[i] = Residual{Actual: yTrue[i], Predicted: yPred[i], Residual: yTrue[i] - yPred[i]}
+	}
+	return r, nil
+}
+
+// String formats the metrics of the report, one per line.
+func (r RegressionReport) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "N:                  %d\n", r.N)
+	fmt.Fprintf(&b, "MSE:                %5.2f\n", r.MSE)
+	fmt.Fprintf(&b, "RMSE:               %5.2f\n", r.RMSE)
+	fmt.Fprintf(&b, "MAE:                %5.2f\n", r.MAE)
+	fmt.Fprintf(&b, "Median AE:          %5.2f\n", r.MedianAE)
+	fmt.Fprintf(&b, "R²:                 %5.3f\n", r.R2)
+	fmt.Fprintf(&b, "Adjusted R²:        %5.3f\n", r.AdjustedR2)
+	fmt.Fprintf(&b, "Explained variance: %5.3f\n", r.ExplainedVariance)
+	fmt.Fprintf(&b, "MAPE:               %5.2f%%\n", 100*r.MAPE)
+	return b.String()
+}
+
+// ResidualsTable formats the first n residuals of the report as a table (all of them if n <= 0).
+func (r RegressionReport) ResidualsTable(n int) string {
+	if n <= 0 || n > len(r.Residuals) {
+		n = len(r.Residuals)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%12s %12s %12s\n", "Actual", "Predicted", "Residual")
+	for _, res := range r.Residuals[:n] {
+		fmt.Fprintf(&b, "%12.4f %12.4f %12.4f\n", res.Actual, res.Predicted, res.Residual)
+	}
+	return b.String()
+}
+
+// jsonFloat is a float64 that is written as null when it is NaN or infinite, which encoding/json refuses to encode.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, float64(f), 'g', -1, 64), nil
+}
+
+// UnmarshalJSON reads null as NaN.
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = jsonFloat(math.NaN())
+		return nil
+	}
+	return json.Unmarshal(data, (*float64)(f))
+}
+
+// Metrics returns the summary metrics of the report keyed as in its JSON form, for use as ModelMetadata.Metrics.
+// Undefined metrics are left out.
+func (r RegressionReport) Metrics() map[string]float64 {
+	return finiteMetrics(map[string]float64{
+		"mse":                r.MSE,
+		"rmse":               r.RMSE,
+		"mae":                r.MAE,
+		"median_ae":          r.MedianAE,
+		"r2":                 r.R2,
+		"adjusted_r2":        r.AdjustedR2,
+		"explained_variance": r.ExplainedVariance,
+		"mape":               r.MAPE,
+	})
+}
+
+// finiteMetrics removes the NaN and infinite values from m, which JSON cannot represent.
+func finiteMetrics(m map[string]float64) map[string]float64 {
+	for k, v := range m {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+// MarshalJSON writes the report with snake_case keys. Undefined metrics are written as null.
+func (r RegressionReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		N                 int        `json:"n"`
+		Features          int        `json:"features"`
+		MSE               jsonFloat  `json:"mse"`
+		RMSE              jsonFloat  `json:"rmse"`
+		MAE               jsonFloat  `json:"mae"`
+		MedianAE          jsonFloat  `json:"median_ae"`
+		R2                jsonFloat  `json:"r2"`
+		AdjustedR2        jsonFloat  `json:"adjusted_r2"`
+		ExplainedVariance jsonFloat  `json:"explained_variance"`
+		MAPE              jsonFloat  `json:"mape"`
+		Residuals         []Residual `json:"residuals,omitempty"`
+	}{
+		r.N, r.Features,
+		jsonFloat(r.MSE), jsonFloat(r.RMSE), jsonFloat(r.MAE), jsonFloat(r.MedianAE),
+		jsonFloat(r.R2), jsonFloat(r.AdjustedR2), jsonFloat(r.ExplainedVariance), jsonFloat(r.MAPE),
+		r.Residuals,
+	})
+}
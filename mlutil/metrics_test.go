@@ -0,0 +1,80 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// closeTo reports whether got equals want to within 1e-9, with NaN equal to NaN.
+func closeTo(got, want float64) bool {
+	if math.IsNaN(want) {
+		return math.IsNaN(got)
+	}
+	return math.Abs(got-want) <= 1e-9
+}
+
+func TestNewRegressionReport(t *testing.T) {
+	// the absolute errors are 1, 0, 1 and 4; the residuals -1, 0, 1 and -4 have a mean of -1 and a variance of 3.5
+	yTrue := []float64{2, 4, 6, 8}
+	yPred := []float64{3, 4, 5, 12}
+	r, err := NewRegressionReport(yTrue, yPred, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"MSE", r.MSE, 4.5},
+		{"RMSE", r.RMSE, math.Sqrt(4.5)},
+		{"MAE", r.MAE, 1.5},
+		{"median AE", r.MedianAE, 1},
+		{"R²", r.R2, 1 - 18.0/20},
+		{"adjusted R²", r.AdjustedR2, 1 - 0.9*3/2},
+		{"explained variance", r.ExplainedVariance, 1 - 3.5/5},
+		{"MAPE", r.MAPE, (0.5 + 0 + 1.0/6 + 0.5) / 4},
+	}
+	for _, tt := range tests {
+		if !closeTo(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if len(r.Residuals) != 4 || r.Residuals[3] != (Residual{Actual: 8, Predicted: 12, Residual: -4}) {
+		t.Errorf("residuals %v, want 4 ending with {8 12 -4}", r.Residuals)
+	}
+
+	if _, err := NewRegressionReport(yTrue, yPred[:3], 1); err == nil {
+		t.Error("NewRegressionReport() with fewer predictions succeeded, want an error")
+	}
+	if _, err := NewRegressionReport(nil, nil, 1); err == nil {
+		t.Error("NewRegressionReport() without predictions succeeded, want an error")
+	}
+}
+
+func TestRegressionReportJSON(t *testing.T) {
+	// with as many features as examples the adjusted R² is undefined, and so is the MAPE of zero targets
+	r, err := NewRegressionReport([]float64{0, 0}, []float64{1, -1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"mse":1`, `"adjusted_r2":null`, `"mape":null`, `"residuals":[{"actual":0,"predicted":1,"residual":-1}`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON %s does not contain %s", data, want)
+		}
+	}
+}
+
+func TestMedianAbsoluteError(t *testing.T) {
+	if got := MedianAbsoluteError([]float64{1, 2, 3}, []float64{1, 5, 4}); got != 1 {
+		t.Errorf("MedianAbsoluteError() of an odd number of errors = %v, want 1", got)
+	}
+	if got := MedianAbsoluteError(nil, nil); !math.IsNaN(got) {
+		t.Errorf("MedianAbsoluteError() of no errors = %v, want NaN", got)
+	}
+}
@@ -0,0 +1,566 @@
+package mlutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ColumnMissing counts the missing values of a column: NA elements (gota reads "NA" and unparsable numbers as NA),
+// NaN and infinite floats, and empty strings. NA floats are counted as NaN.
+type ColumnMissing struct {
+	Column string
+	NA     int
+	NaN    int
+	Inf    int
+	Empty  int
+	Rows   int
+}
+
+// Missing returns the total number of missing values in the column.
+func (c ColumnMissing) Missing() int {
+	return c.NA + c.NaN + c.Inf + c.Empty
+}
+
+// MissingReport holds the missing value counts of every column of a dataframe.
+type MissingReport []ColumnMissing
+
+// MissingValues counts the missing values of every column of df.
+func MissingValues(df dataframe.DataFrame) (MissingReport, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	ret := make(MissingReport, df.Ncol(), df.Ncol())
+	for j, name := range df.Names() {
+		c := df.Col(name)
+		ret[j] = ColumnMissing{Column: name, Rows: c.Len()}
+		for i := 0; i < c.Len(); i++ {
+			e := c.Elem(i)
+			switch {
+			case c.Type() == series.Float && math.IsInf(e.Float(), 0):
+				ret[j].Inf++
+			case c.Type() == series.Float && math.IsNaN(e.Float()):
+				ret[j].NaN++
+			case e.IsNA():
+				ret[j].NA++
+			case c.Type() == series.String && strings.TrimSpace(e.String()) == "":
+				ret[j].Empty++
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Total returns the number of missing values over all columns.
+func (r MissingReport) Total() int {
+	var n int
+	for _, c := range r {
+		n += c.Missing()
+	}
+	return n
+}
+
+// String formats the report as a table with a row per column.
+func (r MissingReport) String() string {
+	width := len("column")
+	for _, c := range r {
+		if len(c.Column) > width {
+			width = len(c.Column)
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%-*s %8s %8s %8s %8s %8s %8s\n", width, "column", "NA", "NaN", "Inf", "empty", "missing", "percent")
+	for _, c := range r {
+		fmt.Fprintf(&b, "%-*s %8d %8d %8d %8d %8d %7.2f%%\n", width, c.Column, c.NA, c.NaN, c.Inf, c.Empty, c.Missing(), 100*safeDivide(float64(c.Missing()), float64(c.Rows)))
+	}
+	return b.String()
+}
+
+// isMissing reports whether element i of s is a missing value, as counted by MissingValues.
+func isMissing(s series.Series, i int) bool {
+	e := s.Elem(i)
+	switch s.Type() {
+	case series.Float:
+		f := e.Float()
+		return math.IsNaN(f) || math.IsInf(f, 0)
+	case series.String:
+		return e.IsNA() || strings.TrimSpace(e.String()) == ""
+	default:
+		return e.IsNA()
+	}
+}
+
+// DropMissing returns df without the rows that have a missing value in any of cols, or in any column if cols is
+// empty.
+func DropMissing(df dataframe.DataFrame, cols ...string) (dataframe.DataFrame, error) {
+	if df.Err != nil {
+		return df, df.Err
+	}
+	if len(cols) == 0 {
+		cols = df.Names()
+	}
+	drop := make([]bool, df.Nrow(), df.Nrow())
+	for _, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return df, c.Err
+		}
+		for i := 0; i < c.Len(); i++ {
+			if isMissing(c, i) {
+				drop[i] = true
+			}
+		}
+	}
+	return DropRows(df, drop)
+}
+
+// ImputeStrategy selects the value an Imputer fills missing values with.
+type ImputeStrategy string
+
+const (
+	// ImputeMean fills the missing values of a numeric column with the mean of its other values.
+	ImputeMean ImputeStrategy = "mean"
+	// ImputeMedian fills the missing values of a numeric column with the median of its other values.
+	ImputeMedian ImputeStrategy = "median"
+	// ImputeMode fills the missing values of a column with its most frequent value; ties go to the smallest one.
+	ImputeMode ImputeStrategy = "mode"
+	// ImputeConstant fills the missing values with the imputer's Constant.
+	ImputeConstant ImputeStrategy = "constant"
+)
+
+// Imputer replaces the missing values of a column by a value learned from the training data. Mean and median only
+// apply to numeric columns; mode and constant also to string columns.
+type Imputer struct {
+	Strategy ImputeStrategy `json:"strategy"`
+	// Columns are the columns to impute. If empty, Fit imputes every column of a type the strategy applies to.
+	Columns []string `json:"columns,omitempty"`
+	// Constant is the fill value for ImputeConstant, parsed according to the type of each column.
+	Constant string `json:"constant,omitempty"`
+
+	// Values are the fitted fill values of each column, formatted as in a CSV file.
+	Values map[string]string `json:"values"`
+}
+
+// NewImputer returns an imputer of the given columns using strategy.
+func NewImputer(strategy ImputeStrategy, cols ...string) *Imputer {
+	return &Imputer{Strategy: strategy, Columns: cols}
+}
+
+// Fit learns the fill value of each column from its non-missing values in df.
+func (m *Imputer) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	cols := m.Columns
+	if len(cols) == 0 {
+		if m.Strategy == ImputeMean || m.Strategy == ImputeMedian {
+			cols = numericColumns(df)
+		} else {
+			cols = df.Names()
+		}
+	}
+	values := make(map[string]string, len(cols))
+	for _, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return c.Err
+		}
+		numeric := c.Type() == series.Float || c.Type() == series.Int
+		var present []float64
+		var records []string
+		for i := 0; i < c.Len(); i++ {
+			if isMissing(c, i) {
+				continue
+			}
+			present = append(present, c.Elem(i).Float())
+			records = append(records, c.Elem(i).String())
+		}
+		switch m.Strategy {
+		case ImputeMean, ImputeMedian:
+			if !numeric {
+				return fmt.Errorf("mlutil: cannot impute the %s of non-numeric column %q", m.Strategy, col)
+			}
+			if len(present) == 0 {
+				return fmt.Errorf("mlutil: column %q has no values to impute from", col)
+			}
+			if m.Strategy == ImputeMean {
+				values[col] = formatFloat(mean(present))
+			} else {
+				values[col] = formatFloat(median(present))
+			}
+		case ImputeMode:
+			if len(records) == 0 {
+				return fmt.Errorf("mlutil: column %q has no values to impute from", col)
+			}
+			values[col] = mode(records, numeric)
+		case ImputeConstant:
+			values[col] = m.Constant
+		default:
+			return fmt.Errorf("mlutil: unknown imputation strategy %q", m.Strategy)
+		}
+	}
+	m.Values = values
+	return nil
+}
+
+// Transform fills the missing values of each fitted column of df. An integer column whose fill value is not a whole
+// number, such as a mean of 12.5, becomes a float column.
+func (m *Imputer) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if m.Values == nil {
+		return df, ErrNotFitted
+	}
+	if df.Err != nil {
+		return df, df.Err
+	}
+	for _, col := range sortedStrings(m.Values) {
+		c := df.Col(col)
+		if c.Err != nil {
+			return df, c.Err
+		}
+		var filled series.Series
+		_, notInt := strconv.Atoi(m.Values[col])
+		if c.Type() == series.Float || (c.Type() == series.Int && notInt != nil) {
+			// the records of a float column are rounded, and an integer column cannot hold a fractional fill value,
+			// so fill the values themselves as floats
+			fill, err := strconv.ParseFloat(m.Values[col], 64)
+			if err != nil {
+				return df, fmt.Errorf("mlutil: fill value of column %q: %v", col, err)
+			}
+			v := c.Float()
+			for i := range v {
+				if isMissing(c, i) {
+					v[i] = fill
+				}
+			}
+			filled = series.New(v, series.Float, col)
+		} else {
+			records := c.Records()
+			for i := range records {
+				if isMissing(c, i) {
+					records[i] = m.Values[col]
+				}
+			}
+			filled = series.New(records, c.Type(), col)
+		}
+		if filled.Err != nil {
+			return df, filled.Err
+		}
+		df = df.Mutate(filled)
+	}
+	return df, df.Err
+}
+
+func (m *Imputer) transformerKind() string {
+	return "imputer"
+}
+
+// KNNImputer fills the missing values of numeric columns with the mean of the K nearest training rows that have a
+// value for the column. Distances are Euclidean over the columns both rows have, scaled up to make up for the
+// columns that are left out.
+type KNNImputer struct {
+	K int `json:"k"`
+	// Columns are the numeric columns used for the distances and imputed. If empty, Fit uses every numeric column.
+	Columns []string `json:"columns,omitempty"`
+
+	// Rows are the training rows the neighbours are taken from, with NaN for missing values. In JSON, missing values
+	// are null.
+	Rows [][]float64 `json:"rows"`
+	// Fitted is the order of the columns of Rows.
+	Fitted []string `json:"fitted"`
+}
+
+// NewKNNImputer returns an imputer of the given columns from k neighbours.
+func NewKNNImputer(k int, cols ...string) *KNNImputer {
+	return &KNNImputer{K: k, Columns: cols}
+}
+
+// Fit stores the numeric columns of df to take neighbours from.
+func (m *KNNImputer) Fit(df dataframe.DataFrame) error {
+	if m.K < 1 {
+		return fmt.Errorf("mlutil: cannot impute from %d neighbours", m.K)
+	}
+	cols := m.Columns
+	if len(cols) == 0 {
+		cols = numericColumns(df)
+	}
+	rows, err := m.rows(df, cols)
+	if err != nil {
+		return err
+	}
+	m.Rows, m.Fitted = rows, cols
+	return nil
+}
+
+// Transform fills the missing values of the fitted columns of df from the nearest training rows. Values that no
+// training row has are left missing.
+func (m *KNNImputer) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if m.Rows == nil {
+		return df, ErrNotFitted
+	}
+	rows, err := m.rows(df, m.Fitted)
+	if err != nil {
+		return df, err
+	}
+	type neighbour struct {
+		row  int
+		dist float64
+	}
+	for _, row := range rows {
+		var neighbours []neighbour
+		for j := range m.Fitted {
+			if !math.IsNaN(row[j]) {
+				continue
+			}
+			if neighbours == nil {
+				neighbours = make([]neighbour, 0, len(m.Rows))
+				for t, train := range m.Rows {
+					if d, ok := nanDistance(row, train); ok {
+						neighbours = append(neighbours, neighbour{t, d})
+					}
+				}
+				sort.SliceStable(neighbours, func(a, b int) bool { return neighbours[a].dist < neighbours[b].dist })
+			}
+			var sum float64
+			var n int
+			for _, nb := range neighbours {
+				if v := m.Rows[nb.row][j]; !math.IsNaN(v) {
+					sum += v
+					n++
+					if n == m.K {
+						break
+					}
+				}
+			}
+			if n > 0 {
+				row[j] = sum / float64(n)
+			}
+		}
+	}
+	for j, col := range m.Fitted {
+		v := make([]float64, len(rows), len(rows))
+		for i := range rows {
+			v[i] = rows[i][j]
+		}
+		df = df.Mutate(series.New(v, series.Float, col))
+	}
+	return df, df.Err
+}
+
+// rows returns the given numeric columns of df as rows, with NaN for missing values.
+func (m *KNNImputer) rows(df dataframe.DataFrame, cols []string) ([][]float64, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	ret := make([][]float64, df.Nrow(), df.Nrow())
+	for i := range ret {
+		ret[i] = make([]float64, len(cols), len(cols))
+	}
+	for j, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return nil, c.Err
+		}
+		if c.Type() != series.Float && c.Type() != series.Int {
+			return nil, fmt.Errorf("mlutil: cannot impute non-numeric column %q from neighbours", col)
+		}
+		for i := range ret {
+			if isMissing(c, i) {
+				ret[i][j] = math.NaN()
+			} else {
+				ret[i][j] = c.Elem(i).Float()
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (m *KNNImputer) transformerKind() string {
+	return "knn_imputer"
+}
+
+// knnImputerJSON is the JSON form of a KNNImputer, with nil for the missing values that encoding/json cannot encode
+// as NaN.
+type knnImputerJSON struct {
+	K       int          `json:"k"`
+	Columns []string     `json:"columns,omitempty"`
+	Rows    [][]*float64 `json:"rows"`
+	Fitted  []string     `json:"fitted"`
+}
+
+// MarshalJSON encodes the imputer with its missing training values as null.
+func (m *KNNImputer) MarshalJSON() ([]byte, error) {
+	j := knnImputerJSON{K: m.K, Columns: m.Columns, Fitted: m.Fitted}
+	if m.Rows != nil {
+		j.Rows = make([][]*float64, len(m.Rows), len(m.Rows))
+		for i, row := range m.Rows {
+			j.Rows[i] = make([]*float64, len(row), len(row))
+			for k := range row {
+				if !math.IsNaN(row[k]) {
+					j.Rows[i][k] = &row[k]
+				}
+			}
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes an imputer encoded by MarshalJSON.
+func (m *KNNImputer) UnmarshalJSON(data []byte) error {
+	var j knnImputerJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	m.K, m.Columns, m.Fitted, m.Rows = j.K, j.Columns, j.Fitted, nil
+	if j.Rows != nil {
+		m.Rows = make([][]float64, len(j.Rows), len(j.Rows))
+		for i, row := range j.Rows {
+			m.Rows[i] = make([]float64, len(row), len(row))
+			for k, v := range row {
+				if v == nil {
+					m.Rows[i][k] = math.NaN()
+				} else {
+					m.Rows[i][k] = *v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// nanDistance returns the Euclidean distance between a and b over the coordinates both have, scaled by the share of
+// coordinates used. ok is false if they have none in common.
+func nanDistance(a, b []float64) (dist float64, ok bool) {
+	var sum float64
+	var n int
+	for i := range a {
+		if math.IsNaN(a[i]) || math.IsNaN(b[i]) {
+			continue
+		}
+		d := a[i] - b[i]
+		sum += d * d
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return math.Sqrt(sum * float64(len(a)) / float64(n)), true
+}
+
+// MissingIndicator adds a 0/1 column "<column>_missing" marking the missing values of each column, so that a model
+// can still tell imputed values apart.
+type MissingIndicator struct {
+	// Columns are the columns to mark. If empty, Fit marks every column that has missing values in the training
+	// data.
+	Columns []string `json:"columns,omitempty"`
+
+	// Fitted are the marked columns.
+	Fitted []string `json:"fitted"`
+}
+
+// NewMissingIndicator returns an indicator of the missing values of the given columns.
+func NewMissingIndicator(cols ...string) *MissingIndicator {
+	return &MissingIndicator{Columns: cols}
+}
+
+// Fit selects the columns to mark.
+func (m *MissingIndicator) Fit(df dataframe.DataFrame) error {
+	if len(m.Columns) > 0 {
+		m.Fitted = m.Columns
+		return nil
+	}
+	report, err := MissingValues(df)
+	if err != nil {
+		return err
+	}
+	m.Fitted = []string{}
+	for _, c := range report {
+		if c.Missing() > 0 {
+			m.Fitted = append(m.Fitted, c.Column)
+		}
+	}
+	return nil
+}
+
+// Transform adds the indicator columns to df.
+func (m *MissingIndicator) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if m.Fitted == nil {
+		return df, ErrNotFitted
+	}
+	if df.Err != nil {
+		return df, df.Err
+	}
+	for _, col := range m.Fitted {
+		c := df.Col(col)
+		if c.Err != nil {
+			return df, c.Err
+		}
+		v := make([]int, c.Len(), c.Len())
+		for i := range v {
+			if isMissing(c, i) {
+				v[i] = 1
+			}
+		}
+		df = df.Mutate(series.New(v, series.Int, col+"_missing"))
+	}
+	return df, df.Err
+}
+
+func (m *MissingIndicator) transformerKind() string {
+	return "missing_indicator"
+}
+
+// median returns the median of f, which must not be empty.
+func median(f []float64) float64 {
+	s := append([]float64(nil), f...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// mode returns the most frequent of records, which must not be empty, choosing the smallest on ties. The records
+// of numeric columns are compared as numbers, so that 9 comes before 10.
+func mode(records []string, numeric bool) string {
+	counts := make(map[string]int)
+	for _, r := range records {
+		counts[r]++
+	}
+	sorted := uniqueSorted(records)
+	if numeric {
+		sort.SliceStable(sorted, func(a, b int) bool {
+			x, _ := strconv.ParseFloat(sorted[a], 64)
+			y, _ := strconv.ParseFloat(sorted[b], 64)
+			return x < y
+		})
+	}
+	best := sorted[0]
+	for _, r := range sorted[1:] {
+		if counts[r] > counts[best] {
+			best = r
+		}
+	}
+	return best
+}
+
+// formatFloat formats f so that it parses back to the same value.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sortedStrings returns the keys of m in increasing order.
+func sortedStrings(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,271 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"strings"
+	"testing"
+)
+
+// missingFrame returns a dataframe with a missing value in every column: a float column f, an integer column i and
+// a string column s.
+func missingFrame() dataframe.DataFrame {
+	return dataframe.New(
+		series.New([]float64{1, math.NaN(), 3, 10}, series.Float, "f"),
+		series.New([]string{"1", "NA", "2", "2"}, series.Int, "i"),
+		series.New([]string{"a", "", "b", "a"}, series.String, "s"),
+	)
+}
+
+func TestImputer(t *testing.T) {
+	tests := []struct {
+		name    string
+		imputer *Imputer
+		values  map[string]string
+		want    []wantColumn
+	}{
+		{
+			name:    "mean of floats",
+			imputer: NewImputer(ImputeMean, "f"),
+			values:  map[string]string{"f": "4.666666666666667"},
+			want:    []wantColumn{{name: "f", typ: series.Float, floats: []float64{1, 14.0 / 3, 3, 10}}},
+		},
+		{
+			name:    "median of floats",
+			imputer: NewImputer(ImputeMedian, "f"),
+			values:  map[string]string{"f": "3"},
+			want:    []wantColumn{{name: "f", typ: series.Float, floats: []float64{1, 3, 3, 10}}},
+		},
+		{
+			name:    "fractional mean turns integers into floats",
+			imputer: NewImputer(ImputeMean, "i"),
+			values:  map[string]string{"i": "1.6666666666666667"},
+			want:    []wantColumn{{name: "i", typ: series.Float, floats: []float64{1, 5.0 / 3, 2, 2}}},
+		},
+		{
+			name:    "whole median keeps integers",
+			imputer: NewImputer(ImputeMedian, "i"),
+			values:  map[string]string{"i": "2"},
+			want:    []wantColumn{{name: "i", typ: series.Int, records: []string{"1", "2", "2", "2"}}},
+		},
+		{
+			name:    "median of every numeric column",
+			imputer: NewImputer(ImputeMedian),
+			values:  map[string]string{"f": "3", "i": "2"},
+			want: []wantColumn{
+				{name: "f", typ: series.Float, floats: []float64{1, 3, 3, 10}},
+				{name: "i", typ: series.Int, records: []string{"1", "2", "2", "2"}},
+				{name: "s", typ: series.String, records: []string{"a", "", "b", "a"}},
+			},
+		},
+		{
+			name:    "mode of strings and integers",
+			imputer: NewImputer(ImputeMode, "s", "i"),
+			values:  map[string]string{"s": "a", "i": "2"},
+			want: []wantColumn{
+				{name: "s", typ: series.String, records: []string{"a", "a", "b", "a"}},
+				{name: "i", typ: series.Int, records: []string{"1", "2", "2", "2"}},
+			},
+		},
+		{
+			name:    "constant",
+			imputer: &Imputer{Strategy: ImputeConstant, Columns: []string{"s", "f"}, Constant: "0"},
+			values:  map[string]string{"s": "0", "f": "0"},
+			want: []wantColumn{
+				{name: "s", typ: series.String, records: []string{"a", "0", "b", "a"}},
+				{name: "f", typ: series.Float, floats: []float64{1, 0, 3, 10}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := missingFrame()
+			if err := tt.imputer.Fit(df); err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.imputer.Values) != len(tt.values) {
+				t.Errorf("fill values %v, want %v", tt.imputer.Values, tt.values)
+			}
+			for col, v := range tt.values {
+				if tt.imputer.Values[col] != v {
+					t.Errorf("fill value of %q = %q, want %q", col, tt.imputer.Values[col], v)
+				}
+			}
+			out, err := tt.imputer.Transform(df)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out, tt.want...)
+
+			// a pipeline decoded from JSON fills the same values
+			data, err := json.Marshal(NewPipeline(tt.imputer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var p Pipeline
+			if err := json.Unmarshal(data, &p); err != nil {
+				t.Fatal(err)
+			}
+			out, err = p.Transform(missingFrame())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out, tt.want...)
+		})
+	}
+}
+
+func TestImputerModeTies(t *testing.T) {
+	// 9 and 10 are as frequent; as numbers 9 is the smaller, though "10" sorts first as a string
+	m := NewImputer(ImputeMode, "n", "s")
+	if err := m.Fit(dataframe.New(
+		series.New([]int{10, 9, 10, 9}, series.Int, "n"),
+		series.New([]string{"10", "9", "10", "9"}, series.String, "s"),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	if m.Values["n"] != "9" || m.Values["s"] != "10" {
+		t.Errorf("fill values %v, want 9 for the integers and 10 for the strings", m.Values)
+	}
+}
+
+func TestImputerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		imputer *Imputer
+	}{
+		{"mean of strings", NewImputer(ImputeMean, "s")},
+		{"median of strings", NewImputer(ImputeMedian, "s")},
+		{"unknown strategy", NewImputer(ImputeStrategy("max"), "f")},
+		{"unknown column", NewImputer(ImputeMode, "g")},
+		{"no values", NewImputer(ImputeMean, "empty")},
+	}
+	df := missingFrame().Mutate(series.New([]float64{math.NaN(), math.NaN(), math.NaN(), math.NaN()}, series.Float, "empty"))
+	for _, tt := range tests {
+		if err := tt.imputer.Fit(df); err == nil {
+			t.Errorf("%s: Fit() succeeded, want an error", tt.name)
+		}
+	}
+	if _, err := NewImputer(ImputeMean).Transform(df); err != ErrNotFitted {
+		t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+	}
+}
+
+func TestKNNImputer(t *testing.T) {
+	df := dataframe.New(
+		series.New([]float64{1, 2, 3, math.NaN()}, series.Float, "a"),
+		series.New([]float64{1, 2, 3, 4}, series.Float, "b"),
+	)
+	tests := []struct {
+		k int
+		// the last row is nearest to the third, then the second, then the first
+		want float64
+	}{
+		{1, 3},
+		{2, 2.5},
+		{3, 2},
+		{10, 2},
+	}
+	for _, tt := range tests {
+		m := NewKNNImputer(tt.k)
+		if err := m.Fit(df); err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.Transform(df)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkColumns(t, out, wantColumn{name: "a", typ: series.Float, floats: []float64{1, 2, 3, tt.want}})
+	}
+
+	// the missing training value is persisted as null and read back as missing
+	p := NewPipeline(NewKNNImputer(2))
+	if err := p.Fit(df); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[null,4]") {
+		t.Errorf("JSON %s does not hold the missing value as null", data)
+	}
+	var decoded Pipeline
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	out, err := decoded.Transform(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumns(t, out, wantColumn{name: "a", typ: series.Float, floats: []float64{1, 2, 3, 2.5}})
+
+	if err := NewKNNImputer(0).Fit(df); err == nil {
+		t.Error("Fit() with no neighbours succeeded, want an error")
+	}
+	if err := NewKNNImputer(1, "s").Fit(missingFrame()); err == nil {
+		t.Error("Fit() on a string column succeeded, want an error")
+	}
+}
+
+func TestMissingValues(t *testing.T) {
+	df := dataframe.New(
+		series.New([]float64{1, math.NaN(), math.Inf(1), 2}, series.Float, "f"),
+		series.New([]string{"1", "NA", "3", "4"}, series.Int, "i"),
+		series.New([]string{"a", "", "c", "b"}, series.String, "s"),
+	)
+	report, err := MissingValues(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MissingReport{
+		{Column: "f", NaN: 1, Inf: 1, Rows: 4},
+		{Column: "i", NA: 1, Rows: 4},
+		{Column: "s", Empty: 1, Rows: 4},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("report has %d columns, want %d", len(report), len(want))
+	}
+	for j := range want {
+		if report[j] != want[j] {
+			t.Errorf("column %d: %+v, want %+v", j, report[j], want[j])
+		}
+	}
+	if report.Total() != 4 {
+		t.Errorf("Total() = %d, want 4", report.Total())
+	}
+
+	dropTests := []struct {
+		cols []string
+		rows int
+	}{
+		{nil, 2},
+		{[]string{"i"}, 3},
+		{[]string{"f", "s"}, 2},
+	}
+	for _, tt := range dropTests {
+		out, err := DropMissing(df, tt.cols...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Nrow() != tt.rows {
+			t.Errorf("DropMissing(%v) kept %d rows, want %d", tt.cols, out.Nrow(), tt.rows)
+		}
+	}
+
+	m := NewMissingIndicator()
+	if err := m.Fit(df); err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Transform(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumns(t, out,
+		wantColumn{name: "f_missing", typ: series.Int, records: []string{"0", "1", "1", "0"}},
+		wantColumn{name: "i_missing", typ: series.Int, records: []string{"0", "1", "0", "0"}},
+		wantColumn{name: "s_missing", typ: series.Int, records: []string{"0", "1", "0", "0"}},
+	)
+}
@@ -0,0 +1,75 @@
+package mlutil
+
+import (
+	"errors"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	mnist "github.com/petar/GoMNIST"
+)
+
+// MNISTSetToDataframe converts at most maxExamples images of the set to a dataframe with an "Image" column holding
+// the raw pixels and an int "Label" column. MNISTSetToImageSet keeps the pixels as bytes instead, which is faster
+// and smaller for training on the images.
+func MNISTSetToDataframe(st *mnist.Set, maxExamples int) (dataframe.DataFrame, error) {
+	if st == nil {
+		return dataframe.DataFrame{}, errors.New("mlutil: nil MNIST set")
+	}
+	length := maxExamples
+	if length > len(st.Images) {
+		length = len(st.Images)
+	}
+	s := make([]string, length, length)
+	l := make([]int, length, length)
+	for i := 0; i < length; i++ {
+		s[i] = string(st.Images[i])
+		l[i] = int(st.Labels[i])
+	}
+	images := series.Strings(s)
+	images.Name = "Image"
+	labels := series.Ints(l)
+	labels.Name = "Label"
+	df := dataframe.New(images, labels)
+	return df, df.Err
+}
+
+// NormalizeBytes maps pixel values onto the range [0,1].
+func NormalizeBytes(bs []byte) []float64 {
+	ret := make([]float64, len(bs), len(bs))
+	for i := range bs {
+		ret[i] = float64(bs[i]) / 255.
+	}
+	return ret
+}
+
+// ImageSeriesToFloats converts the image column col created by MNISTSetToDataframe to normalised pixel values.
+func ImageSeriesToFloats(df dataframe.DataFrame, col string) ([][]float64, error) {
+	s := df.Col(col)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	ret := make([][]float64, s.Len(), s.Len())
+	for i := 0; i < s.Len(); i++ {
+		b := []byte(s.Elem(i).String())
+		ret[i] = NormalizeBytes(b)
+	}
+	return ret, nil
+}
+
+// ImageSeriesToInts converts the image column col created by MNISTSetToDataframe to raw pixel values (0-255), as
+// expected by the Python models in Chapter05.
+func ImageSeriesToInts(df dataframe.DataFrame, col string) ([][]int, error) {
+	s := df.Col(col)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	ret := make([][]int, s.Len(), s.Len())
+	for i := 0; i < s.Len(); i++ {
+		b := []byte(s.Elem(i).String())
+		ints := make([]int, len(b), len(b))
+		for j := range b {
+			ints[j] = int(b[j])
+		}
+		ret[i] = ints
+	}
+	return ret, nil
+}
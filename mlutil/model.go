@@ -0,0 +1,78 @@
+package mlutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/datastream/libsvm"
+)
+
+// ErrNotClassifier is returned by PredictProba for models that do not predict class probabilities, such as the
+// regression models.
+var ErrNotClassifier = errors.New("mlutil: model does not predict class probabilities")
+
+// ErrNotFitted is returned when a model is used for prediction before Fit has been called.
+var ErrNotFitted = errors.New("mlutil: model has not been fitted")
+
+// Model is the common interface implemented by the adapters for goml, sajari/regression, RF.go, libsvm and go-deep,
+// and by the decision trees of this package, so that evaluation, serving and plotting code only has to be written
+// once.
+//
+// For classifiers y holds the class labels 0, 1, ..., Predict returns the predicted label and PredictProba returns
+// the probability of each label, indexed by label. For regression models Predict returns the predicted value and
+// PredictProba returns ErrNotClassifier.
+type Model interface {
+	Fit(x [][]float64, y []float64) error
+	Predict(x []float64) (float64, error)
+	PredictProba(x []float64) ([]float64, error)
+}
+
+// ModelTrainFunc returns a TrainFunc that fits a new model returned by newModel, for use with CrossValidate.
+func ModelTrainFunc(newModel func() Model) TrainFunc {
+	return func(x [][]float64, y []float64) (Predictor, error) {
+		m := newModel()
+		if err := m.Fit(x, y); err != nil {
+			return nil, err
+		}
+		return m.Predict, nil
+	}
+}
+
+// PredictAll returns the predictions of m for every row of x.
+func PredictAll(m Model, x [][]float64) ([]float64, error) {
+	ret := make([]float64, len(x), len(x))
+	for i := range x {
+		p, err := m.Predict(x[i])
+		if err != nil {
+			return nil, fmt.Errorf("mlutil: predicting example %d: %v", i, err)
+		}
+		ret[i] = p
+	}
+	return ret, nil
+}
+
+// FloatsToInterfaces converts a slice of float64 to the []interface{} expected by RF.go.
+func FloatsToInterfaces(f []float64) []interface{} {
+	iif := make([]interface{}, len(f), len(f))
+	for i := range f {
+		iif[i] = f[i]
+	}
+	return iif
+}
+
+// FloatsToSVMNode converts a slice of float64 to SVMNode with sequential indices starting at 1, terminated by the
+// end of vector node libsvm expects.
+func FloatsToSVMNode(f []float64) []libsvm.SVMNode {
+	ret := make([]libsvm.SVMNode, len(f), len(f)+1)
+	for i := range f {
+		ret[i] = libsvm.SVMNode{
+			Index: i + 1,
+			Value: f[i],
+		}
+	}
+	//End of Vector
+	ret = append(ret, libsvm.SVMNode{
+		Index: -1,
+		Value: 0,
+	})
+	return ret
+}
@@ -0,0 +1,95 @@
+package mlutil
+
+import (
+	"github.com/cdipaolo/goml/base"
+	"github.com/datastream/libsvm"
+	"github.com/patrikeh/go-deep"
+	"math"
+	"testing"
+)
+
+func TestModelsBeforeFit(t *testing.T) {
+	models := map[string]Model{
+		"least squares": NewLeastSquaresModel(base.BatchGA, 0.01, 0, 10),
+		"logistic":      NewLogisticModel(base.BatchGA, 0.01, 0, 10),
+		"softmax":       NewSoftmaxModel(base.BatchGA, 0.01, 0, 3, 10),
+		"OLS":           NewOLSModel(),
+		"forest":        NewForestModel(10, 0, 1),
+		"SVM":           NewSVMModel(libsvm.SVMParameter{}),
+		"neural":        NewNeuralModel(deep.Config{Layout: []int{2}, Mode: deep.ModeMultiClass}, nil, 1),
+	}
+	for name, m := range models {
+		if _, err := m.Predict([]float64{1}); err != ErrNotFitted {
+			t.Errorf("%s: Predict() before Fit() returned %v, want ErrNotFitted", name, err)
+		}
+	}
+}
+
+func TestOLSModel(t *testing.T) {
+	x := [][]float64{{0, 1}, {1, 0}, {2, 2}, {3, 1}, {4, 5}}
+	y := make([]float64, len(x), len(x))
+	for i := range x {
+		y[i] = 1 + 2*x[i][0] - x[i][1]
+	}
+	m := NewOLSModel()
+	if err := m.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	p, err := m.Predict([]float64{10, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p-18) > 1e-9 {
+		t.Errorf("Predict() = %v, want 18", p)
+	}
+	if _, err := m.PredictProba([]float64{10, 3}); err != ErrNotClassifier {
+		t.Errorf("PredictProba() returned %v, want ErrNotClassifier", err)
+	}
+}
+
+func TestSVMModel(t *testing.T) {
+	// two well separated classes, labelled 1 and 0 in that order so that libsvm sees them reversed
+	x := [][]float64{{5, 5}, {6, 5}, {5, 6}, {6, 6}, {0, 0}, {1, 0}, {0, 1}, {1, 1}}
+	y := []float64{1, 1, 1, 1, 0, 0, 0, 0}
+	m := NewSVMModel(libsvm.SVMParameter{SvmType: libsvm.CSVC, KernelType: libsvm.LINEAR, C: 1, Eps: 1e-3, CacheSize: 10})
+	if err := m.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		x    []float64
+		want float64
+	}{{[]float64{0.5, 0.5}, 0}, {[]float64{5.5, 5.5}, 1}} {
+		if p, err := m.Predict(tt.x); err != nil || p != tt.want {
+			t.Errorf("Predict(%v) = %v, %v, want %v", tt.x, p, err, tt.want)
+		}
+	}
+	if _, err := m.PredictProba(x[0]); err == nil {
+		t.Error("PredictProba() without Probability set succeeded, want an error")
+	}
+}
+
+func TestFloatsToSVMNode(t *testing.T) {
+	nodes := FloatsToSVMNode([]float64{0.5, 2})
+	want := []libsvm.SVMNode{{Index: 1, Value: 0.5}, {Index: 2, Value: 2}, {Index: -1}}
+	if len(nodes) != len(want) {
+		t.Fatalf("FloatsToSVMNode() = %v, want %v", nodes, want)
+	}
+	for i := range want {
+		if nodes[i] != want[i] {
+			t.Errorf("FloatsToSVMNode() = %v, want %v", nodes, want)
+		}
+	}
+}
+
+func TestModelTrainFunc(t *testing.T) {
+	x := [][]float64{{0}, {1}, {2}, {3}, {4}, {5}}
+	y := []float64{1, 3, 5, 7, 9, 11}
+	r, err := CrossValidateXY(x, y, [][]int{{0, 2, 4}, {1, 3, 5}}, ModelTrainFunc(func() Model { return NewOLSModel() }),
+		map[string]Scorer{"MSE": MeanSquaredError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Mean["MSE"] > 1e-12 {
+		t.Errorf("cross-validated MSE of a line fitted to a line is %v, want 0", r.Mean["MSE"])
+	}
+}
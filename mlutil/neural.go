@@ -0,0 +1,217 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/patrikeh/go-deep"
+	"github.com/patrikeh/go-deep/training"
+	"math"
+)
+
+// NeuralModel adapts a go-deep network to the Model interface. Config.Mode selects between multi-class
+// classification (the labels are one-hot encoded onto the output layer), binary classification and regression.
+type NeuralModel struct {
+	Config    deep.Config
+	Optimizer training.Solver
+	// Epochs is the number of passes the trainer makes over the training examples.
+	Epochs int
+
+	network *deep.Neural
+}
+
+// NewNeuralModel returns an unfitted network with the given configuration, trained for epochs iterations with
+// optimizer.
+func NewNeuralModel(config deep.Config, optimizer training.Solver, epochs int) *NeuralModel {
+	return &NeuralModel{Config: config, Optimizer: optimizer, Epochs: epochs}
+}
+
+// WrapNeural returns an adapter for a network that has already been trained.
+func WrapNeural(network *deep.Neural) *NeuralModel {
+	return &NeuralModel{Config: *network.Config, network: network}
+}
+
+// Fit trains the network on x and y.
+func (m *NeuralModel) Fit(x [][]float64, y []float64) error {
+	if len(x) == 0 {
+		return errors.New("mlutil: no training examples")
+	}
+	if len(m.Config.Layout) == 0 {
+		return errors.New("mlutil: network has no layers")
+	}
+	outputs := m.Config.Layout[len(m.Config.Layout)-1]
+	examples := make(training.Examples, len(x), len(x))
+	for i := range x {
+		var response []float64
+		switch m.Config.Mode {
+		case deep.ModeMultiClass:
+			if y[i] < 0 || int(y[i]) >= outputs {
+				return fmt.Errorf("mlutil: class label %v of example %d does not fit in %d outputs", y[i], i, outputs)
+			}
+			response = make([]float64, outputs, outputs)
+			response[int(y[i])] = 1
+		default:
+			response = []float64{y[i]}
+		}
+		examples[i] = training.Example{Input: x[i], Response: response}
+	}
+
+	config := m.Config
+	if config.Inputs == 0 {
+		config.Inputs = len(x[0])
+	}
+	network := deep.NewNeural(&config)
+	trainer := training.NewTrainer(m.Optimizer, 0)
+	trainer.Train(network, examples, nil, m.Epochs)
+	m.network = network
+	return nil
+}
+
+// Predict returns the most probable class for classifiers and the output value for regression networks.
+func (m *NeuralModel) Predict(x []float64) (float64, error) {
+	if m.network == nil {
+		return 0, ErrNotFitted
+	}
+	out := m.network.Predict(x)
+	switch m.Config.Mode {
+	case deep.ModeMultiClass:
+		return float64(MaxIndex(out)), nil
+	case deep.ModeBinary:
+		return math.Round(out[0]), nil
+	default:
+		return out[0], nil
+	}
+}
+
+// PredictProba returns the probability of each class.
+func (m *NeuralModel) PredictProba(x []float64) ([]float64, error) {
+	if m.network == nil {
+		return nil, ErrNotFitted
+	}
+	switch m.Config.Mode {
+	case deep.ModeMultiClass:
+		return m.network.Predict(x), nil
+	case deep.ModeBinary:
+		p := m.network.Predict(x)[0]
+		return []float64{1 - p, p}, nil
+	default:
+		return nil, ErrNotClassifier
+	}
+}
+
+func (m *NeuralModel) modelKind() (string, string) {
+	return "neural", "go-deep"
+}
+
+// marshalModel writes the network with go-deep's own Dump. The optimizer is not persisted, so a loaded model has to
+// be given one before it is fitted again.
+func (m *NeuralModel) marshalModel() ([]byte, error) {
+	if m.network == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(struct {
+		Epochs  int        `json:"epochs"`
+		Network *deep.Dump `json:"network"`
+	}{m.Epochs, m.network.Dump()})
+}
+
+func (m *NeuralModel) unmarshalModel(data []byte) error {
+	var s struct {
+		Epochs  int        `json:"epochs"`
+		Network *deep.Dump `json:"network"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s.Network == nil || s.Network.Config == nil || len(s.Network.Config.Layout) == 0 {
+		return errors.New("network has no layers")
+	}
+	network := deep.NewNeural(s.Network.Config)
+	if !sameShape(network.Weights(), s.Network.Weights) {
+		return errors.New("weights do not match the network layout")
+	}
+	network.ApplyWeights(s.Network.Weights)
+	*m = NeuralModel{Config: *network.Config, Epochs: s.Epochs, network: network}
+	return nil
+}
+
+// sameShape reports whether two sets of layer weights have the same dimensions.
+func sameShape(a, b [][][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if len(a[i][j]) != len(b[i][j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// EpochTrainer trains a go-deep network online like training.OnlineTrainer, but one epoch at a time, so that the
+// examples can change between epochs, eg. to augment them anew. Calling OnlineTrainer.Train once per epoch would
+// initialise the solver every time, losing the momentum of SGD or the moments of Adam and restarting the decay of
+// the learning rate. An EpochTrainer initialises the solver once per network and numbers the epochs on.
+type EpochTrainer struct {
+	Solver training.Solver
+
+	network *deep.Neural
+	epoch   int
+	deltas  [][]float64
+}
+
+// NewEpochTrainer returns a trainer updating the weights with solver.
+func NewEpochTrainer(solver training.Solver) *EpochTrainer {
+	return &EpochTrainer{Solver: solver}
+}
+
+// Epoch returns the number of epochs n has been trained for.
+func (t *EpochTrainer) Epoch() int {
+	return t.epoch
+}
+
+// TrainEpoch makes a pass over the examples in a random order, updating the weights of n after every example.
+// Training another network starts the solver and the count of epochs over.
+func (t *EpochTrainer) TrainEpoch(n *deep.Neural, examples training.Examples) {
+	if t.network != n {
+		t.network, t.epoch = n, 0
+		t.deltas = make([][]float64, len(n.Layers), len(n.Layers))
+		for i, l := range n.Layers {
+			t.deltas[i] = make([]float64, len(l.Neurons), len(l.Neurons))
+		}
+		t.Solver.Init(n.NumWeights())
+	}
+	t.epoch++
+	examples.Shuffle()
+	loss := deep.GetLoss(n.Config.Loss)
+	last := len(n.Layers) - 1
+	for _, e := range examples {
+		n.Forward(e.Input)
+		for i, neuron := range n.Layers[last].Neurons {
+			t.deltas[last][i] = loss.Df(neuron.Value, e.Response[i], neuron.DActivate(neuron.Value))
+		}
+		for i := last - 1; i >= 0; i-- {
+			for j, neuron := range n.Layers[i].Neurons {
+				var sum float64
+				for k, s := range neuron.Out {
+					sum += s.Weight * t.deltas[i+1][k]
+				}
+				t.deltas[i][j] = neuron.DActivate(neuron.Value) * sum
+			}
+		}
+		var idx int
+		for i, l := range n.Layers {
+			for j := range l.Neurons {
+				for _, s := range l.Neurons[j].In {
+					s.Weight += t.Solver.Update(s.Weight, t.deltas[i][j]*s.In, t.epoch, idx)
+					idx++
+				}
+			}
+		}
+	}
+}
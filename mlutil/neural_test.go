@@ -0,0 +1,63 @@
+package mlutil
+
+import (
+	"github.com/patrikeh/go-deep"
+	"github.com/patrikeh/go-deep/training"
+	"testing"
+)
+
+// recordingSolver is an SGD solver that records how often it is initialised and the epochs of its updates.
+type recordingSolver struct {
+	*training.SGD
+	inits  int
+	epochs map[int]bool
+}
+
+func (s *recordingSolver) Init(size int) {
+	s.inits++
+	s.SGD.Init(size)
+}
+
+func (s *recordingSolver) Update(value, gradient float64, iteration, idx int) float64 {
+	s.epochs[iteration] = true
+	return s.SGD.Update(value, gradient, iteration, idx)
+}
+
+func TestEpochTrainer(t *testing.T) {
+	// the exclusive or of two inputs
+	examples := training.Examples{
+		{Input: []float64{0, 0}, Response: []float64{0}},
+		{Input: []float64{0, 1}, Response: []float64{1}},
+		{Input: []float64{1, 0}, Response: []float64{1}},
+		{Input: []float64{1, 1}, Response: []float64{0}},
+	}
+	network := deep.NewNeural(&deep.Config{
+		Inputs:     2,
+		Layout:     []int{4, 1},
+		Activation: deep.ActivationTanh,
+		Mode:       deep.ModeBinary,
+		Weight:     deep.NewUniform(0.5, 0),
+		Bias:       true,
+	})
+	solver := &recordingSolver{SGD: training.NewSGD(0.1, 0.9, 1e-3, false), epochs: make(map[int]bool)}
+	trainer := NewEpochTrainer(solver)
+	before := network.Weights()[0][0][0]
+	for epoch := 0; epoch < 3; epoch++ {
+		trainer.TrainEpoch(network, examples)
+	}
+	if solver.inits != 1 || trainer.Epoch() != 3 {
+		t.Errorf("solver initialised %d times over %d epochs, want once over 3", solver.inits, trainer.Epoch())
+	}
+	if len(solver.epochs) != 3 || !solver.epochs[1] || !solver.epochs[3] {
+		t.Errorf("updates of epochs %v, want 1 to 3", solver.epochs)
+	}
+	if network.Weights()[0][0][0] == before {
+		t.Error("training did not change the weights")
+	}
+
+	// another network starts over
+	trainer.TrainEpoch(deep.NewNeural(network.Config), examples)
+	if solver.inits != 2 || trainer.Epoch() != 1 {
+		t.Errorf("solver initialised %d times with the epoch at %d, want twice and 1", solver.inits, trainer.Epoch())
+	}
+}
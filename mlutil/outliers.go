@@ -0,0 +1,568 @@
+package mlutil
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// OutlierMethod selects how an OutlierDetector computes the fences outside of which values are outliers.
+type OutlierMethod string
+
+const (
+	// OutlierIQR uses Tukey's fences, Q1 - k*IQR and Q3 + k*IQR, with k = 1.5 by default. A column whose IQR is
+	// zero has no outliers: its fences are its minimum and maximum.
+	OutlierIQR OutlierMethod = "iqr"
+	// OutlierZScore flags the values more than k standard deviations from the mean, with k = 3 by default.
+	OutlierZScore OutlierMethod = "zscore"
+	// OutlierMAD flags the values whose modified z-score, 0.6745 * (x - median) / MAD, is more than k in absolute
+	// value, with k = 3.5 by default. Unlike the z-score it is not inflated by the outliers themselves. If more than
+	// half the values are equal, the MAD is zero and 1.253314 times the mean absolute deviation from the median is
+	// used instead.
+	OutlierMAD OutlierMethod = "mad"
+	// OutlierPercentile flags the values below the k and above the 1-k quantiles, with k = 0.01 by default.
+	OutlierPercentile OutlierMethod = "percentile"
+)
+
+// defaultThresholds are the thresholds used by each method when an OutlierDetector has none.
+var defaultThresholds = map[OutlierMethod]float64{
+	OutlierIQR:        1.5,
+	OutlierZScore:     3,
+	OutlierMAD:        3.5,
+	OutlierPercentile: 0.01,
+}
+
+// Fences are the bounds of the values of a column that are not outliers.
+type Fences struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// OutlierDetector learns the fences of numeric columns from the training data with Fit, and flags the rows of any
+// dataframe with values outside of them. Missing values are never outliers.
+type OutlierDetector struct {
+	Method OutlierMethod `json:"method"`
+	// Threshold is the parameter k of the method. If zero, the default of the method is used.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Columns are the columns to check. If empty, Fit checks every numeric column.
+	Columns []string `json:"columns,omitempty"`
+
+	// Fences are the fitted bounds of each column.
+	Fences map[string]Fences `json:"fences"`
+}
+
+// NewOutlierDetector returns a detector of the outliers of the given columns, using method with threshold k (0 for
+// the default of the method).
+func NewOutlierDetector(method OutlierMethod, k float64, cols ...string) *OutlierDetector {
+	return &OutlierDetector{Method: method, Threshold: k, Columns: cols}
+}
+
+// Fit learns the fences of each column from its non-missing values in df.
+func (d *OutlierDetector) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	k := d.Threshold
+	if k == 0 {
+		k = defaultThresholds[d.Method]
+	}
+	if k < 0 || (d.Method == OutlierPercentile && k >= 0.5) {
+		return fmt.Errorf("mlutil: invalid %s outlier threshold %v", d.Method, k)
+	}
+	cols := d.Columns
+	if len(cols) == 0 {
+		cols = numericColumns(df)
+	}
+	fences := make(map[string]Fences, len(cols))
+	for _, col := range cols {
+		v, err := presentFloats(df, col)
+		if err != nil {
+			return err
+		}
+		if len(v) == 0 {
+			return fmt.Errorf("mlutil: column %q has no values to find outliers in", col)
+		}
+		sort.Float64s(v)
+		switch d.Method {
+		case OutlierIQR:
+			q1, q3 := quantile(v, 0.25), quantile(v, 0.75)
+			if q1 == q3 {
+				// every value would be an outlier but the quartile itself
+				fences[col] = Fences{v[0], v[len(v)-1]}
+				continue
+			}
+			fences[col] = Fences{q1 - k*(q3-q1), q3 + k*(q3-q1)}
+		case OutlierZScore:
+			m := mean(v)
+			var ss float64
+			for _, x := range v {
+				ss += (x - m) * (x - m)
+			}
+			sd := math.Sqrt(ss / float64(len(v)))
+			fences[col] = Fences{m - k*sd, m + k*sd}
+		case OutlierMAD:
+			med := quantile(v, 0.5)
+			deviations := make([]float64, len(v), len(v))
+			for i, x := range v {
+				deviations[i] = math.Abs(x - med)
+			}
+			spread := median(deviations) / 0.6745
+			if spread == 0 {
+				spread = 1.253314 * mean(deviations)
+			}
+			fences[col] = Fences{med - k*spread, med + k*spread}
+		case OutlierPercentile:
+			fences[col] = Fences{quantile(v, k), quantile(v, 1-k)}
+		default:
+			return fmt.Errorf("mlutil: unknown outlier method %q", d.Method)
+		}
+	}
+	d.Fences = fences
+	return nil
+}
+
+// Mask returns whether each row of df has a value outside the fences of any fitted column, and a report of the
+// outliers in each column.
+func (d *OutlierDetector) Mask(df dataframe.DataFrame) ([]bool, OutlierReport, error) {
+	if d.Fences == nil {
+		return nil, OutlierReport{}, ErrNotFitted
+	}
+	if df.Err != nil {
+		return nil, OutlierReport{}, df.Err
+	}
+	mask := make([]bool, df.Nrow(), df.Nrow())
+	report := OutlierReport{Rows: df.Nrow()}
+	for _, col := range sortedFences(d.Fences) {
+		c := df.Col(col)
+		if c.Err != nil {
+			return nil, OutlierReport{}, c.Err
+		}
+		f := d.Fences[col]
+		counts := ColumnOutliers{Column: col, Fences: f}
+		for i, x := range c.Float() {
+			if isMissing(c, i) {
+				continue
+			}
+			switch {
+			case x < f.Lower:
+				counts.Below++
+			case x > f.Upper:
+				counts.Above++
+			default:
+				continue
+			}
+			mask[i] = true
+		}
+		report.Columns = append(report.Columns, counts)
+	}
+	report.Removed = countTrue(mask)
+	return mask, report, nil
+}
+
+// Filter returns df without the rows flagged by Mask, and the report of Mask.
+func (d *OutlierDetector) Filter(df dataframe.DataFrame) (dataframe.DataFrame, OutlierReport, error) {
+	mask, report, err := d.Mask(df)
+	if err != nil {
+		return df, report, err
+	}
+	df, err = DropRows(df, mask)
+	return df, report, err
+}
+
+// Winsorizer is a transformer that clips the values of each column to the fences of an OutlierDetector instead of
+// dropping the rows, so it can be used in a Pipeline and at inference time.
+type Winsorizer struct {
+	OutlierDetector
+}
+
+// NewWinsorizer returns a transformer clipping the given columns to their p and 1-p quantiles in the training data.
+func NewWinsorizer(p float64, cols ...string) *Winsorizer {
+	return &Winsorizer{OutlierDetector{Method: OutlierPercentile, Threshold: p, Columns: cols}}
+}
+
+// Transform clips the values of each fitted column of df to its fences.
+func (w *Winsorizer) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if w.Fences == nil {
+		return df, ErrNotFitted
+	}
+	if df.Err != nil {
+		return df, df.Err
+	}
+	for _, col := range sortedFences(w.Fences) {
+		c := df.Col(col)
+		if c.Err != nil {
+			return df, c.Err
+		}
+		f := w.Fences[col]
+		v := c.Float()
+		for i := range v {
+			v[i] = math.Min(math.Max(v[i], f.Lower), f.Upper)
+		}
+		df = df.Mutate(series.New(v, series.Float, col))
+	}
+	return df, df.Err
+}
+
+func (w *Winsorizer) transformerKind() string {
+	return "winsorizer"
+}
+
+// ColumnOutliers counts the values of a column below and above its fences.
+type ColumnOutliers struct {
+	Column string
+	Fences Fences
+	Below  int
+	Above  int
+}
+
+// OutlierReport summarises the outliers found in a dataframe. Removed counts the rows flagged in any column, so it
+// can be less than the sum of the counts of the columns.
+type OutlierReport struct {
+	Rows    int
+	Removed int
+	// Columns has the counts of each checked column. It is empty for methods that score whole rows, such as the
+	// IsolationForest.
+	Columns []ColumnOutliers
+}
+
+// String formats the report as a table with a row per column.
+func (r OutlierReport) String() string {
+	var b bytes.Buffer
+	if len(r.Columns) > 0 {
+		width := len("column")
+		for _, c := range r.Columns {
+			if len(c.Column) > width {
+				width = len(c.Column)
+			}
+		}
+		fmt.Fprintf(&b, "%-*s %12s %12s %8s %8s\n", width, "column", "lower", "upper", "below", "above")
+		for _, c := range r.Columns {
+			fmt.Fprintf(&b, "%-*s %12.4g %12.4g %8d %8d\n", width, c.Column, c.Fences.Lower, c.Fences.Upper, c.Below, c.Above)
+		}
+	}
+	fmt.Fprintf(&b, "Rows removed: %d of %d (%.2f%%)\n", r.Removed, r.Rows, 100*safeDivide(float64(r.Removed), float64(r.Rows)))
+	return b.String()
+}
+
+// DropRows returns df without the rows for which mask is true.
+func DropRows(df dataframe.DataFrame, mask []bool) (dataframe.DataFrame, error) {
+	if df.Err != nil {
+		return df, df.Err
+	}
+	if len(mask) != df.Nrow() {
+		return df, fmt.Errorf("mlutil: mask has %d rows, dataframe has %d", len(mask), df.Nrow())
+	}
+	keep := []int{}
+	for i := range mask {
+		if !mask[i] {
+			keep = append(keep, i)
+		}
+	}
+	df = df.Subset(keep)
+	return df, df.Err
+}
+
+// IsolationForest scores how easily each row is isolated by random axis-parallel splits (Liu et al., 2008). Outliers
+// are isolated in few splits, so they get scores close to 1, while scores well below 0.5 are normal.
+type IsolationForest struct {
+	Trees      int
+	SampleSize int
+	Seed       int64
+	// Columns are the numeric columns to score on. If empty, Fit uses every numeric column.
+	Columns []string
+	// Contamination is the expected share of outliers. If it is positive, Fit sets Threshold so that this share of
+	// the training rows is flagged.
+	Contamination float64
+	// Threshold is the score above which Mask flags a row. If zero, 0.6 is used.
+	Threshold float64
+
+	fitted []string
+	trees  []isolationTree
+}
+
+// NewIsolationForest returns an isolation forest of the given number of trees, each grown on sampleSize rows. 100
+// trees of 256 rows are the usual choice.
+func NewIsolationForest(trees, sampleSize int, seed int64) *IsolationForest {
+	return &IsolationForest{Trees: trees, SampleSize: sampleSize, Seed: seed}
+}
+
+// Fit grows the trees on random samples of the rows of df. Rows with missing values are left out.
+func (f *IsolationForest) Fit(df dataframe.DataFrame) error {
+	if f.Trees < 1 || f.SampleSize < 2 {
+		return fmt.Errorf("mlutil: cannot grow %d isolation trees on %d rows", f.Trees, f.SampleSize)
+	}
+	cols := f.Columns
+	if len(cols) == 0 {
+		cols = numericColumns(df)
+	}
+	x, err := f.rows(df, cols)
+	if err != nil {
+		return err
+	}
+	var complete [][]float64
+	for _, row := range x {
+		if row != nil {
+			complete = append(complete, row)
+		}
+	}
+	if len(complete) < 2 {
+		return fmt.Errorf("mlutil: need at least 2 complete rows to fit an isolation forest, got %d", len(complete))
+	}
+	size := f.SampleSize
+	if size > len(complete) {
+		size = len(complete)
+	}
+	rnd := rand.New(rand.NewSource(f.Seed))
+	limit := int(math.Ceil(math.Log2(float64(size))))
+	f.fitted, f.trees = cols, make([]isolationTree, f.Trees, f.Trees)
+	for t := range f.trees {
+		perm := rnd.Perm(len(complete))[:size]
+		sample := make([][]float64, size, size)
+		for i, j := range perm {
+			sample[i] = complete[j]
+		}
+		f.trees[t] = isolationTree{size: size}
+		f.trees[t].grow(sample, 0, limit, rnd)
+	}
+	if f.Contamination > 0 {
+		if f.Contamination >= 1 {
+			return fmt.Errorf("mlutil: contamination %v is not below 1", f.Contamination)
+		}
+		scores := make([]float64, len(complete), len(complete))
+		for i := range complete {
+			scores[i] = f.score(complete[i])
+		}
+		sort.Float64s(scores)
+		f.Threshold = quantile(scores, 1-f.Contamination)
+	}
+	return nil
+}
+
+// Scores returns the anomaly score of every row of df, or NaN for rows with missing values.
+func (f *IsolationForest) Scores(df dataframe.DataFrame) ([]float64, error) {
+	if f.trees == nil {
+		return nil, ErrNotFitted
+	}
+	x, err := f.rows(df, f.fitted)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]float64, len(x), len(x))
+	for i, row := range x {
+		if row == nil {
+			ret[i] = math.NaN()
+			continue
+		}
+		ret[i] = f.score(row)
+	}
+	return ret, nil
+}
+
+// Mask returns whether the score of each row of df is above the threshold, and a report of the rows flagged.
+func (f *IsolationForest) Mask(df dataframe.DataFrame) ([]bool, OutlierReport, error) {
+	scores, err := f.Scores(df)
+	if err != nil {
+		return nil, OutlierReport{}, err
+	}
+	threshold := f.Threshold
+	if threshold == 0 {
+		threshold = 0.6
+	}
+	mask := make([]bool, len(scores), len(scores))
+	for i, s := range scores {
+		mask[i] = s > threshold
+	}
+	return mask, OutlierReport{Rows: len(mask), Removed: countTrue(mask)}, nil
+}
+
+// Filter returns df without the rows flagged by Mask, and the report of Mask.
+func (f *IsolationForest) Filter(df dataframe.DataFrame) (dataframe.DataFrame, OutlierReport, error) {
+	mask, report, err := f.Mask(df)
+	if err != nil {
+		return df, report, err
+	}
+	df, err = DropRows(df, mask)
+	return df, report, err
+}
+
+// score returns the anomaly score 2^(-E[h(x)] / c(n)) of x, where h is the path length in a tree and c(n) the
+// average path length of an unsuccessful search in a binary search tree of n = SampleSize rows.
+func (f *IsolationForest) score(x []float64) float64 {
+	var sum float64
+	for i := range f.trees {
+		sum += f.trees[i].pathLength(x)
+	}
+	return math.Pow(2, -sum/float64(len(f.trees))/averagePathLength(f.trees[0].size))
+}
+
+// rows returns the given columns of df as rows, with nil for the rows that have missing values.
+func (f *IsolationForest) rows(df dataframe.DataFrame, cols []string) ([][]float64, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	ret := make([][]float64, df.Nrow(), df.Nrow())
+	for i := range ret {
+		ret[i] = make([]float64, len(cols), len(cols))
+	}
+	for j, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return nil, c.Err
+		}
+		if c.Type() != series.Float && c.Type() != series.Int {
+			return nil, fmt.Errorf("mlutil: cannot score non-numeric column %q", col)
+		}
+		for i := range ret {
+			if ret[i] == nil {
+				continue
+			}
+			if isMissing(c, i) {
+				ret[i] = nil
+				continue
+			}
+			ret[i][j] = c.Elem(i).Float()
+		}
+	}
+	return ret, nil
+}
+
+// isolationTree is a tree of random splits stored as a slice of nodes, the root first.
+type isolationTree struct {
+	size  int
+	nodes []isolationNode
+}
+
+// isolationNode is a split of feature at value, or a leaf holding size rows if left is 0.
+type isolationNode struct {
+	feature     int
+	value       float64
+	left, right int
+	size        int
+}
+
+// grow adds the subtree isolating x to t and returns the index of its root.
+func (t *isolationTree) grow(x [][]float64, depth, limit int, rnd *rand.Rand) int {
+	ix := len(t.nodes)
+	t.nodes = append(t.nodes, isolationNode{size: len(x)})
+	if depth >= limit || len(x) < 2 {
+		return ix
+	}
+	// choose among the features that are not constant in x
+	var features []int
+	for j := range x[0] {
+		min, max := columnRange(x, j)
+		if min < max {
+			features = append(features, j)
+		}
+	}
+	if len(features) == 0 {
+		return ix
+	}
+	feature := features[rnd.Intn(len(features))]
+	min, max := columnRange(x, feature)
+	value := min + rnd.Float64()*(max-min)
+	var left, right [][]float64
+	for _, row := range x {
+		if row[feature] < value {
+			left = append(left, row)
+		} else {
+			right = append(right, row)
+		}
+	}
+	l := t.grow(left, depth+1, limit, rnd)
+	r := t.grow(right, depth+1, limit, rnd)
+	t.nodes[ix] = isolationNode{feature: feature, value: value, left: l, right: r, size: len(x)}
+	return ix
+}
+
+// pathLength returns the number of splits that isolate x, plus the expected path length of the rows left in the
+// leaf it ends in.
+func (t *isolationTree) pathLength(x []float64) float64 {
+	var depth float64
+	n := t.nodes[0]
+	for n.left != 0 {
+		if x[n.feature] < n.value {
+			n = t.nodes[n.left]
+		} else {
+			n = t.nodes[n.right]
+		}
+		depth++
+	}
+	return depth + averagePathLength(n.size)
+}
+
+// averagePathLength returns c(n), the average path length of an unsuccessful search in a binary search tree of n
+// elements.
+func averagePathLength(n int) float64 {
+	switch {
+	case n < 2:
+		return 0
+	case n == 2:
+		return 1
+	}
+	harmonic := math.Log(float64(n-1)) + 0.5772156649
+	return 2*harmonic - 2*float64(n-1)/float64(n)
+}
+
+// columnRange returns the minimum and maximum of column j of x.
+func columnRange(x [][]float64, j int) (min, max float64) {
+	min, max = x[0][j], x[0][j]
+	for _, row := range x[1:] {
+		min = math.Min(min, row[j])
+		max = math.Max(max, row[j])
+	}
+	return min, max
+}
+
+// presentFloats returns the non-missing values of column col of df.
+func presentFloats(df dataframe.DataFrame, col string) ([]float64, error) {
+	c := df.Col(col)
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	if c.Type() != series.Float && c.Type() != series.Int {
+		return nil, fmt.Errorf("mlutil: column %q is not numeric", col)
+	}
+	var ret []float64
+	for i, x := range c.Float() {
+		if !isMissing(c, i) {
+			ret = append(ret, x)
+		}
+	}
+	return ret, nil
+}
+
+// quantile returns the p quantile of the sorted values s, interpolating linearly between the closest ranks.
+func quantile(s []float64, p float64) float64 {
+	pos := p * float64(len(s)-1)
+	i := int(pos)
+	if i >= len(s)-1 {
+		return s[len(s)-1]
+	}
+	return s[i] + (pos-float64(i))*(s[i+1]-s[i])
+}
+
+// countTrue returns the number of true values in mask.
+func countTrue(mask []bool) int {
+	var n int
+	for _, b := range mask {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// sortedFences returns the keys of m in increasing order.
+func sortedFences(m map[string]Fences) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,167 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"testing"
+)
+
+// spikeFrame returns a dataframe of a column x holding 1 to 5, a spike of 100 and a missing value.
+func spikeFrame() dataframe.DataFrame {
+	return dataframe.New(series.New([]float64{1, 2, 3, 4, 5, 100, math.NaN()}, series.Float, "x"))
+}
+
+func TestOutlierDetector(t *testing.T) {
+	tests := []struct {
+		method       OutlierMethod
+		k            float64
+		want         Fences
+		below, above int
+	}{
+		// the quartiles are 2.25 and 4.75
+		{OutlierIQR, 0, Fences{2.25 - 1.5*2.5, 4.75 + 1.5*2.5}, 0, 1},
+		// the median is 3.5 and the median absolute deviation 1.5
+		{OutlierMAD, 0, Fences{3.5 - 3.5*1.5/0.6745, 3.5 + 3.5*1.5/0.6745}, 0, 1},
+		// the spike inflates the standard deviation so much that it is within 3 of the mean, but not within 2
+		{OutlierZScore, 2, Fences{}, 0, 1},
+		{OutlierZScore, 0, Fences{}, 0, 0},
+		{OutlierPercentile, 0.2, Fences{2, 5}, 1, 1},
+	}
+	for _, tt := range tests {
+		d := NewOutlierDetector(tt.method, tt.k, "x")
+		if err := d.Fit(spikeFrame()); err != nil {
+			t.Fatal(err)
+		}
+		if f := d.Fences["x"]; tt.want != (Fences{}) && (!closeTo(f.Lower, tt.want.Lower) || !closeTo(f.Upper, tt.want.Upper)) {
+			t.Errorf("%s fences %+v, want %+v", tt.method, f, tt.want)
+		}
+		mask, report, err := d.Mask(spikeFrame())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := report.Columns[0]; c.Below != tt.below || c.Above != tt.above || report.Removed != tt.below+tt.above {
+			t.Errorf("%s with threshold %v flags %d below and %d above, want %d and %d", tt.method, tt.k, c.Below, c.Above, tt.below, tt.above)
+		}
+		if mask[6] {
+			t.Errorf("%s flags the missing value", tt.method)
+		}
+	}
+
+	d := NewOutlierDetector(OutlierIQR, 0)
+	if _, _, err := d.Mask(spikeFrame()); err != ErrNotFitted {
+		t.Errorf("Mask() before Fit() returned %v, want ErrNotFitted", err)
+	}
+	if err := d.Fit(spikeFrame()); err != nil {
+		t.Fatal(err)
+	}
+	out, report, err := d.Filter(spikeFrame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Nrow() != 6 || report.Rows != 7 || report.Removed != 1 {
+		t.Errorf("Filter() kept %d rows and removed %d of %d, want 6 kept and 1 of 7 removed", out.Nrow(), report.Removed, report.Rows)
+	}
+	if err := NewOutlierDetector(OutlierPercentile, 0.5).Fit(spikeFrame()); err == nil {
+		t.Error("Fit() with a percentile of 0.5 succeeded, want an error")
+	}
+	if err := NewOutlierDetector(OutlierMethod("grubbs"), 0).Fit(spikeFrame()); err == nil {
+		t.Error("Fit() with an unknown method succeeded, want an error")
+	}
+}
+
+func TestOutlierDetectorZeroSpread(t *testing.T) {
+	// most values are 5, so the IQR and the MAD are zero
+	df := dataframe.New(series.New([]float64{5, 5, 5, 5, 5, 5, 5, 6, 50}, series.Float, "x"))
+	tests := []struct {
+		method OutlierMethod
+		want   Fences
+	}{
+		{OutlierIQR, Fences{5, 50}},
+		// the mean absolute deviation from 5 is 46/9
+		{OutlierMAD, Fences{5 - 3.5*1.253314*46/9, 5 + 3.5*1.253314*46/9}},
+	}
+	for _, tt := range tests {
+		d := NewOutlierDetector(tt.method, 0, "x")
+		if err := d.Fit(df); err != nil {
+			t.Fatal(err)
+		}
+		if f := d.Fences["x"]; !closeTo(f.Lower, tt.want.Lower) || !closeTo(f.Upper, tt.want.Upper) {
+			t.Errorf("%s fences %+v, want %+v", tt.method, f, tt.want)
+		}
+		mask, _, err := d.Mask(df)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range mask[:8] {
+			if mask[i] {
+				t.Errorf("%s flags row %d, %v", tt.method, i, df.Col("x").Float()[i])
+			}
+		}
+	}
+}
+
+func TestWinsorizer(t *testing.T) {
+	w := NewWinsorizer(0.2, "x")
+	if _, err := w.Transform(spikeFrame()); err != ErrNotFitted {
+		t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+	}
+	p := NewPipeline(w)
+	if err := p.Fit(spikeFrame()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Pipeline
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	out, err := decoded.Transform(spikeFrame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumns(t, out, wantColumn{name: "x", typ: series.Float, floats: []float64{2, 2, 3, 4, 5, 5, math.NaN()}})
+}
+
+func TestIsolationForest(t *testing.T) {
+	// a grid of 25 points around the origin and one far away
+	var a, b []float64
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 5; j++ {
+			a, b = append(a, float64(i)), append(b, float64(j))
+		}
+	}
+	a, b = append(a, 40), append(b, -40)
+	df := dataframe.New(series.New(a, series.Float, "a"), series.New(b, series.Float, "b"))
+
+	f := NewIsolationForest(100, 16, 3)
+	if _, err := f.Scores(df); err != ErrNotFitted {
+		t.Errorf("Scores() before Fit() returned %v, want ErrNotFitted", err)
+	}
+	f.Contamination = 1.0 / 26
+	if err := f.Fit(df); err != nil {
+		t.Fatal(err)
+	}
+	scores, err := f.Scores(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range scores[:25] {
+		if s >= scores[25] {
+			t.Errorf("row %d scores %v, not below the %v of the far point", i, s, scores[25])
+		}
+	}
+	mask, report, err := f.Mask(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mask[25] || report.Removed != 1 {
+		t.Errorf("Mask() flags %d rows, want only the far point", report.Removed)
+	}
+	if err := NewIsolationForest(0, 16, 3).Fit(df); err == nil {
+		t.Error("Fit() of no trees succeeded, want an error")
+	}
+}
@@ -0,0 +1,144 @@
+package mlutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// PCA is a Transformer that replaces numeric columns by their projections onto the first principal components of the
+// training data, named PC1, PC2, ... The columns are centred but not scaled, so columns with different units should
+// usually be standardised first.
+type PCA struct {
+	// Columns are the columns to project. If empty, Fit uses every numeric column.
+	Columns []string `json:"columns,omitempty"`
+	// Components is the number of components kept by Transform. 0 keeps all of them.
+	Components int `json:"components"`
+
+	// Inputs are the fitted columns, Mean their means, and Vectors the principal components over them in order of
+	// decreasing variance. Variances are the variances along all the components.
+	Inputs    []string    `json:"inputs"`
+	Mean      []float64   `json:"mean"`
+	Vectors   [][]float64 `json:"vectors"`
+	Variances []float64   `json:"variances"`
+}
+
+// NewPCA returns a PCA keeping the first components principal components of the given columns.
+func NewPCA(components int, cols ...string) *PCA {
+	return &PCA{Columns: cols, Components: components}
+}
+
+// Fit finds the principal components of the columns of df.
+func (p *PCA) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	cols := p.Columns
+	if len(cols) == 0 {
+		cols = numericColumns(df)
+	}
+	if len(cols) == 0 {
+		return errors.New("mlutil: no columns for PCA")
+	}
+	if p.Components < 0 || p.Components > len(cols) {
+		return fmt.Errorf("mlutil: cannot keep %d components of %d columns", p.Components, len(cols))
+	}
+	x, err := p.matrix(df, cols)
+	if err != nil {
+		return err
+	}
+	if df.Nrow() < 2 {
+		return errors.New("mlutil: PCA needs at least two rows")
+	}
+	var pc stat.PC
+	if ok := pc.PrincipalComponents(x, nil); !ok {
+		return errors.New("mlutil: principal component analysis failed")
+	}
+	var vectors mat.Dense
+	pc.VectorsTo(&vectors)
+
+	mean := make([]float64, len(cols), len(cols))
+	for j := range cols {
+		mean[j] = stat.Mean(mat.Col(nil, j, x), nil)
+	}
+	_, n := vectors.Dims()
+	p.Vectors = make([][]float64, n, n)
+	for i := range p.Vectors {
+		p.Vectors[i] = mat.Col(nil, i, &vectors)
+	}
+	p.Inputs, p.Mean, p.Variances = append([]string(nil), cols...), mean, pc.VarsTo(nil)
+	return nil
+}
+
+// Transform replaces the fitted columns of df by their projections onto the kept components.
+func (p *PCA) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if p.Vectors == nil {
+		return df, ErrNotFitted
+	}
+	if df.Err != nil {
+		return df, df.Err
+	}
+	x, err := p.matrix(df, p.Inputs)
+	if err != nil {
+		return df, err
+	}
+	k := p.Components
+	if k == 0 || k > len(p.Vectors) {
+		k = len(p.Vectors)
+	}
+	rows := df.Nrow()
+	projections := make([][]float64, k, k)
+	for i := range projections {
+		projections[i] = make([]float64, rows, rows)
+		for r := 0; r < rows; r++ {
+			var v float64
+			for j, w := range p.Vectors[i] {
+				v += (x.At(r, j) - p.Mean[j]) * w
+			}
+			projections[i][r] = v
+		}
+	}
+	df = df.Drop(p.Inputs)
+	for i := range projections {
+		df = df.Mutate(series.New(projections[i], series.Float, fmt.Sprintf("PC%d", i+1)))
+	}
+	return df, df.Err
+}
+
+// ExplainedVarianceRatio returns the fraction of the total variance along each component.
+func (p *PCA) ExplainedVarianceRatio() []float64 {
+	var total float64
+	for _, v := range p.Variances {
+		total += v
+	}
+	ret := make([]float64, len(p.Variances), len(p.Variances))
+	for i, v := range p.Variances {
+		ret[i] = safeDivide(v, total)
+	}
+	return ret
+}
+
+// matrix returns the given columns of df as a matrix.
+func (p *PCA) matrix(df dataframe.DataFrame, cols []string) (*mat.Dense, error) {
+	if df.Nrow() == 0 {
+		return nil, errors.New("mlutil: no rows for PCA")
+	}
+	x := mat.NewDense(df.Nrow(), len(cols), nil)
+	for j, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return nil, c.Err
+		}
+		for i, v := range c.Float() {
+			x.Set(i, j, v)
+		}
+	}
+	return x, nil
+}
+
+func (p *PCA) transformerKind() string {
+	return "pca"
+}
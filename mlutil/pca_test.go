@@ -0,0 +1,60 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"testing"
+)
+
+func TestPCA(t *testing.T) {
+	// the points lie on the line b = 2a
+	df := dataframe.New(
+		series.New([]float64{-2, -1, 0, 1, 2}, series.Float, "a"),
+		series.New([]float64{-4, -2, 0, 2, 4}, series.Float, "b"),
+		series.New([]string{"p", "q", "r", "s", "t"}, series.String, "id"),
+	)
+	p := NewPCA(1)
+	if _, err := p.Transform(df); err != ErrNotFitted {
+		t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+	}
+	if err := p.Fit(df); err != nil {
+		t.Fatal(err)
+	}
+	if r := p.ExplainedVarianceRatio(); len(r) != 2 || !closeTo(r[0], 1) || !closeTo(r[1], 0) {
+		t.Errorf("explained variance ratios %v, want all of it along the first component", r)
+	}
+	// the first component is along (1, 2) up to its sign
+	if v := p.Vectors[0]; !closeTo(math.Abs(v[1]/v[0]), 2) {
+		t.Errorf("first component %v, want it along (1, 2)", v)
+	}
+
+	var decoded Pipeline
+	data, err := json.Marshal(NewPipeline(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	out, err := decoded.Transform(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Ncol() != 2 || out.Col("PC1").Err != nil || out.Col("id").Err != nil {
+		t.Fatalf("columns %v, want id and PC1", out.Names())
+	}
+	// the projections are centred and symmetric, like the points
+	pc := out.Col("PC1").Float()
+	if !closeTo(pc[2], 0) || !closeTo(pc[0], -pc[4]) || !closeTo(math.Abs(pc[4]), math.Sqrt(20)) {
+		t.Errorf("projections %v, want 0 in the middle and ±√20 at the ends", pc)
+	}
+
+	if err := NewPCA(3, "a", "b").Fit(df); err == nil {
+		t.Error("Fit() of more components than columns succeeded, want an error")
+	}
+	if err := NewPCA(1).Fit(df.Subset([]int{0})); err == nil {
+		t.Error("Fit() on a single row succeeded, want an error")
+	}
+}
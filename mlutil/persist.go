@@ -0,0 +1,138 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FormatVersion is the version of the model file format written by SaveModel. Files written by a newer version are
+// rejected by LoadModel rather than being misread.
+const FormatVersion = 1
+
+// ModelMetadata is stored alongside the weights of a persisted model so that it can be served without access to the
+// program that trained it.
+type ModelMetadata struct {
+	// Version, Kind, Library and Created are filled in by WriteModel.
+	Version int       `json:"version"`
+	Kind    string    `json:"kind"`
+	Library string    `json:"library"`
+	Created time.Time `json:"created"`
+
+	// Features are the names of the input columns, in the order the model expects them. If the model has
+	// Preprocessing, they are the inputs of the preprocessing pipeline.
+	Features []string `json:"features,omitempty"`
+	// Target is the name of the column the model predicts.
+	Target string `json:"target,omitempty"`
+	// Labels are the names of the classes of a classifier, indexed by class label.
+	Labels []string `json:"labels,omitempty"`
+	// Preprocessing holds the parameters of any transformation that has to be applied to the inputs before they
+	// reach the model, encoded as JSON. It is usually a Pipeline, which is decoded by the Pipeline method.
+	Preprocessing json.RawMessage `json:"preprocessing,omitempty"`
+	// Metrics are the scores the model achieved when it was trained, such as the validation accuracy.
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// persistentModel is implemented by the Model adapters that can be saved with SaveModel.
+type persistentModel interface {
+	Model
+	// modelKind returns the kind recorded in the file and the library that implements the model.
+	modelKind() (kind, library string)
+	marshalModel() ([]byte, error)
+	unmarshalModel(data []byte) error
+}
+
+// modelKinds maps every kind that can be loaded to a function returning an empty model of that kind.
+var modelKinds = map[string]func() persistentModel{
+	"least_squares": func() persistentModel { return &LeastSquaresModel{} },
+	"logistic":      func() persistentModel { return &LogisticModel{} },
+	"softmax":       func() persistentModel { return &SoftmaxModel{} },
+	"ols":           func() persistentModel { return &OLSModel{} },
+	"forest":        func() persistentModel { return &ForestModel{} },
+	"svm":           func() persistentModel { return &SVMModel{} },
+	"neural":        func() persistentModel { return &NeuralModel{} },
+	"kmeans":        func() persistentModel { return &KMeansModel{} },
+	"tree":          func() persistentModel { return &TreeModel{} },
+	"random_forest": func() persistentModel { return &RandomForestModel{} },
+	"gbdt":          func() persistentModel { return &GBDTModel{} },
+}
+
+// modelFile is the layout of a persisted model: the metadata followed by the model specific weights.
+type modelFile struct {
+	ModelMetadata
+	Model json.RawMessage `json:"model"`
+}
+
+// SaveModel writes the fitted model m and its metadata to path, creating any missing directories.
+func SaveModel(path string, m Model, meta ModelMetadata) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteModel(f, m, meta); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteModel writes the fitted model m and its metadata to w as JSON.
+func WriteModel(w io.Writer, m Model, meta ModelMetadata) error {
+	pm, ok := m.(persistentModel)
+	if !ok {
+		return fmt.Errorf("mlutil: cannot persist models of type %T", m)
+	}
+	data, err := pm.marshalModel()
+	if err != nil {
+		return err
+	}
+	meta.Version = FormatVersion
+	meta.Kind, meta.Library = pm.modelKind()
+	if meta.Created.IsZero() {
+		meta.Created = time.Now().UTC()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(modelFile{ModelMetadata: meta, Model: data})
+}
+
+// LoadModel reads a model written by SaveModel from path.
+func LoadModel(path string) (Model, ModelMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ModelMetadata{}, err
+	}
+	defer f.Close()
+	return ReadModel(f)
+}
+
+// ReadModel reads a model written by WriteModel from r.
+func ReadModel(r io.Reader) (Model, ModelMetadata, error) {
+	var file modelFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, ModelMetadata{}, err
+	}
+	meta := file.ModelMetadata
+	if meta.Version < 1 || meta.Version > FormatVersion {
+		return nil, meta, fmt.Errorf("mlutil: unsupported model file version %d", meta.Version)
+	}
+	newModel, ok := modelKinds[meta.Kind]
+	if !ok {
+		return nil, meta, fmt.Errorf("mlutil: unknown model kind %q", meta.Kind)
+	}
+	if len(file.Model) == 0 {
+		return nil, meta, errors.New("mlutil: model file has no weights")
+	}
+	m := newModel()
+	if err := m.unmarshalModel(file.Model); err != nil {
+		return nil, meta, fmt.Errorf("mlutil: reading %s model: %v", meta.Kind, err)
+	}
+	return m, meta, nil
+}
@@ -0,0 +1,92 @@
+package mlutil
+
+import (
+	"bytes"
+	"github.com/datastream/libsvm"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModelRoundTrip(t *testing.T) {
+	x := [][]float64{{0, 1}, {1, 0}, {2, 2}, {3, 1}, {8, 9}, {9, 8}, {9, 9}, {8, 8}}
+	y := []float64{0, 0, 0, 0, 1, 1, 1, 1}
+	probes := [][]float64{{0.5, 0.5}, {8.5, 8}, {4, 5}}
+	models := []struct {
+		kind  string
+		model Model
+	}{
+		{"ols", NewOLSModel()},
+		{"kmeans", NewKMeansModel(2, 50)},
+		{"forest", NewForestModel(5, 0, 1)},
+		{"svm", NewSVMModel(libsvm.SVMParameter{SvmType: libsvm.CSVC, KernelType: libsvm.LINEAR, C: 1, Eps: 1e-3, CacheSize: 10})},
+	}
+	for _, tt := range models {
+		t.Run(tt.kind, func(t *testing.T) {
+			if err := WriteModel(&bytes.Buffer{}, tt.model, ModelMetadata{}); err != ErrNotFitted {
+				t.Errorf("WriteModel() before Fit() returned %v, want ErrNotFitted", err)
+			}
+			if err := tt.model.Fit(x, y); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "models", tt.kind+".json")
+			meta := ModelMetadata{Features: []string{"a", "b"}, Labels: []string{"low", "high"}, Metrics: map[string]float64{"accuracy": 1}}
+			if err := SaveModel(path, tt.model, meta); err != nil {
+				t.Fatal(err)
+			}
+			loaded, gotMeta, err := LoadModel(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotMeta.Version != FormatVersion || gotMeta.Kind != tt.kind || gotMeta.Created.IsZero() {
+				t.Errorf("metadata version %d, kind %q, created %v, want version %d of kind %q with a creation time", gotMeta.Version, gotMeta.Kind, gotMeta.Created, FormatVersion, tt.kind)
+			}
+			if len(gotMeta.Features) != 2 || gotMeta.Labels[1] != "high" || gotMeta.Metrics["accuracy"] != 1 {
+				t.Errorf("metadata %+v lost the features, labels or metrics of %+v", gotMeta, meta)
+			}
+			for _, p := range probes {
+				want, err := tt.model.Predict(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err := loaded.Predict(p); err != nil || !closeTo(got, want) {
+					t.Errorf("loaded model predicts %v, %v for %v, want %v", got, err, p, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadModelErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"not JSON", `model`},
+		{"newer version", `{"version": 2, "kind": "ols", "model": {"coefficients": [1]}}`},
+		{"no version", `{"kind": "ols", "model": {"coefficients": [1]}}`},
+		{"unknown kind", `{"version": 1, "kind": "perceptron", "model": {}}`},
+		{"no weights", `{"version": 1, "kind": "ols"}`},
+		{"empty weights", `{"version": 1, "kind": "ols", "model": {"coefficients": []}}`},
+	}
+	for _, tt := range tests {
+		if _, _, err := ReadModel(strings.NewReader(tt.file)); err == nil {
+			t.Errorf("%s: ReadModel() succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestWriteModelUnsupported(t *testing.T) {
+	if err := WriteModel(&bytes.Buffer{}, modelFunc(nil), ModelMetadata{}); err == nil {
+		t.Error("WriteModel() of a model without a persisted form succeeded, want an error")
+	}
+}
+
+// modelFunc is a Model predicting with a function, which cannot be persisted.
+type modelFunc func(x []float64) float64
+
+func (f modelFunc) Fit(x [][]float64, y []float64) error { return nil }
+
+func (f modelFunc) Predict(x []float64) (float64, error) { return f(x), nil }
+
+func (f modelFunc) PredictProba(x []float64) ([]float64, error) { return nil, ErrNotClassifier }
@@ -0,0 +1,357 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"sort"
+)
+
+// Transformer is a preprocessing step that learns its parameters from the training data with Fit, and then applies
+// the same transformation to any dataframe with Transform, so that no statistics of the validation data leak into
+// training and new data can be transformed at inference time.
+type Transformer interface {
+	Fit(df dataframe.DataFrame) error
+	Transform(df dataframe.DataFrame) (dataframe.DataFrame, error)
+}
+
+// InverseTransformer is implemented by the transformers that can be undone, such as the scalers.
+type InverseTransformer interface {
+	Transformer
+	InverseTransform(df dataframe.DataFrame) (dataframe.DataFrame, error)
+}
+
+// FitTransformer is implemented by the transformers that transform their own training data differently from new
+// data, such as the TargetEncoder. Pipeline.FitTransform uses FitTransform for them.
+type FitTransformer interface {
+	Transformer
+	FitTransform(df dataframe.DataFrame) (dataframe.DataFrame, error)
+}
+
+// persistentTransformer is implemented by the transformers that can be stored in a Pipeline. The transformer itself
+// is encoded as JSON, so its fitted parameters have to be exported.
+type persistentTransformer interface {
+	Transformer
+	transformerKind() string
+}
+
+// transformerKinds maps every kind of transformer that can be decoded to a function returning an empty transformer of
+// that kind.
+var transformerKinds = map[string]func() persistentTransformer{
+	"scaler":            func() persistentTransformer { return &Scaler{} },
+	"onehot":            func() persistentTransformer { return &OneHotEncoder{} },
+	"ordinal":           func() persistentTransformer { return &OrdinalEncoder{} },
+	"frequency":         func() persistentTransformer { return &FrequencyEncoder{} },
+	"target":            func() persistentTransformer { return &TargetEncoder{} },
+	"imputer":           func() persistentTransformer { return &Imputer{} },
+	"knn_imputer":       func() persistentTransformer { return &KNNImputer{} },
+	"missing_indicator": func() persistentTransformer { return &MissingIndicator{} },
+	"winsorizer":        func() persistentTransformer { return &Winsorizer{} },
+	"pca":               func() persistentTransformer { return &PCA{} },
+	"pipeline":          func() persistentTransformer { return &Pipeline{} },
+}
+
+// ScaleMethod selects how a Scaler maps its columns.
+type ScaleMethod string
+
+const (
+	// MinMax maps the values onto the range [0,1] by subtracting the minimum and dividing by max - min.
+	MinMax ScaleMethod = "minmax"
+	// MeanNormalise maps the values onto the range [-1,1] by subtracting the mean and dividing by max - min.
+	MeanNormalise ScaleMethod = "mean"
+	// Standardise subtracts the mean and divides by the standard deviation.
+	Standardise ScaleMethod = "standard"
+)
+
+// Scaler rescales numeric columns as (x - Offset) / Scale, with the offset and scale of each column learned by Fit.
+// Columns that are constant in the training data are only shifted.
+type Scaler struct {
+	Method ScaleMethod `json:"method"`
+	// Columns are the columns to scale. If empty, Fit scales every numeric column.
+	Columns []string `json:"columns,omitempty"`
+
+	// Offset and Scale are the fitted parameters of each column.
+	Offset map[string]float64 `json:"offset"`
+	Scale  map[string]float64 `json:"scale"`
+}
+
+// NewMinMaxScaler returns a scaler mapping the given columns onto the range [0,1].
+func NewMinMaxScaler(cols ...string) *Scaler {
+	return &Scaler{Method: MinMax, Columns: cols}
+}
+
+// NewMeanNormaliser returns a scaler mapping the given columns onto the range [-1,1] around their mean.
+func NewMeanNormaliser(cols ...string) *Scaler {
+	return &Scaler{Method: MeanNormalise, Columns: cols}
+}
+
+// NewStandardScaler returns a scaler giving the given columns zero mean and unit standard deviation.
+func NewStandardScaler(cols ...string) *Scaler {
+	return &Scaler{Method: Standardise, Columns: cols}
+}
+
+// Fit learns the offset and scale of each column from df.
+func (s *Scaler) Fit(df dataframe.DataFrame) error {
+	if df.Err != nil {
+		return df.Err
+	}
+	cols := s.Columns
+	if len(cols) == 0 {
+		cols = numericColumns(df)
+	}
+	offset := make(map[string]float64, len(cols))
+	scale := make(map[string]float64, len(cols))
+	for _, col := range cols {
+		c := df.Col(col)
+		if c.Err != nil {
+			return c.Err
+		}
+		if c.Len() == 0 {
+			return fmt.Errorf("mlutil: cannot fit scaler on empty column %q", col)
+		}
+		switch s.Method {
+		case MinMax:
+			offset[col], scale[col] = c.Min(), c.Max()-c.Min()
+		case MeanNormalise:
+			offset[col], scale[col] = c.Mean(), c.Max()-c.Min()
+		case Standardise:
+			offset[col], scale[col] = c.Mean(), c.StdDev()
+		default:
+			return fmt.Errorf("mlutil: unknown scale method %q", s.Method)
+		}
+		if scale[col] == 0 {
+			scale[col] = 1
+		}
+	}
+	s.Offset, s.Scale = offset, scale
+	return nil
+}
+
+// Transform scales the fitted columns of df.
+func (s *Scaler) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	return s.apply(df, func(x, offset, scale float64) float64 { return (x - offset) / scale })
+}
+
+// InverseTransform maps scaled columns back onto their original range.
+func (s *Scaler) InverseTransform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	return s.apply(df, func(x, offset, scale float64) float64 { return x*scale + offset })
+}
+
+// apply replaces every fitted column of df by f of its values.
+func (s *Scaler) apply(df dataframe.DataFrame, f func(x, offset, scale float64) float64) (dataframe.DataFrame, error) {
+	if s.Offset == nil {
+		return df, ErrNotFitted
+	}
+	if df.Err != nil {
+		return df, df.Err
+	}
+	for _, col := range sortedKeys(s.Offset) {
+		c := df.Col(col)
+		if c.Err != nil {
+			return df, c.Err
+		}
+		v := c.Float()
+		for i := range v {
+			v[i] = f(v[i], s.Offset[col], s.Scale[col])
+		}
+		df = df.Mutate(series.New(v, series.Float, col))
+	}
+	return df, df.Err
+}
+
+func (s *Scaler) transformerKind() string {
+	return "scaler"
+}
+
+// Pipeline applies a sequence of transformers in order, each fitted on the output of the previous one. A pipeline
+// can be encoded as JSON, for example to store it as the Preprocessing of a ModelMetadata, as long as all its steps
+// are transformers from this package.
+type Pipeline struct {
+	Steps []Transformer
+}
+
+// NewPipeline returns a pipeline of the given steps.
+func NewPipeline(steps ...Transformer) *Pipeline {
+	return &Pipeline{Steps: steps}
+}
+
+// Fit fits every step of the pipeline on df transformed by the steps before it.
+func (p *Pipeline) Fit(df dataframe.DataFrame) error {
+	_, err := p.FitTransform(df)
+	return err
+}
+
+// FitTransform fits the pipeline on df and returns df transformed by it.
+func (p *Pipeline) FitTransform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	for i, step := range p.Steps {
+		var err error
+		if ft, ok := step.(FitTransformer); ok {
+			if df, err = ft.FitTransform(df); err != nil {
+				return df, fmt.Errorf("mlutil: fitting pipeline step %d: %v", i, err)
+			}
+			continue
+		}
+		if err = step.Fit(df); err != nil {
+			return df, fmt.Errorf("mlutil: fitting pipeline step %d: %v", i, err)
+		}
+		if df, err = step.Transform(df); err != nil {
+			return df, fmt.Errorf("mlutil: pipeline step %d: %v", i, err)
+		}
+	}
+	return df, nil
+}
+
+// Transform applies every step of the fitted pipeline to df.
+func (p *Pipeline) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	for i, step := range p.Steps {
+		var err error
+		if df, err = step.Transform(df); err != nil {
+			return df, fmt.Errorf("mlutil: pipeline step %d: %v", i, err)
+		}
+	}
+	return df, nil
+}
+
+// InverseTransform undoes the steps of the pipeline in reverse order. It fails if a step cannot be undone.
+func (p *Pipeline) InverseTransform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	for i := len(p.Steps) - 1; i >= 0; i-- {
+		step, ok := p.Steps[i].(InverseTransformer)
+		if !ok {
+			return df, fmt.Errorf("mlutil: pipeline step %d (%T) cannot be inverted", i, p.Steps[i])
+		}
+		var err error
+		if df, err = step.InverseTransform(df); err != nil {
+			return df, fmt.Errorf("mlutil: pipeline step %d: %v", i, err)
+		}
+	}
+	return df, nil
+}
+
+// TransformRow applies the pipeline to a single example whose values are given in the order of cols, and returns
+// the transformed example in the order of the columns of the transformed dataframe.
+func (p *Pipeline) TransformRow(cols []string, row []float64) ([]float64, error) {
+	values := make([]interface{}, len(row), len(row))
+	for i := range row {
+		values[i] = row[i]
+	}
+	return p.TransformValues(cols, values)
+}
+
+// TransformValues is like TransformRow for an example whose values may be categories, as decoded from JSON: a
+// float64 becomes a float column, a string a string column for the encoders, and nil a missing float. Categories
+// are matched by their text, so those of integer columns should be given as strings too, eg. "3".
+func (p *Pipeline) TransformValues(cols []string, row []interface{}) ([]float64, error) {
+	if len(cols) != len(row) {
+		return nil, fmt.Errorf("mlutil: expected %d values, got %d", len(cols), len(row))
+	}
+	s := make([]series.Series, len(cols), len(cols))
+	for i := range cols {
+		switch v := row[i].(type) {
+		case float64:
+			s[i] = series.New([]float64{v}, series.Float, cols[i])
+		case nil:
+			s[i] = series.New([]float64{math.NaN()}, series.Float, cols[i])
+		case string:
+			s[i] = series.New([]string{v}, series.String, cols[i])
+		default:
+			return nil, fmt.Errorf("mlutil: value %v of column %q is neither a number nor a string", v, cols[i])
+		}
+	}
+	df, err := p.Transform(dataframe.New(s...))
+	if err != nil {
+		return nil, err
+	}
+	x, _, err := DataFrameToXYs(df, "")
+	if err != nil {
+		return nil, err
+	}
+	return x[0], nil
+}
+
+func (p *Pipeline) transformerKind() string {
+	return "pipeline"
+}
+
+// transformerJSON is the encoding of a single pipeline step.
+type transformerJSON struct {
+	Kind   string          `json:"kind"`
+	Params json.RawMessage `json:"params"`
+}
+
+// MarshalJSON encodes the pipeline as a list of its steps and their fitted parameters.
+func (p *Pipeline) MarshalJSON() ([]byte, error) {
+	steps := make([]transformerJSON, len(p.Steps), len(p.Steps))
+	for i, step := range p.Steps {
+		pt, ok := step.(persistentTransformer)
+		if !ok {
+			return nil, fmt.Errorf("mlutil: cannot persist pipeline step of type %T", step)
+		}
+		params, err := json.Marshal(pt)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = transformerJSON{Kind: pt.transformerKind(), Params: params}
+	}
+	return json.Marshal(steps)
+}
+
+// UnmarshalJSON decodes a pipeline encoded by MarshalJSON.
+func (p *Pipeline) UnmarshalJSON(data []byte) error {
+	var steps []transformerJSON
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return err
+	}
+	p.Steps = make([]Transformer, len(steps), len(steps))
+	for i, step := range steps {
+		newTransformer, ok := transformerKinds[step.Kind]
+		if !ok {
+			return fmt.Errorf("mlutil: unknown transformer kind %q", step.Kind)
+		}
+		t := newTransformer()
+		if err := json.Unmarshal(step.Params, t); err != nil {
+			return err
+		}
+		p.Steps[i] = t
+	}
+	return nil
+}
+
+// Pipeline decodes the preprocessing pipeline stored in the metadata. It returns nil if the model has none.
+func (meta ModelMetadata) Pipeline() (*Pipeline, error) {
+	if len(meta.Preprocessing) == 0 {
+		return nil, nil
+	}
+	if len(meta.Features) == 0 {
+		return nil, errors.New("mlutil: model has preprocessing but no feature names")
+	}
+	p := new(Pipeline)
+	if err := json.Unmarshal(meta.Preprocessing, p); err != nil {
+		return nil, fmt.Errorf("mlutil: reading preprocessing: %v", err)
+	}
+	return p, nil
+}
+
+// numericColumns returns the names of the float and int columns of df.
+func numericColumns(df dataframe.DataFrame) []string {
+	var ret []string
+	types := df.Types()
+	for i, name := range df.Names() {
+		if types[i] == series.Float || types[i] == series.Int {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys(m map[string]float64) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
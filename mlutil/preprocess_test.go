@@ -0,0 +1,205 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"math"
+	"testing"
+)
+
+// wantColumn is the expected content of a column: its values if it is a float column, and its records otherwise.
+type wantColumn struct {
+	name    string
+	typ     series.Type
+	floats  []float64
+	records []string
+}
+
+// checkColumns reports the columns of df that differ from want.
+func checkColumns(t *testing.T, df dataframe.DataFrame, want ...wantColumn) {
+	t.Helper()
+	for _, w := range want {
+		c := df.Col(w.name)
+		if c.Err != nil {
+			t.Errorf("column %q: %v", w.name, c.Err)
+			continue
+		}
+		if c.Type() != w.typ {
+			t.Errorf("column %q is of type %s, want %s", w.name, c.Type(), w.typ)
+			continue
+		}
+		if w.typ == series.Float {
+			got := c.Float()
+			for i := range w.floats {
+				if math.Abs(got[i]-w.floats[i]) > 1e-12 {
+					t.Errorf("column %q = %v, want %v", w.name, got, w.floats)
+					break
+				}
+			}
+			continue
+		}
+		got := c.Records()
+		for i := range w.records {
+			if got[i] != w.records[i] {
+				t.Errorf("column %q = %q, want %q", w.name, got, w.records)
+				break
+			}
+		}
+	}
+}
+
+// heightFrame returns a dataframe of a float column height, an int column age and a constant float column one.
+func heightFrame() dataframe.DataFrame {
+	return dataframe.New(
+		series.New([]float64{150, 160, 170, 180}, series.Float, "height"),
+		series.New([]int{20, 30, 40, 30}, series.Int, "age"),
+		series.New([]float64{1, 1, 1, 1}, series.Float, "one"),
+	)
+}
+
+func TestScaler(t *testing.T) {
+	// the heights have a mean of 165, a range of 30 and a sample standard deviation of sqrt(500/3)
+	sd := math.Sqrt(500.0 / 3)
+	tests := []struct {
+		name   string
+		scaler *Scaler
+		want   []wantColumn
+	}{
+		{"min-max", NewMinMaxScaler("height"), []wantColumn{
+			{name: "height", typ: series.Float, floats: []float64{0, 1.0 / 3, 2.0 / 3, 1}},
+			{name: "age", typ: series.Int, records: []string{"20", "30", "40", "30"}},
+		}},
+		{"mean", NewMeanNormaliser("height"), []wantColumn{
+			{name: "height", typ: series.Float, floats: []float64{-0.5, -1.0 / 6, 1.0 / 6, 0.5}},
+		}},
+		{"standard", NewStandardScaler("height"), []wantColumn{
+			{name: "height", typ: series.Float, floats: []float64{-15 / sd, -5 / sd, 5 / sd, 15 / sd}},
+		}},
+		{"every numeric column", NewMinMaxScaler(), []wantColumn{
+			{name: "age", typ: series.Float, floats: []float64{0, 0.5, 1, 0.5}},
+			// constant columns are only shifted
+			{name: "one", typ: series.Float, floats: []float64{0, 0, 0, 0}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.scaler.Transform(heightFrame()); err != ErrNotFitted {
+				t.Errorf("Transform() before Fit() returned %v, want ErrNotFitted", err)
+			}
+			if err := tt.scaler.Fit(heightFrame()); err != nil {
+				t.Fatal(err)
+			}
+			out, err := tt.scaler.Transform(heightFrame())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, out, tt.want...)
+			back, err := tt.scaler.InverseTransform(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkColumns(t, back, wantColumn{name: "height", typ: series.Float, floats: []float64{150, 160, 170, 180}})
+		})
+	}
+	if err := (&Scaler{Method: ScaleMethod("log")}).Fit(heightFrame()); err == nil {
+		t.Error("Fit() with an unknown method succeeded, want an error")
+	}
+	if err := NewMinMaxScaler("weight").Fit(heightFrame()); err == nil {
+		t.Error("Fit() of a missing column succeeded, want an error")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(NewMinMaxScaler("height"), NewStandardScaler("height", "age"))
+	fitted, err := p.FitTransform(heightFrame())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a pipeline decoded from JSON transforms like the original
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Pipeline
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	out, err := decoded.Transform(heightFrame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumns(t, out,
+		wantColumn{name: "height", typ: series.Float, floats: fitted.Col("height").Float()},
+		wantColumn{name: "age", typ: series.Float, floats: fitted.Col("age").Float()},
+	)
+	back, err := decoded.InverseTransform(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumns(t, back, wantColumn{name: "height", typ: series.Float, floats: []float64{150, 160, 170, 180}})
+
+	// a single row comes out in the column order of the transformed dataframe
+	row, err := decoded.TransformRow([]string{"height", "age", "one"}, []float64{170, 40, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{out.Col("height").Float()[2], out.Col("age").Float()[2], 1}; !closeTo(row[0], want[0]) || !closeTo(row[1], want[1]) || row[2] != want[2] {
+		t.Errorf("TransformRow() = %v, want %v", row, want)
+	}
+	if _, err := decoded.TransformRow([]string{"height"}, []float64{170, 40}); err == nil {
+		t.Error("TransformRow() with more values than columns succeeded, want an error")
+	}
+}
+
+// squareTransformer squares a column. It has no persisted form.
+type squareTransformer string
+
+func (s squareTransformer) Fit(df dataframe.DataFrame) error { return nil }
+
+func (s squareTransformer) Transform(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	v := df.Col(string(s)).Float()
+	for i := range v {
+		v[i] *= v[i]
+	}
+	df = df.Mutate(series.New(v, series.Float, string(s)))
+	return df, df.Err
+}
+
+func TestPipelineErrors(t *testing.T) {
+	p := NewPipeline(NewMinMaxScaler("height"), squareTransformer("height"))
+	if _, err := p.FitTransform(heightFrame()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(p); err == nil {
+		t.Error("Marshal() of a pipeline with a custom step succeeded, want an error")
+	}
+	if _, err := p.InverseTransform(heightFrame()); err == nil {
+		t.Error("InverseTransform() of a pipeline with a step that cannot be undone succeeded, want an error")
+	}
+	var decoded Pipeline
+	if err := json.Unmarshal([]byte(`[{"kind": "square", "params": {}}]`), &decoded); err == nil {
+		t.Error("Unmarshal() of an unknown kind of step succeeded, want an error")
+	}
+}
+
+func TestPipelineTransformValues(t *testing.T) {
+	p := NewPipeline(NewOneHotEncoder("cat"))
+	if err := p.Fit(dataframe.New(
+		series.New([]string{"a", "b"}, series.String, "cat"),
+		series.New([]float64{1, 2}, series.Float, "x"),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	row, err := p.TransformValues([]string{"cat", "x"}, []interface{}{"b", 5.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{5, 0, 1}; len(row) != 3 || row[0] != want[0] || row[1] != want[1] || row[2] != want[2] {
+		t.Errorf("TransformValues() = %v, want %v", row, want)
+	}
+	if _, err := p.TransformValues([]string{"cat", "x"}, []interface{}{true, 5.0}); err == nil {
+		t.Error("TransformValues() of a boolean succeeded, want an error")
+	}
+}
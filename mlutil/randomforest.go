@@ -0,0 +1,320 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// RandomForestModel is a random forest of CART trees, for regression or classification depending on its Criterion.
+// Every tree is grown on a bootstrap sample of the training examples, considering a random subset of the features
+// at each split, and the forest averages their predictions. The trees are grown concurrently.
+type RandomForestModel struct {
+	Trees     int
+	Criterion Criterion
+	// MaxDepth and MinSamplesLeaf limit the growth of every tree, as for TreeModel.
+	MaxDepth       int
+	MinSamplesLeaf int
+	// MaxFeatures is the number of features considered at each split. 0 considers a third of them for regression
+	// and their square root for classification.
+	MaxFeatures int
+	// Bootstrap is the size of the sample drawn with replacement for each tree, as a fraction of the number of
+	// training examples, 1 if 0.
+	Bootstrap float64
+	// Workers is the number of trees grown at a time, the number of CPUs if 0.
+	Workers int
+	Seed    int64
+
+	trees             []*TreeModel
+	features, classes int
+	oobScore          float64
+	oobExamples       int
+}
+
+// NewRandomForestModel returns an unfitted random forest of the given number of trees, with criterion and
+// maxFeatures features considered at each split.
+func NewRandomForestModel(trees int, criterion Criterion, maxFeatures int) *RandomForestModel {
+	return &RandomForestModel{Trees: trees, Criterion: criterion, MaxFeatures: maxFeatures}
+}
+
+// Fit grows the trees on x and y, and computes the out-of-bag score.
+func (m *RandomForestModel) Fit(x [][]float64, y []float64) error {
+	if len(x) == 0 {
+		return errors.New("mlutil: no training examples")
+	}
+	if len(x) != len(y) {
+		return fmt.Errorf("mlutil: %d examples but %d targets", len(x), len(y))
+	}
+	if m.Trees < 1 {
+		return errors.New("mlutil: random forest has no trees")
+	}
+	features := len(x[0])
+	maxFeatures := m.MaxFeatures
+	if maxFeatures == 0 {
+		if m.Criterion.classification() {
+			maxFeatures = int(math.Sqrt(float64(features)))
+		} else {
+			maxFeatures = features / 3
+		}
+		if maxFeatures < 1 {
+			maxFeatures = 1
+		}
+	}
+	bootstrap := m.Bootstrap
+	if bootstrap == 0 {
+		bootstrap = 1
+	}
+	samples := int(math.Round(bootstrap * float64(len(x))))
+	if samples < 1 {
+		return fmt.Errorf("mlutil: bootstrap fraction %v draws no examples", bootstrap)
+	}
+	workers := m.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	// every tree draws its sample and features from its own seed, so that the forest does not depend on the order
+	// in which the workers grow the trees
+	trees := make([]*TreeModel, m.Trees, m.Trees)
+	inBag := make([][]bool, m.Trees, m.Trees)
+	errs := make([]error, m.Trees, m.Trees)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				rnd := rand.New(rand.NewSource(m.Seed + int64(t)))
+				bx := make([][]float64, samples, samples)
+				by := make([]float64, samples, samples)
+				inBag[t] = make([]bool, len(x), len(x))
+				for i := range bx {
+					j := rnd.Intn(len(x))
+					bx[i], by[i] = x[j], y[j]
+					inBag[t][j] = true
+				}
+				trees[t] = &TreeModel{
+					Criterion:      m.Criterion,
+					MaxDepth:       m.MaxDepth,
+					MinSamplesLeaf: m.MinSamplesLeaf,
+					MaxFeatures:    maxFeatures,
+					Seed:           rnd.Int63(),
+				}
+				errs[t] = trees[t].Fit(bx, by)
+			}
+		}()
+	}
+	for t := range trees {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	m.trees, m.features, m.classes = trees, features, 0
+	for _, t := range trees {
+		if t.classes > m.classes {
+			m.classes = t.classes
+		}
+	}
+	m.oob(x, y, inBag)
+	return nil
+}
+
+// oob computes the out-of-bag score: the score of the predictions for each training example of the trees that were
+// not grown on it.
+func (m *RandomForestModel) oob(x [][]float64, y []float64, inBag [][]bool) {
+	m.oobScore, m.oobExamples = 0, 0
+	var yTrue, yPred []float64
+	for i := range x {
+		var sum float64
+		var proba []float64
+		n := 0
+		for t, tree := range m.trees {
+			if inBag[t][i] {
+				continue
+			}
+			leaf, _ := tree.leaf(x[i])
+			if proba == nil && m.Criterion.classification() {
+				proba = make([]float64, m.classes, m.classes)
+			}
+			for c, p := range leaf.Proba {
+				proba[c] += p
+			}
+			sum += leaf.Value
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		yTrue = append(yTrue, y[i])
+		if proba != nil {
+			yPred = append(yPred, float64(MaxIndex(proba)))
+		} else {
+			yPred = append(yPred, sum/float64(n))
+		}
+	}
+	if len(yTrue) == 0 {
+		return
+	}
+	m.oobExamples = len(yTrue)
+	if m.Criterion.classification() {
+		m.oobScore = Accuracy(yTrue, yPred)
+	} else {
+		m.oobScore = MeanSquaredError(yTrue, yPred)
+	}
+}
+
+// OOBScore returns the out-of-bag mean squared error of a regression forest, or the out-of-bag accuracy of a
+// classification forest, and the number of training examples it was computed on: those left out of the bootstrap
+// sample of at least one tree. It estimates the error on unseen examples without holding any out.
+func (m *RandomForestModel) OOBScore() (score float64, examples int) {
+	return m.oobScore, m.oobExamples
+}
+
+// Predict returns the mean prediction of the trees for regression forests, and the class with the largest mean
+// probability for classification forests.
+func (m *RandomForestModel) Predict(x []float64) (float64, error) {
+	if m.trees == nil {
+		return 0, ErrNotFitted
+	}
+	if m.Criterion.classification() {
+		p, err := m.PredictProba(x)
+		if err != nil {
+			return 0, err
+		}
+		return float64(MaxIndex(p)), nil
+	}
+	var sum float64
+	for _, t := range m.trees {
+		v, err := t.Predict(x)
+		if err != nil {
+			return 0, err
+		}
+		sum += v
+	}
+	return sum / float64(len(m.trees)), nil
+}
+
+// PredictProba returns the mean probability of each class over the trees. It returns ErrNotClassifier for
+// regression forests.
+func (m *RandomForestModel) PredictProba(x []float64) ([]float64, error) {
+	if m.trees == nil {
+		return nil, ErrNotFitted
+	}
+	if !m.Criterion.classification() {
+		return nil, ErrNotClassifier
+	}
+	ret := make([]float64, m.classes, m.classes)
+	for _, t := range m.trees {
+		leaf, err := t.leaf(x)
+		if err != nil {
+			return nil, err
+		}
+		for c, p := range leaf.Proba {
+			ret[c] += p / float64(len(m.trees))
+		}
+	}
+	return ret, nil
+}
+
+// Estimators returns the fitted trees of the forest.
+func (m *RandomForestModel) Estimators() []*TreeModel {
+	return m.trees
+}
+
+// FeatureImportances returns the impurity-based importance of each feature: the decrease in impurity brought by
+// the splits on it, averaged over the trees and normalised to sum to 1. It favours features with many distinct
+// values; PermutationImportance does not.
+func (m *RandomForestModel) FeatureImportances() []float64 {
+	ret := make([]float64, m.features, m.features)
+	var total float64
+	for _, t := range m.trees {
+		for j, v := range t.FeatureImportances() {
+			ret[j] += v
+			total += v
+		}
+	}
+	for j := range ret {
+		ret[j] = safeDivide(ret[j], total)
+	}
+	return ret
+}
+
+// randomForestState is the persisted form of RandomForestModel. The trees share the parameters of the forest, so
+// only their nodes are stored.
+type randomForestState struct {
+	Trees          int         `json:"trees"`
+	Criterion      string      `json:"criterion"`
+	MaxDepth       int         `json:"max_depth"`
+	MinSamplesLeaf int         `json:"min_samples_leaf"`
+	MaxFeatures    int         `json:"max_features"`
+	Bootstrap      float64     `json:"bootstrap"`
+	Seed           int64       `json:"seed"`
+	Features       int         `json:"features"`
+	Classes        int         `json:"classes"`
+	OOBScore       float64     `json:"oob_score"`
+	OOBExamples    int         `json:"oob_examples"`
+	Roots          []*TreeNode `json:"roots"`
+}
+
+func (m *RandomForestModel) modelKind() (string, string) {
+	return "random_forest", "mlutil"
+}
+
+func (m *RandomForestModel) marshalModel() ([]byte, error) {
+	if m.trees == nil {
+		return nil, ErrNotFitted
+	}
+	roots := make([]*TreeNode, len(m.trees), len(m.trees))
+	for i, t := range m.trees {
+		roots[i] = t.root
+	}
+	return json.Marshal(randomForestState{
+		m.Trees, m.Criterion.String(), m.MaxDepth, m.MinSamplesLeaf, m.MaxFeatures, m.Bootstrap, m.Seed,
+		m.features, m.classes, m.oobScore, m.oobExamples, roots,
+	})
+}
+
+func (m *RandomForestModel) unmarshalModel(data []byte) error {
+	var s randomForestState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	criterion, err := ParseCriterion(s.Criterion)
+	if err != nil {
+		return err
+	}
+	if len(s.Roots) == 0 {
+		return errors.New("forest has no trees")
+	}
+	*m = RandomForestModel{
+		Trees:          s.Trees,
+		Criterion:      criterion,
+		MaxDepth:       s.MaxDepth,
+		MinSamplesLeaf: s.MinSamplesLeaf,
+		MaxFeatures:    s.MaxFeatures,
+		Bootstrap:      s.Bootstrap,
+		Seed:           s.Seed,
+		features:       s.Features,
+		classes:        s.Classes,
+		oobScore:       s.OOBScore,
+		oobExamples:    s.OOBExamples,
+	}
+	for _, root := range s.Roots {
+		if root == nil {
+			return errors.New("forest has an empty tree")
+		}
+		m.trees = append(m.trees, &TreeModel{Criterion: criterion, root: root, features: s.Features, classes: s.Classes})
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package mlutil
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// constantTree returns a fitted tree that is a single leaf.
+func constantTree(criterion Criterion, value float64, proba ...float64) *TreeModel {
+	return &TreeModel{Criterion: criterion, root: &TreeNode{Value: value, Proba: proba}, features: 1, classes: len(proba)}
+}
+
+func TestRandomForestModelOOB(t *testing.T) {
+	x := column(0, 1, 2)
+	tests := []struct {
+		name     string
+		forest   *RandomForestModel
+		y        []float64
+		inBag    [][]bool
+		score    float64
+		examples int
+	}{
+		{
+			name:     "regression one tree out of bag",
+			forest:   &RandomForestModel{Criterion: SquaredError, trees: []*TreeModel{constantTree(SquaredError, 1), constantTree(SquaredError, 3)}},
+			y:        []float64{1, 3, 2},
+			inBag:    [][]bool{{true, true, true}, {false, false, false}},
+			score:    5.0 / 3, // predictions 3, 3, 3
+			examples: 3,
+		},
+		{
+			name:     "regression mean of out of bag trees",
+			forest:   &RandomForestModel{Criterion: SquaredError, trees: []*TreeModel{constantTree(SquaredError, 1), constantTree(SquaredError, 3)}},
+			y:        []float64{1, 3, 2},
+			inBag:    [][]bool{{true, false, false}, {false, true, false}},
+			score:    8.0 / 3, // predictions 3, 1, 2
+			examples: 3,
+		},
+		{
+			name:     "regression skips examples in every bag",
+			forest:   &RandomForestModel{Criterion: SquaredError, trees: []*TreeModel{constantTree(SquaredError, 1), constantTree(SquaredError, 3)}},
+			y:        []float64{1, 3, 2},
+			inBag:    [][]bool{{true, false, true}, {true, true, false}},
+			score:    2.5, // predictions -, 1, 3
+			examples: 2,
+		},
+		{
+			name:     "regression all in bag",
+			forest:   &RandomForestModel{Criterion: SquaredError, trees: []*TreeModel{constantTree(SquaredError, 1)}},
+			y:        []float64{1, 3, 2},
+			inBag:    [][]bool{{true, true, true}},
+			score:    0,
+			examples: 0,
+		},
+		{
+			name: "classification",
+			forest: &RandomForestModel{Criterion: Gini, classes: 2, trees: []*TreeModel{
+				constantTree(Gini, 0, 1, 0), constantTree(Gini, 1, 0.25, 0.75),
+			}},
+			y:        []float64{0, 1, 1},
+			inBag:    [][]bool{{false, true, false}, {true, false, false}},
+			score:    2.0 / 3, // mean probabilities of class 1: 0, 0.75, 0.375
+			examples: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.forest.oob(x, tt.y, tt.inBag)
+			score, examples := tt.forest.OOBScore()
+			if math.Abs(score-tt.score) > 1e-12 || examples != tt.examples {
+				t.Errorf("OOBScore() = %v, %d, want %v, %d", score, examples, tt.score, tt.examples)
+			}
+		})
+	}
+}
+
+
+// stepData returns n examples of a single feature 0, 1, ..., n-1 whose label is the index of the equal part of the
+// range they fall in, times scale.
+func stepData(n, parts int, scale float64) ([][]float64, []float64) {
+	x := make([][]float64, n, n)
+	y := make([]float64, n, n)
+	for i := range x {
+		x[i] = []float64{float64(i)}
+		y[i] = float64(i*parts/n) * scale
+	}
+	return x, y
+}
+
+func TestRandomForestModelFitOOB(t *testing.T) {
+	x, y := stepData(120, 4, 1)
+	var scores []float64
+	for _, workers := range []int{1, 4} {
+		m := &RandomForestModel{Trees: 30, Criterion: SquaredError, MaxFeatures: 1, Workers: workers, Seed: 7}
+		if err := m.Fit(x, y); err != nil {
+			t.Fatal(err)
+		}
+		score, examples := m.OOBScore()
+		// with 30 bootstrap samples every example is left out of some tree
+		if examples != len(x) || score > 0.05 {
+			t.Errorf("out-of-bag MSE %v on %d examples, want below 0.05 on %d", score, examples, len(x))
+		}
+		scores = append(scores, score)
+	}
+	if scores[0] != scores[1] {
+		t.Errorf("out-of-bag MSE %v with 1 worker and %v with 4, want the same forest", scores[0], scores[1])
+	}
+
+	// a classification forest scores the accuracy of its majority vote
+	_, y = stepData(120, 3, 1)
+	m := &RandomForestModel{Trees: 30, Criterion: Gini, MaxFeatures: 1, Seed: 7}
+	if err := m.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	if score, _ := m.OOBScore(); score < 0.95 {
+		t.Errorf("out-of-bag accuracy %v, want above 0.95", score)
+	}
+}
+
+func TestFeatureImportances(t *testing.T) {
+	// the target is a step of the first feature, and the second is noise
+	x, y := stepData(100, 2, 10)
+	rnd := rand.New(rand.NewSource(1))
+	for i := range x {
+		x[i] = append(x[i], rnd.Float64())
+	}
+	m := &RandomForestModel{Trees: 10, Criterion: SquaredError, MaxFeatures: 2, Seed: 3}
+	if err := m.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	if imp := m.FeatureImportances(); !closeTo(imp[0]+imp[1], 1) || imp[0] < 0.9 {
+		t.Errorf("impurity importances %v, want nearly all of it on the first feature", imp)
+	}
+
+	r, err := PermutationImportance(m, x, y, []string{"step", "noise"}, MeanSquaredError, true, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r[0].Feature != "step" || r[0].Importance <= 0 || math.Abs(r[1].Importance) > r[0].Importance/10 {
+		t.Errorf("permutation importances %v, want step far ahead of noise", r)
+	}
+	if _, err := NewImportanceReport([]string{"step"}, []float64{1, 2}, nil); err == nil {
+		t.Error("NewImportanceReport() with fewer names than importances succeeded, want an error")
+	}
+}
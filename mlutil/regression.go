@@ -0,0 +1,85 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sajari/regression"
+)
+
+// OLSModel adapts sajari/regression's ordinary least squares regression to the Model interface. Only the fitted
+// coefficients are kept, so that the model can be persisted without the training data sajari/regression holds on to.
+type OLSModel struct {
+	// coeff holds the intercept followed by the coefficient of each feature.
+	coeff []float64
+}
+
+// NewOLSModel returns an unfitted ordinary least squares regression.
+func NewOLSModel() *OLSModel {
+	return &OLSModel{}
+}
+
+// WrapOLS returns an adapter for a regression that has already been run.
+func WrapOLS(model *regression.Regression) *OLSModel {
+	return &OLSModel{coeff: model.GetCoeffs()}
+}
+
+// Fit trains the model on x and y.
+func (m *OLSModel) Fit(x [][]float64, y []float64) error {
+	model := new(regression.Regression)
+	for i := range x {
+		model.Train(regression.DataPoint(y[i], x[i]))
+	}
+	if err := model.Run(); err != nil {
+		return err
+	}
+	m.coeff = model.GetCoeffs()
+	return nil
+}
+
+// Predict returns the predicted value for x.
+func (m *OLSModel) Predict(x []float64) (float64, error) {
+	if m.coeff == nil {
+		return 0, ErrNotFitted
+	}
+	if len(x)+1 != len(m.coeff) {
+		return 0, fmt.Errorf("mlutil: expected %d features, got %d", len(m.coeff)-1, len(x))
+	}
+	p := m.coeff[0]
+	for i := range x {
+		p += m.coeff[i+1] * x[i]
+	}
+	return p, nil
+}
+
+// PredictProba always returns ErrNotClassifier.
+func (m *OLSModel) PredictProba(x []float64) ([]float64, error) {
+	return nil, ErrNotClassifier
+}
+
+func (m *OLSModel) modelKind() (string, string) {
+	return "ols", "sajari/regression"
+}
+
+func (m *OLSModel) marshalModel() ([]byte, error) {
+	if m.coeff == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(struct {
+		Coefficients []float64 `json:"coefficients"`
+	}{m.coeff})
+}
+
+func (m *OLSModel) unmarshalModel(data []byte) error {
+	var s struct {
+		Coefficients []float64 `json:"coefficients"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s.Coefficients) == 0 {
+		return errors.New("no coefficients")
+	}
+	m.coeff = s.Coefficients
+	return nil
+}
@@ -0,0 +1,204 @@
+package mlutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// HeaderMode says what the first line of a CSV file read with a Schema holds.
+type HeaderMode int
+
+const (
+	// NoHeader files start with data, such as cal_housing.data.
+	NoHeader HeaderMode = iota
+	// MatchHeader files start with the column names, which must be those of the schema in the same order.
+	MatchHeader
+	// SkipHeader files start with a line that is not data and is ignored, such as the "150,4,setosa,..." line of
+	// scikit-learn's iris.csv.
+	SkipHeader
+)
+
+// ColumnSpec declares a column of a CSV file.
+type ColumnSpec struct {
+	Name string
+	// Type is series.Float, series.Int, series.String or series.Bool.
+	Type series.Type
+	// Unit documents the unit of the values, eg. "inches".
+	Unit string
+	// Nullable allows missing values, which are read as NaN in float columns and NA in the others.
+	Nullable bool
+	// Range bounds the values of numeric columns, if not nil. Use math.Inf for a one-sided bound.
+	Range *Fences
+	// Categories are the allowed values of a string column. If empty, any value is allowed.
+	Categories []string
+}
+
+// Schema declares the columns of a CSV file, so that it can be read with the right types and validated while
+// reading.
+type Schema struct {
+	Columns []ColumnSpec
+	Header  HeaderMode
+	// Comma is the field delimiter. If zero, ',' is used.
+	Comma rune
+	// NullValues are the fields read as missing values. If nil, empty fields, "NA" and "NaN" are.
+	NullValues []string
+}
+
+// Names returns the names of the columns of the schema.
+func (s Schema) Names() []string {
+	ret := make([]string, len(s.Columns), len(s.Columns))
+	for i, c := range s.Columns {
+		ret[i] = c.Name
+	}
+	return ret
+}
+
+// SchemaError is returned for a field of a CSV file that does not match its Schema.
+type SchemaError struct {
+	// Line is the 1-based line of the file.
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *SchemaError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("mlutil: line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("mlutil: line %d, column %q: %v", e.Line, e.Column, e.Err)
+}
+
+// LoadCSV reads the CSV file at path according to schema.
+func LoadCSV(path string, schema Schema) (dataframe.DataFrame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return dataframe.DataFrame{}, err
+	}
+	defer f.Close()
+	return schema.ReadCSV(f)
+}
+
+// ReadCSV reads CSV data from r into a dataframe with the columns of the schema. It fails on the first field that
+// does not parse as the type of its column, is missing in a column that is not nullable, or is out of the range or
+// categories of its column.
+func (s Schema) ReadCSV(r io.Reader) (dataframe.DataFrame, error) {
+	chunks, err := NewCSVChunkReader(r, s, 0)
+	if err != nil {
+		return dataframe.DataFrame{}, err
+	}
+	df, _, err := chunks.read()
+	return df, err
+}
+
+// matchHeader checks that the header record holds the names of the columns of the schema.
+func (s Schema) matchHeader(record []string, line int) error {
+	if len(record) != len(s.Columns) {
+		return &SchemaError{Line: line, Err: fmt.Errorf("expected %d columns, got %d", len(s.Columns), len(record))}
+	}
+	for j, c := range s.Columns {
+		if name := strings.TrimSpace(record[j]); name != c.Name {
+			return &SchemaError{Line: line, Err: fmt.Errorf("expected column %q, found %q", c.Name, name)}
+		}
+	}
+	return nil
+}
+
+// parse validates field against the spec of its column and returns it as a record for series.New, which is "NaN"
+// for missing values.
+func (s Schema) parse(c ColumnSpec, field string) (string, error) {
+	field = strings.TrimSpace(field)
+	if s.isNull(field) {
+		if !c.Nullable {
+			return "", errors.New("missing value")
+		}
+		return "NaN", nil
+	}
+	var f float64
+	switch c.Type {
+	case series.Float:
+		var err error
+		if f, err = strconv.ParseFloat(field, 64); err != nil || math.IsNaN(f) {
+			return "", fmt.Errorf("%q is not a number", field)
+		}
+	case series.Int:
+		i, err := strconv.Atoi(field)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", field)
+		}
+		f = float64(i)
+	case series.Bool:
+		if _, err := strconv.ParseBool(field); err != nil {
+			return "", fmt.Errorf("%q is not a boolean", field)
+		}
+		return field, nil
+	case series.String:
+		if len(c.Categories) > 0 && !contains(c.Categories, field) {
+			return "", fmt.Errorf("%q is not one of the categories %v", field, c.Categories)
+		}
+		return field, nil
+	default:
+		return "", fmt.Errorf("unsupported column type %q", c.Type)
+	}
+	if c.Range != nil && (f < c.Range.Lower || f > c.Range.Upper) {
+		return "", fmt.Errorf("%v is outside of the range [%v, %v]", f, c.Range.Lower, c.Range.Upper)
+	}
+	return field, nil
+}
+
+// isNull reports whether field is one of the null values of the schema.
+func (s Schema) isNull(field string) bool {
+	if s.NullValues == nil {
+		return field == "" || field == "NA" || field == "NaN"
+	}
+	return contains(s.NullValues, field)
+}
+
+// contains reports whether s is one of values.
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nonNegative is the range of counts.
+var nonNegative = &Fences{Lower: 0, Upper: math.Inf(1)}
+
+// CaliforniaHousingSchema is the schema of the headerless cal_housing.data file of the California housing dataset.
+// totalBedrooms is nullable since some copies of the dataset leave it blank for a few districts.
+var CaliforniaHousingSchema = Schema{
+	Header: NoHeader,
+	Columns: []ColumnSpec{
+		{Name: "longitude", Type: series.Float, Unit: "degrees", Range: &Fences{Lower: -180, Upper: 180}},
+		{Name: "latitude", Type: series.Float, Unit: "degrees", Range: &Fences{Lower: -90, Upper: 90}},
+		{Name: "housingMedianAge", Type: series.Float, Unit: "years", Range: nonNegative},
+		{Name: "totalRooms", Type: series.Float, Range: nonNegative},
+		{Name: "totalBedrooms", Type: series.Float, Nullable: true, Range: nonNegative},
+		{Name: "population", Type: series.Float, Unit: "people", Range: nonNegative},
+		{Name: "households", Type: series.Float, Range: nonNegative},
+		{Name: "medianIncome", Type: series.Float, Unit: "10,000 USD", Range: nonNegative},
+		{Name: "medianHouseValue", Type: series.Float, Unit: "USD", Range: nonNegative},
+	},
+}
+
+// IrisSchema is the schema of scikit-learn's iris.csv, whose first line holds the numbers of samples and features
+// and the class names rather than the column names. species is the class label, 0 to 2.
+var IrisSchema = Schema{
+	Header: SkipHeader,
+	Columns: []ColumnSpec{
+		{Name: "petal length", Type: series.Float, Unit: "cm", Range: nonNegative},
+		{Name: "petal width", Type: series.Float, Unit: "cm", Range: nonNegative},
+		{Name: "sepal length", Type: series.Float, Unit: "cm", Range: nonNegative},
+		{Name: "sepal width", Type: series.Float, Unit: "cm", Range: nonNegative},
+		{Name: "species", Type: series.Int, Range: &Fences{Lower: 0, Upper: 2}},
+	},
+}
@@ -0,0 +1,104 @@
+package mlutil
+
+import (
+	"errors"
+	"github.com/go-gota/gota/series"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bmiSchema is the schema of a small file of people with a header line.
+var bmiSchema = Schema{
+	Header: MatchHeader,
+	Columns: []ColumnSpec{
+		{Name: "name", Type: series.String},
+		{Name: "height", Type: series.Float, Unit: "cm", Nullable: true, Range: &Fences{Lower: 50, Upper: 250}},
+		{Name: "sex", Type: series.String, Categories: []string{"f", "m"}},
+		{Name: "age", Type: series.Int, Range: nonNegative},
+	},
+}
+
+func TestSchemaReadCSV(t *testing.T) {
+	df, err := bmiSchema.ReadCSV(strings.NewReader("name,height,sex,age\nann, 170.5,f,31\nbob,NA,m,40\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumns(t, df,
+		wantColumn{name: "name", typ: series.String, records: []string{"ann", "bob"}},
+		wantColumn{name: "height", typ: series.Float, floats: []float64{170.5, math.NaN()}},
+		wantColumn{name: "age", typ: series.Int, records: []string{"31", "40"}},
+	)
+	if h := df.Col("height").Float()[1]; !math.IsNaN(h) {
+		t.Errorf("missing height read as %v, want NaN", h)
+	}
+
+	// iris.csv starts with a line of counts and class names, of another number of fields
+	df, err = IrisSchema.ReadCSV(strings.NewReader("150,4,setosa,versicolor,virginica\n1.4,0.2,5.1,3.5,0\n4.7,1.4,7.0,3.2,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.Nrow() != 2 || df.Ncol() != 5 || df.Col("species").Type() != series.Int {
+		t.Errorf("iris dataframe of %dx%d with species of type %s, want 2x5 with integer species", df.Nrow(), df.Ncol(), df.Col("species").Type())
+	}
+
+	s := Schema{Comma: ';', NullValues: []string{"?"}, Columns: []ColumnSpec{
+		{Name: "a", Type: series.Float, Nullable: true},
+		{Name: "b", Type: series.Bool},
+	}}
+	df, err = s.ReadCSV(strings.NewReader("?;true\nNA;false\n"))
+	if err == nil {
+		t.Errorf("ReadCSV() with NA outside of the null values succeeded with %v, want an error", df)
+	}
+}
+
+func TestSchemaReadCSVErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		line   int
+		column string
+	}{
+		{"wrong header", "name,weight,sex,age\n", 1, ""},
+		{"unparsable float", "name,height,sex,age\nann,tall,f,31\n", 2, "height"},
+		{"fractional integer", "name,height,sex,age\nann,170,f,31.5\n", 2, "age"},
+		{"out of range", "name,height,sex,age\nann,170,f,31\nbob,12,m,40\n", 3, "height"},
+		{"unknown category", "name,height,sex,age\nann,170,x,31\n", 2, "sex"},
+		{"missing value", "name,height,sex,age\n,170,f,31\n", 2, "name"},
+		{"too few fields", "name,height,sex,age\nann,170,f\n", 2, ""},
+	}
+	for _, tt := range tests {
+		_, err := bmiSchema.ReadCSV(strings.NewReader(tt.data))
+		var serr *SchemaError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: ReadCSV() returned %v, want a SchemaError", tt.name, err)
+			continue
+		}
+		if serr.Line != tt.line || serr.Column != tt.column {
+			t.Errorf("%s: error at line %d, column %q, want line %d, column %q", tt.name, serr.Line, serr.Column, tt.line, tt.column)
+		}
+	}
+	if _, err := (Schema{}).ReadCSV(strings.NewReader("a\n")); err == nil {
+		t.Error("ReadCSV() with an empty schema succeeded, want an error")
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cal_housing.data")
+	data := "-122.23,37.88,41,880,,322,126,8.3252,452600\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	df, err := LoadCSV(path, CaliforniaHousingSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.Nrow() != 1 || !math.IsNaN(df.Col("totalBedrooms").Float()[0]) || df.Col("medianHouseValue").Float()[0] != 452600 {
+		t.Errorf("dataframe %v, want one district with missing bedrooms", df)
+	}
+	if _, err := LoadCSV(filepath.Join(t.TempDir(), "missing.csv"), CaliforniaHousingSchema); err == nil {
+		t.Error("LoadCSV() of a missing file succeeded, want an error")
+	}
+}
@@ -0,0 +1,234 @@
+package mlutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// maxRequestBytes bounds the size of a prediction request body.
+const maxRequestBytes = 32 << 20
+
+// errEmptyBatch is returned for batch requests without instances.
+var errEmptyBatch = errors.New("batch has no instances")
+
+// PredictionServer serves the predictions of a model loaded with LoadModel over HTTP:
+//
+//	POST /predict        a JSON array of features, or {"features": [...]}, returns one prediction
+//	POST /predict/batch  a JSON array of feature arrays, or {"instances": [[...], ...]}, returns an array of predictions
+//	GET  /healthz        returns {"status": "ok"}
+//	GET  /metadata       returns the ModelMetadata of the model
+//
+// POST / is an alias of /predict, so clients of the Python model_http.py server work unchanged.
+//
+// A prediction is an object holding the "prediction" itself, and for classifiers the class "label" (if the metadata
+// has labels) and the class "probabilities" (if the model provides them).
+type PredictionServer struct {
+	Model    Model
+	Metadata ModelMetadata
+	// Preprocessing is applied to the features, named by Metadata.Features, before they reach the model. With
+	// preprocessing the features may also be strings, the raw categories of the columns its encoders turn into
+	// numbers, or null for missing values; without it they must be numbers.
+	Preprocessing *Pipeline
+	// Scale multiplies every input before prediction, for example 1/255 to serve raw pixel values to a model trained
+	// on the output of ImageSet.Floats. 0 leaves the inputs unchanged.
+	Scale float64
+	// FlagField names a boolean added to the predictions of classifiers which is true if FlagLabel is the predicted
+	// label, as in the {"is_trousers": true} replies of model_http.py. The field is left out if FlagField is empty or
+	// FlagLabel is not one of the labels of the model.
+	FlagField string
+	FlagLabel string
+
+	// mu serialises predictions, since not every model can predict concurrently (go-deep keeps its activations in
+	// the network).
+	mu sync.Mutex
+}
+
+// NewPredictionServer returns a server for m that applies the preprocessing pipeline stored in meta, and flags
+// predictions of the "trouser" class as "is_trousers".
+func NewPredictionServer(m Model, meta ModelMetadata) (*PredictionServer, error) {
+	pipeline, err := meta.Pipeline()
+	if err != nil {
+		return nil, err
+	}
+	return &PredictionServer{Model: m, Metadata: meta, Preprocessing: pipeline, FlagField: "is_trousers", FlagLabel: "trouser"}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *PredictionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/", "/predict":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var features []interface{}
+		if err := decodeInstances(w, r, "features", &features); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		p, err := s.predict(features)
+		if err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	case "/predict/batch":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var instances [][]interface{}
+		if err := decodeInstances(w, r, "instances", &instances); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(instances) == 0 {
+			writeJSONError(w, http.StatusBadRequest, errEmptyBatch)
+			return
+		}
+		ret := make([]map[string]interface{}, len(instances), len(instances))
+		for i := range instances {
+			p, err := s.predict(instances[i])
+			if err != nil {
+				writeJSONError(w, http.StatusUnprocessableEntity, fmt.Errorf("instance %d: %v", i, err))
+				return
+			}
+			ret[i] = p
+		}
+		writeJSON(w, http.StatusOK, ret)
+	case "/healthz":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "/metadata":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Metadata)
+	default:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	}
+}
+
+// predict returns the prediction for a single instance.
+func (s *PredictionServer) predict(values []interface{}) (p map[string]interface{}, err error) {
+	if n := len(s.Metadata.Features); n > 0 && len(values) != n {
+		return nil, fmt.Errorf("expected %d features, got %d", n, len(values))
+	}
+	if s.Scale != 0 {
+		scaled := make([]interface{}, len(values), len(values))
+		for i := range values {
+			if v, ok := values[i].(float64); ok {
+				scaled[i] = v * s.Scale
+			} else {
+				scaled[i] = values[i]
+			}
+		}
+		values = scaled
+	}
+	var features []float64
+	if s.Preprocessing != nil {
+		if features, err = s.Preprocessing.TransformValues(s.Metadata.Features, values); err != nil {
+			return nil, err
+		}
+	} else {
+		features = make([]float64, len(values), len(values))
+		for i := range values {
+			v, ok := values[i].(float64)
+			if !ok {
+				return nil, fmt.Errorf("feature %d is %v, not a number", i, values[i])
+			}
+			features[i] = v
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Some of the wrapped libraries index the input without checking its length
+	defer func() {
+		if r := recover(); r != nil {
+			p, err = nil, fmt.Errorf("prediction failed: %v", r)
+		}
+	}()
+
+	prediction, err := s.Model.Predict(features)
+	if err != nil {
+		return nil, err
+	}
+	p = map[string]interface{}{"prediction": prediction}
+	proba, err := s.Model.PredictProba(features)
+	switch err {
+	case nil:
+		p["probabilities"] = proba
+	case ErrNotClassifier:
+		return p, nil
+	}
+	// Classifiers that were not set up to estimate probabilities still have labels
+	if k := int(prediction); k >= 0 && k < len(s.Metadata.Labels) {
+		p["label"] = s.Metadata.Labels[k]
+		if s.FlagField != "" && s.hasLabel(s.FlagLabel) {
+			p[s.FlagField] = s.Metadata.Labels[k] == s.FlagLabel
+		}
+	}
+	return p, nil
+}
+
+// hasLabel reports whether label is one of the labels of the model.
+func (s *PredictionServer) hasLabel(label string) bool {
+	for _, l := range s.Metadata.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// allowMethod writes a 405 response and returns false unless r uses method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s requires %s", r.URL.Path, method))
+	return false
+}
+
+// decodeInstances decodes the body of r into v, which is either the whole body or the field key of a JSON object.
+func decodeInstances(w http.ResponseWriter, r *http.Request, key string, v interface{}) error {
+	body := http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return err
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return err
+		}
+		field, ok := obj[key]
+		if !ok {
+			return fmt.Errorf("request has no %q field", key)
+		}
+		raw = field
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes err as {"error": "..."}.
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
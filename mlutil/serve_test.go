@@ -0,0 +1,155 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// thresholdClassifier predicts class 1, with certainty, for examples whose first feature is above 0.5.
+type thresholdClassifier struct{}
+
+func (thresholdClassifier) Fit(x [][]float64, y []float64) error { return nil }
+
+func (thresholdClassifier) Predict(x []float64) (float64, error) {
+	if x[0] > 0.5 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (c thresholdClassifier) PredictProba(x []float64) ([]float64, error) {
+	p, _ := c.Predict(x)
+	return []float64{1 - p, p}, nil
+}
+
+// newServer returns a prediction server for m, failing the test if meta has invalid preprocessing.
+func newServer(t *testing.T, m Model, meta ModelMetadata) *PredictionServer {
+	s, err := NewPredictionServer(m, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newCategoryServer returns a server for a model fitted after one-hot encoding the category column cat, so that it
+// is served raw categories. The model predicts x plus 10 for category b and 20 for category c.
+func newCategoryServer(t *testing.T) *PredictionServer {
+	p := NewPipeline(NewOneHotEncoder("cat"))
+	if err := p.Fit(dataframe.New(
+		series.New([]string{"a", "b", "a", "c"}, series.String, "cat"),
+		series.New([]float64{1, 2, 3, 4}, series.Float, "x"),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	preprocessing, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the encoded columns are x, cat_a, cat_b and cat_c
+	m := modelFunc(func(x []float64) float64 { return x[0] + 10*x[2] + 20*x[3] })
+	return newServer(t, m, ModelMetadata{Features: []string{"cat", "x"}, Preprocessing: preprocessing})
+}
+
+func TestPredictionServer(t *testing.T) {
+	classifier := newServer(t, thresholdClassifier{}, ModelMetadata{Features: []string{"x"}, Labels: []string{"shirt", "trouser"}})
+	scaled := newServer(t, thresholdClassifier{}, ModelMetadata{Features: []string{"x"}, Labels: []string{"shirt", "trouser"}})
+	scaled.Scale = 1.0 / 255
+	regression := newServer(t, modelFunc(func(x []float64) float64 { return x[0] + x[1] }), ModelMetadata{})
+	// the pipeline maps a onto [0,1] from its training range [2,4], then the model adds b
+	scaler := NewMinMaxScaler("a")
+	if err := scaler.Fit(dataframe.New(series.New([]float64{2, 4}, series.Float, "a"))); err != nil {
+		t.Fatal(err)
+	}
+	preprocessing, err := json.Marshal(NewPipeline(scaler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := newServer(t, modelFunc(func(x []float64) float64 { return x[0] + x[1] }),
+		ModelMetadata{Features: []string{"a", "b"}, Preprocessing: preprocessing})
+	categories := newCategoryServer(t)
+	tests := []struct {
+		name   string
+		server *PredictionServer
+		method string
+		path   string
+		body   string
+		status int
+		// want is the expected response, or a part of the error message for failed requests
+		want string
+	}{
+		{"predict array", classifier, "POST", "/predict", `[0]`, http.StatusOK,
+			`{"is_trousers":false,"label":"shirt","prediction":0,"probabilities":[1,0]}`},
+		{"predict object", classifier, "POST", "/predict", `{"features": [1]}`, http.StatusOK,
+			`{"is_trousers":true,"label":"trouser","prediction":1,"probabilities":[0,1]}`},
+		{"root alias", classifier, "POST", "/", `[1]`, http.StatusOK,
+			`{"is_trousers":true,"label":"trouser","prediction":1,"probabilities":[0,1]}`},
+		{"scaled pixels", scaled, "POST", "/predict", `[200]`, http.StatusOK,
+			`{"is_trousers":true,"label":"trouser","prediction":1,"probabilities":[0,1]}`},
+		{"batch array", classifier, "POST", "/predict/batch", `[[0], [1]]`, http.StatusOK,
+			`[{"is_trousers":false,"label":"shirt","prediction":0,"probabilities":[1,0]},` +
+				`{"is_trousers":true,"label":"trouser","prediction":1,"probabilities":[0,1]}]`},
+		{"batch object", classifier, "POST", "/predict/batch", `{"instances": [[1]]}`, http.StatusOK,
+			`[{"is_trousers":true,"label":"trouser","prediction":1,"probabilities":[0,1]}]`},
+		{"regression", regression, "POST", "/predict", `[1.5, 2]`, http.StatusOK, `{"prediction":3.5}`},
+		{"preprocessing", pipeline, "POST", "/predict/batch", `[[3, 10], [4, 0]]`, http.StatusOK, `[{"prediction":10.5},{"prediction":1}]`},
+		{"categories", categories, "POST", "/predict", `["b", 2]`, http.StatusOK, `{"prediction":12}`},
+		{"categories batch", categories, "POST", "/predict/batch", `{"instances": [["c", 4], ["a", 3]]}`, http.StatusOK,
+			`[{"prediction":24},{"prediction":3}]`},
+		{"unknown category", categories, "POST", "/predict", `["d", 1]`, http.StatusUnprocessableEntity, `"d"`},
+		{"category without pipeline", classifier, "POST", "/predict", `["a"]`, http.StatusUnprocessableEntity, "not a number"},
+		{"too many features", classifier, "POST", "/predict", `[0, 1]`, http.StatusUnprocessableEntity, "expected 1 features, got 2"},
+		{"bad instance in batch", classifier, "POST", "/predict/batch", `[[0], []]`, http.StatusUnprocessableEntity, "instance 1"},
+		{"model panics", regression, "POST", "/predict", `[1]`, http.StatusUnprocessableEntity, "prediction failed"},
+		{"empty batch", classifier, "POST", "/predict/batch", `[]`, http.StatusBadRequest, errEmptyBatch.Error()},
+		{"invalid JSON", classifier, "POST", "/predict", `[0,`, http.StatusBadRequest, "unexpected EOF"},
+		{"missing field", classifier, "POST", "/predict", `{"instances": [[0]]}`, http.StatusBadRequest, `no "features" field`},
+		{"wrong method", classifier, "GET", "/predict", ``, http.StatusMethodNotAllowed, "requires POST"},
+		{"health", classifier, "GET", "/healthz", ``, http.StatusOK, `{"status":"ok"}`},
+		{"metadata", classifier, "GET", "/metadata", ``, http.StatusOK,
+			`{"version":0,"kind":"","library":"","created":"0001-01-01T00:00:00Z","features":["x"],"labels":["shirt","trouser"]}`},
+		{"unknown endpoint", classifier, "GET", "/predictions", ``, http.StatusNotFound, "no such endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.server.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type %q, want application/json", ct)
+			}
+			got := strings.TrimSpace(w.Body.String())
+			if tt.status != http.StatusOK {
+				var e map[string]string
+				if err := json.Unmarshal([]byte(got), &e); err != nil || !strings.Contains(e["error"], tt.want) {
+					t.Errorf("response %s, want an error mentioning %s", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("response %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPredictionServer(t *testing.T) {
+	tests := []struct {
+		name string
+		meta ModelMetadata
+	}{
+		{"preprocessing without features", ModelMetadata{Preprocessing: []byte(`[]`)}},
+		{"unknown transformer", ModelMetadata{Features: []string{"a"}, Preprocessing: []byte(`[{"kind": "magic", "params": {}}]`)}},
+	}
+	for _, tt := range tests {
+		if _, err := NewPredictionServer(thresholdClassifier{}, tt.meta); err == nil {
+			t.Errorf("%s: NewPredictionServer() succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package mlutil
+
+import (
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// SplitConfig controls how SplitWith and SplitThreeWay shuffle and partition a dataframe.
+type SplitConfig struct {
+	// Rand is the source of randomness. If it is nil a new source seeded with Seed is used, so the same Seed always
+	// gives the same split.
+	Rand *rand.Rand
+	Seed int64
+	// Stratify is the optional name of a column (eg. "Label", "species" or "Position") whose values are kept in the
+	// same proportions in every subset.
+	Stratify string
+}
+
+func (c SplitConfig) rand() *rand.Rand {
+	if c.Rand != nil {
+		return c.Rand
+	}
+	return rand.New(rand.NewSource(c.Seed))
+}
+
+// Split splits the dataframe into training and validation subsets. trainFraction (0 <= trainFraction <= 1) of the
+// samples are used for training and the rest are reserved for validation.
+// The split uses the global math/rand source; use SplitWith for reproducible or stratified splits.
+func Split(df dataframe.DataFrame, trainFraction float64) (training dataframe.DataFrame, validation dataframe.DataFrame, err error) {
+	if df.Err != nil {
+		return training, validation, df.Err
+	}
+	if trainFraction < 0 || trainFraction > 1 {
+		return training, validation, fmt.Errorf("mlutil: training fraction %v is not between 0 and 1", trainFraction)
+	}
+	perm := rand.Perm(df.Nrow())
+	cutoff := int(trainFraction * float64(len(perm)))
+	training = df.Subset(perm[:cutoff])
+	validation = df.Subset(perm[cutoff:])
+	return training, validation, nil
+}
+
+// SplitWith splits the dataframe into training and validation subsets like Split, using the randomness and
+// stratification given by cfg.
+func SplitWith(df dataframe.DataFrame, trainFraction float64, cfg SplitConfig) (training dataframe.DataFrame, validation dataframe.DataFrame, err error) {
+	parts, err := SplitIndices(df, []float64{trainFraction, 1 - trainFraction}, cfg)
+	if err != nil {
+		return training, validation, err
+	}
+	return df.Subset(parts[0]), df.Subset(parts[1]), nil
+}
+
+// SplitThreeWay splits the dataframe into training, validation and test subsets. trainFraction and
+// validationFraction of the samples go to the first two subsets and the rest are reserved for testing.
+func SplitThreeWay(df dataframe.DataFrame, trainFraction, validationFraction float64, cfg SplitConfig) (training, validation, test dataframe.DataFrame, err error) {
+	parts, err := SplitIndices(df, []float64{trainFraction, validationFraction, 1 - trainFraction - validationFraction}, cfg)
+	if err != nil {
+		return training, validation, test, err
+	}
+	return df.Subset(parts[0]), df.Subset(parts[1]), df.Subset(parts[2]), nil
+}
+
+// SplitIndices shuffles the row indices of df and partitions them according to fractions, which must be
+// non-negative and sum to 1. If cfg.Stratify is set every stratum is partitioned separately so that each part keeps
+// the class proportions of the whole dataframe.
+func SplitIndices(df dataframe.DataFrame, fractions []float64, cfg SplitConfig) ([][]int, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	if err := checkFractions(fractions); err != nil {
+		return nil, err
+	}
+	strata, err := strataIndices(df, cfg.Stratify)
+	if err != nil {
+		return nil, err
+	}
+	return splitStrata(strata, fractions, cfg.rand()), nil
+}
+
+// checkFractions checks that fractions are non-negative and sum to 1.
+func checkFractions(fractions []float64) error {
+	var total float64
+	for _, f := range fractions {
+		// allow for rounding in callers computing the last fraction as 1 minus the others
+		if f < -1e-9 || f > 1+1e-9 {
+			return fmt.Errorf("mlutil: split fraction %v is not between 0 and 1", f)
+		}
+		total += f
+	}
+	if math.Abs(total-1) > 1e-9 {
+		return fmt.Errorf("mlutil: split fractions sum to %v, not 1", total)
+	}
+	return nil
+}
+
+// splitStrata shuffles every stratum of indices and partitions it according to fractions.
+func splitStrata(strata [][]int, fractions []float64, rng *rand.Rand) [][]int {
+	parts := make([][]int, len(fractions), len(fractions))
+	for _, rows := range strata {
+		rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+		start := 0
+		cumulative := 0.
+		for p, f := range fractions {
+			cumulative += f
+			end := int(math.Round(cumulative * float64(len(rows))))
+			if p == len(fractions)-1 || end > len(rows) {
+				end = len(rows)
+			}
+			parts[p] = append(parts[p], rows[start:end]...)
+			start = end
+		}
+	}
+	// strata are appended one after the other, so shuffle again to avoid handing sorted data to the trainers
+	for _, part := range parts {
+		rng.Shuffle(len(part), func(i, j int) { part[i], part[j] = part[j], part[i] })
+	}
+	return parts
+}
+
+// strataIndices groups the row indices of df by the value of col, in sorted order of the values. An empty col
+// puts all rows in a single group.
+func strataIndices(df dataframe.DataFrame, col string) ([][]int, error) {
+	if col == "" {
+		rows := make([]int, df.Nrow(), df.Nrow())
+		for i := range rows {
+			rows[i] = i
+		}
+		return [][]int{rows}, nil
+	}
+	s := df.Col(col)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	groups := make(map[string][]int)
+	for i, val := range s.Records() {
+		groups[val] = append(groups[val], i)
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([][]int, len(keys), len(keys))
+	for i, k := range keys {
+		ret[i] = groups[k]
+	}
+	return ret, nil
+}
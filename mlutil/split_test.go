@@ -0,0 +1,89 @@
+package mlutil
+
+import (
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"testing"
+)
+
+// labelFrame returns a dataframe of n rows whose Label column is 1 for every fourth row and 0 otherwise.
+func labelFrame(n int) dataframe.DataFrame {
+	ids := make([]int, n, n)
+	labels := make([]int, n, n)
+	for i := range ids {
+		ids[i] = i
+		if i%4 == 0 {
+			labels[i] = 1
+		}
+	}
+	return dataframe.New(series.New(ids, series.Int, "id"), series.New(labels, series.Int, "Label"))
+}
+
+func TestSplitIndices(t *testing.T) {
+	df := labelFrame(80)
+	cfg := SplitConfig{Seed: 3, Stratify: "Label"}
+	parts, err := SplitIndices(df, []float64{0.5, 0.25, 0.25}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool)
+	for p, part := range parts {
+		positives := 0
+		for _, row := range part {
+			if seen[row] {
+				t.Fatalf("row %d is in more than one part", row)
+			}
+			seen[row] = true
+			if row%4 == 0 {
+				positives++
+			}
+		}
+		// every part keeps the 1 in 4 share of positives
+		if positives*4 != len(part) {
+			t.Errorf("part %d has %d positives out of %d rows", p, positives, len(part))
+		}
+	}
+	if len(seen) != 80 || len(parts[0]) != 40 || len(parts[1]) != 20 || len(parts[2]) != 20 {
+		t.Errorf("parts of %d, %d and %d rows covering %d rows, want 40, 20 and 20 covering 80", len(parts[0]), len(parts[1]), len(parts[2]), len(seen))
+	}
+
+	again, err := SplitIndices(df, []float64{0.5, 0.25, 0.25}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := range parts {
+		for i := range parts[p] {
+			if again[p][i] != parts[p][i] {
+				t.Fatalf("the same seed gave part %d as %v and then %v", p, parts[p], again[p])
+			}
+		}
+	}
+}
+
+func TestSplitIndicesErrors(t *testing.T) {
+	df := labelFrame(8)
+	tests := []struct {
+		name      string
+		fractions []float64
+		cfg       SplitConfig
+	}{
+		{"negative fraction", []float64{1.2, -0.2}, SplitConfig{}},
+		{"sum below 1", []float64{0.5, 0.4}, SplitConfig{}},
+		{"missing stratify column", []float64{0.5, 0.5}, SplitConfig{Stratify: "species"}},
+	}
+	for _, tt := range tests {
+		if _, err := SplitIndices(df, tt.fractions, tt.cfg); err == nil {
+			t.Errorf("%s: SplitIndices() succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestSplitThreeWay(t *testing.T) {
+	training, validation, test, err := SplitThreeWay(labelFrame(20), 0.6, 0.2, SplitConfig{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if training.Nrow() != 12 || validation.Nrow() != 4 || test.Nrow() != 4 {
+		t.Errorf("split into %d, %d and %d rows, want 12, 4 and 4", training.Nrow(), validation.Nrow(), test.Nrow())
+	}
+}
@@ -0,0 +1,378 @@
+package mlutil
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/cdipaolo/goml/base"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BatchReader reads a dataset a mini-batch at a time, so that datasets larger than memory can be used for training
+// and evaluation.
+type BatchReader interface {
+	// Next returns the features and targets of the next batch of rows. It returns io.EOF after the last batch.
+	Next() (x [][]float64, y []float64, err error)
+}
+
+// CSVChunkReader reads a CSV file with a Schema as a sequence of dataframes of at most Size rows, validating every
+// field like Schema.ReadCSV.
+type CSVChunkReader struct {
+	Schema Schema
+	// Size is the maximum number of rows of a chunk. 0 reads the whole file as a single chunk.
+	Size int
+
+	cr    *csv.Reader
+	first bool
+}
+
+// NewCSVChunkReader returns a reader of chunks of size rows of the CSV data in r.
+func NewCSVChunkReader(r io.Reader, schema Schema, size int) (*CSVChunkReader, error) {
+	if len(schema.Columns) == 0 {
+		return nil, errors.New("mlutil: schema has no columns")
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("mlutil: invalid chunk size %d", size)
+	}
+	cr := csv.NewReader(r)
+	if schema.Comma != 0 {
+		cr.Comma = schema.Comma
+	}
+	cr.FieldsPerRecord = len(schema.Columns)
+	if schema.Header == SkipHeader {
+		// the line that is skipped need not have the same number of fields
+		cr.FieldsPerRecord = -1
+	}
+	cr.ReuseRecord = true
+	return &CSVChunkReader{Schema: schema, Size: size, cr: cr, first: true}, nil
+}
+
+// Next returns the next chunk of rows. It returns io.EOF after the last chunk.
+func (r *CSVChunkReader) Next() (dataframe.DataFrame, error) {
+	df, n, err := r.read()
+	if err == nil && n == 0 {
+		return df, io.EOF
+	}
+	return df, err
+}
+
+// read reads up to Size rows and returns them as a dataframe, which has no rows at the end of the file.
+func (r *CSVChunkReader) read() (dataframe.DataFrame, int, error) {
+	s := r.Schema
+	records := make([][]string, len(s.Columns), len(s.Columns))
+	n := 0
+	for r.Size == 0 || n < r.Size {
+		record, err := r.cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				return dataframe.DataFrame{}, 0, &SchemaError{Line: perr.Line, Err: perr.Err}
+			}
+			return dataframe.DataFrame{}, 0, err
+		}
+		line, _ := r.cr.FieldPos(0)
+		if r.first {
+			r.first = false
+			switch s.Header {
+			case SkipHeader:
+				continue
+			case MatchHeader:
+				if err := s.matchHeader(record, line); err != nil {
+					return dataframe.DataFrame{}, 0, err
+				}
+				continue
+			}
+		}
+		if len(record) != len(s.Columns) {
+			return dataframe.DataFrame{}, 0, &SchemaError{Line: line, Err: fmt.Errorf("expected %d fields, got %d", len(s.Columns), len(record))}
+		}
+		for j, c := range s.Columns {
+			v, err := s.parse(c, record[j])
+			if err != nil {
+				line, _ := r.cr.FieldPos(j)
+				return dataframe.DataFrame{}, 0, &SchemaError{Line: line, Column: c.Name, Err: err}
+			}
+			records[j] = append(records[j], v)
+		}
+		n++
+	}
+
+	cols := make([]series.Series, len(s.Columns), len(s.Columns))
+	for j, c := range s.Columns {
+		cols[j] = series.New(records[j], c.Type, c.Name)
+		if cols[j].Err != nil {
+			return dataframe.DataFrame{}, 0, cols[j].Err
+		}
+	}
+	df := dataframe.New(cols...)
+	return df, n, df.Err
+}
+
+// CSVBatchReader is a BatchReader of the chunks of a CSVChunkReader, converted with DataFrameToXYs.
+type CSVBatchReader struct {
+	Chunks *CSVChunkReader
+	// Target is the column returned as y. If empty, y is nil.
+	Target string
+	// Preprocessing, if not nil, is a fitted pipeline applied to every chunk before it is converted.
+	Preprocessing *Pipeline
+}
+
+// NewCSVBatchReader returns a reader of batches of size rows of the CSV data in r, with the target column as y.
+func NewCSVBatchReader(r io.Reader, schema Schema, target string, size int) (*CSVBatchReader, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("mlutil: invalid batch size %d", size)
+	}
+	chunks, err := NewCSVChunkReader(r, schema, size)
+	if err != nil {
+		return nil, err
+	}
+	return &CSVBatchReader{Chunks: chunks, Target: target}, nil
+}
+
+// Next implements BatchReader.
+func (r *CSVBatchReader) Next() ([][]float64, []float64, error) {
+	df, err := r.Chunks.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.Preprocessing != nil {
+		if df, err = r.Preprocessing.Transform(df); err != nil {
+			return nil, nil, err
+		}
+	}
+	return DataFrameToXYs(df, r.Target)
+}
+
+// MNISTBatchReader is a BatchReader of the images and labels of an MNIST style pair of IDX files, which may be
+// gzipped, such as the Fashion-MNIST files downloaded by download-fashion-mnist.sh. Only one batch of images is held
+// in memory at a time. The pixels are normalised like ImageSet.Floats, and y holds the labels.
+type MNISTBatchReader struct {
+	Size int
+	// N is the number of images in the files, and Rows and Cols the size of each image.
+	N, Rows, Cols int
+
+	read   int
+	images *IDXReader
+	labels *IDXReader
+	files  []*os.File
+	pixels []byte
+	label  []byte
+}
+
+// OpenMNISTBatches opens a reader of batches of size images from the given image and label files.
+func OpenMNISTBatches(imagesPath, labelsPath string, size int) (*MNISTBatchReader, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("mlutil: invalid batch size %d", size)
+	}
+	r := &MNISTBatchReader{Size: size}
+	images, err := r.open(imagesPath, 3)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	labels, err := r.open(labelsPath, 1)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	if images.Dims[0] != labels.Dims[0] {
+		r.Close()
+		return nil, fmt.Errorf("mlutil: %d images but %d labels", images.Dims[0], labels.Dims[0])
+	}
+	r.N, r.Rows, r.Cols = images.Dims[0], images.Dims[1], images.Dims[2]
+	r.images, r.labels = images, labels
+	r.pixels, r.label = make([]byte, images.RecordSize()), make([]byte, 1)
+	return r, nil
+}
+
+// open opens an IDX file of unsigned bytes of the given rank.
+func (r *MNISTBatchReader) open(path string, rank int) (*IDXReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r.files = append(r.files, f)
+	ir, err := NewIDXReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s)", err, path)
+	}
+	if ir.Type != IDXUint8 || len(ir.Dims) != rank {
+		return nil, fmt.Errorf("mlutil: %s is not an IDX file of unsigned bytes of rank %d", path, rank)
+	}
+	return ir, nil
+}
+
+// Skip discards the next n images, for example those that were used for training.
+func (r *MNISTBatchReader) Skip(n int) error {
+	if n > r.N-r.read {
+		n = r.N - r.read
+	}
+	if err := r.images.Skip(n); err != nil {
+		return err
+	}
+	if err := r.labels.Skip(n); err != nil {
+		return err
+	}
+	r.read += n
+	return nil
+}
+
+// Next implements BatchReader.
+func (r *MNISTBatchReader) Next() ([][]float64, []float64, error) {
+	n := r.N - r.read
+	if n == 0 {
+		return nil, nil, io.EOF
+	}
+	if n > r.Size {
+		n = r.Size
+	}
+	x := make([][]float64, n, n)
+	y := make([]float64, n, n)
+	for i := range x {
+		if err := r.images.ReadRecord(r.pixels); err != nil {
+			return nil, nil, fmt.Errorf("mlutil: reading image %d: %v", r.read+i, err)
+		}
+		if err := r.labels.ReadRecord(r.label); err != nil {
+			return nil, nil, fmt.Errorf("mlutil: reading label %d: %v", r.read+i, err)
+		}
+		x[i], y[i] = NormalizeBytes(r.pixels), float64(r.label[0])
+	}
+	r.read += n
+	return x, y, nil
+}
+
+// Close closes the files of the reader.
+func (r *MNISTBatchReader) Close() error {
+	var ret error
+	for _, f := range r.files {
+		if err := f.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	r.files = nil
+	return ret
+}
+
+// Review is a review of the processed_acl sentiment dataset: the number of times each word or bigram occurs in it,
+// and its label, "positive" or "negative".
+type Review struct {
+	Features map[string]int
+	Label    string
+}
+
+// ReviewReader reads the reviews of a processed_acl file, such as kitchen/positive.review, a batch at a time. Every
+// line of the file is a review of space separated "feature:count" pairs, ending with "#label#:<label>".
+type ReviewReader struct {
+	Size int
+
+	r    *bufio.Reader
+	line int
+}
+
+// NewReviewReader returns a reader of batches of size reviews from r.
+func NewReviewReader(r io.Reader, size int) (*ReviewReader, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("mlutil: invalid batch size %d", size)
+	}
+	return &ReviewReader{Size: size, r: bufio.NewReader(r)}, nil
+}
+
+// Next returns the next batch of reviews. It returns io.EOF after the last batch.
+func (r *ReviewReader) Next() ([]Review, error) {
+	var ret []Review
+	for len(ret) < r.Size {
+		// lines can be longer than a bufio.Scanner token
+		line, err := r.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		r.line++
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		review, perr := parseReview(line)
+		if perr != nil {
+			return nil, fmt.Errorf("mlutil: line %d: %v", r.line, perr)
+		}
+		ret = append(ret, review)
+	}
+	if len(ret) == 0 {
+		return nil, io.EOF
+	}
+	return ret, nil
+}
+
+// parseReview parses a line of a processed_acl file.
+func parseReview(line string) (Review, error) {
+	ret := Review{Features: make(map[string]int)}
+	for _, pair := range strings.Fields(line) {
+		i := strings.LastIndexByte(pair, ':')
+		if i < 0 {
+			return ret, fmt.Errorf("%q is not a feature:count pair", pair)
+		}
+		feature, value := pair[:i], pair[i+1:]
+		if feature == "#label#" {
+			ret.Label = value
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return ret, fmt.Errorf("%q is not a feature:count pair", pair)
+		}
+		ret.Features[feature] += count
+	}
+	return ret, nil
+}
+
+// PredictBatches returns the targets and the predictions of m for every row read from r, holding only one batch of
+// features in memory at a time.
+func PredictBatches(m Model, r BatchReader) (yTrue, yPred []float64, err error) {
+	for {
+		x, y, err := r.Next()
+		if err == io.EOF {
+			return yTrue, yPred, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		p, err := PredictAll(m, x)
+		if err != nil {
+			return nil, nil, err
+		}
+		yTrue, yPred = append(yTrue, y...), append(yPred, p...)
+	}
+}
+
+// SendDatapoints sends every row read from r to data and then closes it, for training goml models online, eg. with
+// Logistic.OnlineLearn. The targets are sent as single element slices.
+func SendDatapoints(r BatchReader, data chan<- base.Datapoint) error {
+	defer close(data)
+	for {
+		x, y, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for i := range x {
+			dp := base.Datapoint{X: x[i]}
+			if y != nil {
+				dp.Y = []float64{y[i]}
+			}
+			data <- dp
+		}
+	}
+}
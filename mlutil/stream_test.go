@@ -0,0 +1,176 @@
+package mlutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"github.com/cdipaolo/goml/base"
+	"github.com/go-gota/gota/series"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pointsSchema is the schema of headerless CSV data of a feature x and a target y.
+var pointsSchema = Schema{Columns: []ColumnSpec{
+	{Name: "x", Type: series.Float},
+	{Name: "y", Type: series.Float},
+}}
+
+func TestCSVChunkReader(t *testing.T) {
+	r, err := NewCSVChunkReader(strings.NewReader("1,10\n2,20\n3,30\n4,40\n5,50\n"), pointsSchema, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	for {
+		df, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, df.Nrow())
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[2] != 1 {
+		t.Errorf("chunks of %v rows, want 2, 2 and 1", sizes)
+	}
+
+	// the line of an invalid field is counted across chunks
+	r, err = NewCSVChunkReader(strings.NewReader("x,y\n1,10\n2,20\n3,oops\n"), Schema{Header: MatchHeader, Columns: pointsSchema.Columns}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("Next() of an invalid chunk returned %v, want an error at line 4", err)
+	}
+	if _, err := NewCSVChunkReader(strings.NewReader(""), pointsSchema, -1); err == nil {
+		t.Error("NewCSVChunkReader() with a negative size succeeded, want an error")
+	}
+}
+
+func TestPredictBatches(t *testing.T) {
+	r, err := NewCSVBatchReader(strings.NewReader("1,10\n2,20\n3,30\n"), pointsSchema, "y", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yTrue, yPred, err := PredictBatches(modelFunc(func(x []float64) float64 { return 10 * x[0] }), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(yTrue) != 3 || len(yPred) != 3 || yTrue[2] != 30 || yPred[2] != 30 {
+		t.Errorf("PredictBatches() = %v, %v, want the targets 10, 20 and 30 twice", yTrue, yPred)
+	}
+
+	r, err = NewCSVBatchReader(strings.NewReader("1,10\n2,20\n3,30\n"), pointsSchema, "y", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(chan base.Datapoint, 3)
+	if err := SendDatapoints(r, data); err != nil {
+		t.Fatal(err)
+	}
+	var sent []base.Datapoint
+	for dp := range data {
+		sent = append(sent, dp)
+	}
+	if len(sent) != 3 || sent[1].X[0] != 2 || sent[1].Y[0] != 20 {
+		t.Errorf("SendDatapoints() sent %v, want 3 points, the second ([2], [20])", sent)
+	}
+}
+
+// writeMNISTFile writes an IDX file of unsigned bytes with the given magic number, dimensions and data, gzipped if
+// the path ends in .gz.
+func writeMNISTFile(t *testing.T, path string, magic uint32, dims []uint32, data []byte) {
+	t.Helper()
+	var b bytes.Buffer
+	var w io.Writer = &b
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(&b)
+		w = gz
+	}
+	binary.Write(w, binary.BigEndian, magic)
+	binary.Write(w, binary.BigEndian, dims)
+	w.Write(data)
+	if gz != nil {
+		gz.Close()
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMNISTBatchReader(t *testing.T) {
+	dir := t.TempDir()
+	images, labels := filepath.Join(dir, "images.gz"), filepath.Join(dir, "labels")
+	// three 1x2 images, each labelled with its index
+	writeMNISTFile(t, images, 0x0803, []uint32{3, 1, 2}, []byte{0, 255, 51, 0, 255, 255})
+	writeMNISTFile(t, labels, 0x0801, []uint32{3}, []byte{0, 1, 2})
+
+	r, err := OpenMNISTBatches(images, labels, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.N != 3 || r.Rows != 1 || r.Cols != 2 {
+		t.Fatalf("%d images of %dx%d, want 3 of 1x2", r.N, r.Rows, r.Cols)
+	}
+	if err := r.Skip(1); err != nil {
+		t.Fatal(err)
+	}
+	x, y, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 2 || x[0][0] != 0.2 || x[1][1] != 1 || y[0] != 1 || y[1] != 2 {
+		t.Errorf("batch %v with labels %v, want the last two images", x, y)
+	}
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() after the last image returned %v, want io.EOF", err)
+	}
+
+	if _, err := OpenMNISTBatches(labels, labels, 2); err == nil {
+		t.Error("OpenMNISTBatches() of a label file as images succeeded, want an error")
+	}
+	writeMNISTFile(t, labels, 0x0801, []uint32{2}, []byte{0, 1})
+	if _, err := OpenMNISTBatches(images, labels, 2); err == nil {
+		t.Error("OpenMNISTBatches() with fewer labels than images succeeded, want an error")
+	}
+}
+
+func TestReviewReader(t *testing.T) {
+	data := "great:2 great_value:1 #label#:positive\n\nbroke:1 #label#:negative\nrefund:3 #label#:negative"
+	r, err := NewReviewReader(strings.NewReader(data), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].Label != "positive" || batch[0].Features["great"] != 2 || batch[1].Features["broke"] != 1 {
+		t.Errorf("first batch %+v, want a positive and a negative review", batch)
+	}
+	batch, err = r.Next()
+	if err != nil || len(batch) != 1 || batch[0].Features["refund"] != 3 {
+		t.Errorf("last batch %+v, %v, want the review of a refund without a final newline", batch, err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() after the last review returned %v, want io.EOF", err)
+	}
+
+	r, err = NewReviewReader(strings.NewReader("good:1 #label#:positive\ngood #label#:positive\n"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Next() of a malformed review returned %v, want an error at line 2", err)
+	}
+}
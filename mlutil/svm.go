@@ -0,0 +1,103 @@
+package mlutil
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/datastream/libsvm"
+)
+
+// SVMModel adapts libsvm to the Model interface. Set Param.Probability to 1 to be able to use PredictProba.
+type SVMModel struct {
+	Param libsvm.SVMParameter
+
+	svm   *libsvm.SVM
+	model *libsvm.SVMModel
+}
+
+// NewSVMModel returns an unfitted support vector machine with the given parameters.
+func NewSVMModel(param libsvm.SVMParameter) *SVMModel {
+	return &SVMModel{Param: param}
+}
+
+// WrapSVM returns an adapter for a model that has already been trained with svm.
+func WrapSVM(svm *libsvm.SVM, model *libsvm.SVMModel) *SVMModel {
+	return &SVMModel{Param: *model.Param, svm: svm, model: model}
+}
+
+// Fit trains the support vector machine on x and y.
+func (m *SVMModel) Fit(x [][]float64, y []float64) error {
+	var problem libsvm.SVMProblem
+	problem.L = len(x)
+	for i := range x {
+		problem.X = append(problem.X, FloatsToSVMNode(x[i]))
+	}
+	problem.Y = y
+
+	svm := libsvm.NewSvm()
+	param := m.Param
+	if msg := svm.SVMCheckParameter(&problem, &param); msg != "" {
+		return errors.New("mlutil: " + msg)
+	}
+	m.svm = svm
+	m.model = svm.SVMTrain(&problem, &param)
+	return nil
+}
+
+// Predict returns the predicted class label, or the predicted value for regression SVMs.
+func (m *SVMModel) Predict(x []float64) (float64, error) {
+	if m.model == nil {
+		return 0, ErrNotFitted
+	}
+	return m.svm.SVMPredict(m.model, FloatsToSVMNode(x)), nil
+}
+
+// PredictProba returns the probability of each class, indexed by class label. libsvm orders its probability
+// estimates by the order in which it first saw the labels, so they are rearranged here.
+func (m *SVMModel) PredictProba(x []float64) ([]float64, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	if m.svm.SVMCheckProbabilityModel(m.model) == 0 {
+		return nil, errors.New("mlutil: SVM was not trained with Probability set")
+	}
+	p := make([]float64, m.model.NrClass, m.model.NrClass)
+	m.svm.SVMPredictProbability(m.model, FloatsToSVMNode(x), p)
+	k := 0
+	for _, label := range m.model.Label {
+		if label+1 > k {
+			k = label + 1
+		}
+	}
+	ret := make([]float64, k, k)
+	for i, label := range m.model.Label {
+		if label >= 0 {
+			ret[label] = p[i]
+		}
+	}
+	return ret, nil
+}
+
+func (m *SVMModel) modelKind() (string, string) {
+	return "svm", "libsvm"
+}
+
+// marshalModel writes the libsvm model struct, which holds the support vectors, their coefficients and the
+// probability information, as JSON rather than in libsvm's text format, which datastream/libsvm cannot load back.
+func (m *SVMModel) marshalModel() ([]byte, error) {
+	if m.model == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(m.model)
+}
+
+func (m *SVMModel) unmarshalModel(data []byte) error {
+	model := new(libsvm.SVMModel)
+	if err := json.Unmarshal(data, model); err != nil {
+		return err
+	}
+	if model.Param == nil || model.NrClass == 0 {
+		return errors.New("SVM model has no parameters")
+	}
+	*m = SVMModel{Param: *model.Param, svm: libsvm.NewSvm(), model: model}
+	return nil
+}
@@ -0,0 +1,590 @@
+package mlutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Criterion is the impurity a decision tree minimises when it chooses a split.
+type Criterion int
+
+const (
+	// SquaredError is the variance of the targets, for regression trees.
+	SquaredError Criterion = iota
+	// Gini is the Gini impurity of the classes, for classification trees.
+	Gini
+	// Entropy is the entropy of the classes in bits, for classification trees.
+	Entropy
+)
+
+var criterionNames = []string{"squared_error", "gini", "entropy"}
+
+// String returns the name of the criterion, eg. "gini".
+func (c Criterion) String() string {
+	if c < 0 || int(c) >= len(criterionNames) {
+		return fmt.Sprintf("Criterion(%d)", int(c))
+	}
+	return criterionNames[c]
+}
+
+// ParseCriterion returns the criterion with the given name.
+func ParseCriterion(name string) (Criterion, error) {
+	for i, n := range criterionNames {
+		if n == name {
+			return Criterion(i), nil
+		}
+	}
+	return 0, fmt.Errorf("mlutil: unknown tree criterion %q", name)
+}
+
+// classification reports whether c is a criterion for classification trees.
+func (c Criterion) classification() bool {
+	return c == Gini || c == Entropy
+}
+
+// TreeNode is a node of a fitted decision tree. The examples with x[Feature] <= Threshold go to Left and the others
+// to Right; leaves have no children.
+type TreeNode struct {
+	Feature   int       `json:"feature"`
+	Threshold float64   `json:"threshold"`
+	Left      *TreeNode `json:"left,omitempty"`
+	Right     *TreeNode `json:"right,omitempty"`
+	// Value is the mean target of the training examples of the node for regression trees, and their most common
+	// class for classification trees. Proba is the proportion of each class among them, for classification trees.
+	Value float64   `json:"value"`
+	Proba []float64 `json:"proba,omitempty"`
+	// Impurity is the criterion of the training examples of the node, and Samples their number.
+	Impurity float64 `json:"impurity"`
+	Samples  int     `json:"samples"`
+}
+
+// Leaf reports whether n has no children.
+func (n *TreeNode) Leaf() bool {
+	return n.Left == nil || n.Right == nil
+}
+
+// TreeModel is a CART decision tree for regression or classification, depending on its Criterion. The labels of
+// classification trees are the class indices 0, 1, ...
+type TreeModel struct {
+	Criterion Criterion
+	// MaxDepth limits the depth of the tree, the root being at depth 0. 0 grows the tree until its leaves are pure.
+	MaxDepth int
+	// MinSamplesSplit is the number of examples a node needs to be split, 2 if 0. MinSamplesLeaf is the number of
+	// examples each side of a split needs, 1 if 0.
+	MinSamplesSplit int
+	MinSamplesLeaf  int
+	// CCPAlpha is the complexity parameter of minimal cost-complexity pruning. After the tree is grown, the subtrees
+	// that do not reduce the impurity, weighted by the fraction of the examples they hold, by more than CCPAlpha per
+	// extra leaf are collapsed into leaves. 0 does not prune.
+	CCPAlpha float64
+	// MaxFeatures is the number of features, chosen at random with Seed, that are considered at each split. 0
+	// considers every feature.
+	MaxFeatures int
+	Seed        int64
+	// FeatureNames and ClassNames label the splits and leaves in Text and WriteDOT. If empty, the features are
+	// named x[0], x[1], ... and the classes by their indices.
+	FeatureNames []string
+	ClassNames   []string
+
+	root     *TreeNode
+	features int
+	classes  int
+}
+
+// NewTreeModel returns an unfitted decision tree with the given criterion, maximum depth and minimum number of
+// examples per leaf.
+func NewTreeModel(criterion Criterion, maxDepth, minSamplesLeaf int) *TreeModel {
+	return &TreeModel{Criterion: criterion, MaxDepth: maxDepth, MinSamplesLeaf: minSamplesLeaf}
+}
+
+// Fit grows the tree on x and y and prunes it with CCPAlpha.
+func (m *TreeModel) Fit(x [][]float64, y []float64) error {
+	if len(x) == 0 {
+		return errors.New("mlutil: no training examples")
+	}
+	if len(x) != len(y) {
+		return fmt.Errorf("mlutil: %d examples but %d targets", len(x), len(y))
+	}
+	if m.Criterion < SquaredError || m.Criterion > Entropy {
+		return fmt.Errorf("mlutil: unknown tree criterion %d", int(m.Criterion))
+	}
+	b := &treeBuilder{
+		m:        m,
+		x:        x,
+		y:        y,
+		features: len(x[0]),
+		minSplit: m.MinSamplesSplit,
+		minLeaf:  m.MinSamplesLeaf,
+		rnd:      rand.New(rand.NewSource(m.Seed)),
+	}
+	if b.minLeaf < 1 {
+		b.minLeaf = 1
+	}
+	if b.minSplit < 2 {
+		b.minSplit = 2
+	}
+	if m.Criterion.classification() {
+		for i, v := range y {
+			if v < 0 || v != math.Trunc(v) {
+				return fmt.Errorf("mlutil: class label %v of example %d is not a class index", v, i)
+			}
+			if int(v)+1 > b.classes {
+				b.classes = int(v) + 1
+			}
+		}
+	}
+	b.order = make([]int, b.features, b.features)
+	for j := range b.order {
+		b.order[j] = j
+	}
+	idx := make([]int, len(x), len(x))
+	for i := range idx {
+		idx[i] = i
+	}
+	m.root = b.grow(idx, 0)
+	m.features, m.classes = b.features, b.classes
+	if m.CCPAlpha > 0 {
+		m.Prune(m.CCPAlpha)
+	}
+	return nil
+}
+
+// Predict returns the value of the leaf x falls into: the mean target for regression trees and the most common
+// class for classification trees.
+func (m *TreeModel) Predict(x []float64) (float64, error) {
+	leaf, err := m.leaf(x)
+	if err != nil {
+		return 0, err
+	}
+	return leaf.Value, nil
+}
+
+// PredictProba returns the proportion of each class among the training examples of the leaf x falls into. It
+// returns ErrNotClassifier for regression trees.
+func (m *TreeModel) PredictProba(x []float64) ([]float64, error) {
+	if m.root != nil && !m.Criterion.classification() {
+		return nil, ErrNotClassifier
+	}
+	leaf, err := m.leaf(x)
+	if err != nil {
+		return nil, err
+	}
+	return append([]float64(nil), leaf.Proba...), nil
+}
+
+// leaf returns the leaf x falls into.
+func (m *TreeModel) leaf(x []float64) (*TreeNode, error) {
+	if m.root == nil {
+		return nil, ErrNotFitted
+	}
+	if len(x) < m.features {
+		return nil, fmt.Errorf("mlutil: %d features, the tree was fitted on %d", len(x), m.features)
+	}
+	n := m.root
+	for !n.Leaf() {
+		if x[n.Feature] <= n.Threshold {
+			n = n.Left
+		} else {
+			n = n.Right
+		}
+	}
+	return n, nil
+}
+
+// Root returns the root of the fitted tree, or nil if the model has not been fitted.
+func (m *TreeModel) Root() *TreeNode {
+	return m.root
+}
+
+// Depth returns the depth of the deepest leaf, 0 for a tree that is a single leaf.
+func (m *TreeModel) Depth() int {
+	var depth func(n *TreeNode) int
+	depth = func(n *TreeNode) int {
+		if n == nil || n.Leaf() {
+			return 0
+		}
+		l, r := depth(n.Left), depth(n.Right)
+		if r > l {
+			l = r
+		}
+		return l + 1
+	}
+	return depth(m.root)
+}
+
+// Leaves returns the number of leaves of the tree.
+func (m *TreeModel) Leaves() int {
+	var leaves func(n *TreeNode) int
+	leaves = func(n *TreeNode) int {
+		if n == nil {
+			return 0
+		}
+		if n.Leaf() {
+			return 1
+		}
+		return leaves(n.Left) + leaves(n.Right)
+	}
+	return leaves(m.root)
+}
+
+// FeatureImportances returns the total decrease in impurity brought by the splits on each feature, weighted by the
+// number of examples they split and normalised to sum to 1.
+func (m *TreeModel) FeatureImportances() []float64 {
+	ret := make([]float64, m.features, m.features)
+	var walk func(n *TreeNode)
+	walk = func(n *TreeNode) {
+		if n == nil || n.Leaf() {
+			return
+		}
+		ret[n.Feature] += n.Impurity*float64(n.Samples) - n.Left.Impurity*float64(n.Left.Samples) -
+			n.Right.Impurity*float64(n.Right.Samples)
+		walk(n.Left)
+		walk(n.Right)
+	}
+	walk(m.root)
+	var total float64
+	for _, v := range ret {
+		total += v
+	}
+	for j := range ret {
+		ret[j] = safeDivide(ret[j], total)
+	}
+	return ret
+}
+
+// Prune collapses the subtrees of the fitted tree that do not reduce the weighted impurity by more than alpha per
+// extra leaf, which gives the smallest subtree minimising the impurity plus alpha times the number of leaves. Larger
+// values of alpha prune more.
+func (m *TreeModel) Prune(alpha float64) {
+	if m.root == nil {
+		return
+	}
+	total := float64(m.root.Samples)
+	// prune returns the smallest cost of the subtree at n, its weighted impurity plus alpha per leaf
+	var prune func(n *TreeNode) float64
+	prune = func(n *TreeNode) float64 {
+		leaf := n.Impurity*float64(n.Samples)/total + alpha
+		if n.Leaf() {
+			return leaf
+		}
+		if sub := prune(n.Left) + prune(n.Right); sub < leaf {
+			return sub
+		}
+		n.Feature, n.Threshold, n.Left, n.Right = 0, 0, nil, nil
+		return leaf
+	}
+	prune(m.root)
+}
+
+// featureName returns the name of feature j.
+func (m *TreeModel) featureName(j int) string {
+	if j < len(m.FeatureNames) {
+		return m.FeatureNames[j]
+	}
+	return fmt.Sprintf("x[%d]", j)
+}
+
+// valueString describes the prediction of n.
+func (m *TreeModel) valueString(n *TreeNode) string {
+	if !m.Criterion.classification() {
+		return fmt.Sprintf("value = %.4g", n.Value)
+	}
+	if c := int(n.Value); c < len(m.ClassNames) {
+		return "class = " + m.ClassNames[c]
+	}
+	return fmt.Sprintf("class = %d", int(n.Value))
+}
+
+// Text returns the fitted tree as indented text with a line per split and leaf, eg.
+//
+//	|--- medianIncome <= 5.035
+//	|   |--- value = 1.725 (samples = 11841)
+//	|--- medianIncome >  5.035
+//	|   |--- value = 3.137 (samples = 3639)
+func (m *TreeModel) Text() string {
+	if m.root == nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(n *TreeNode, indent string)
+	walk = func(n *TreeNode, indent string) {
+		if n.Leaf() {
+			fmt.Fprintf(&b, "%s|--- %s (samples = %d)\n", indent, m.valueString(n), n.Samples)
+			return
+		}
+		name := m.featureName(n.Feature)
+		fmt.Fprintf(&b, "%s|--- %s <= %.4g\n", indent, name, n.Threshold)
+		walk(n.Left, indent+"|   ")
+		fmt.Fprintf(&b, "%s|--- %s >  %.4g\n", indent, name, n.Threshold)
+		walk(n.Right, indent+"|   ")
+	}
+	walk(m.root, "")
+	return b.String()
+}
+
+// WriteDOT writes the fitted tree to w in the Graphviz DOT language, with the split, impurity, number of examples
+// and prediction of every node, so that it can be drawn with eg. dot -Tpng tree.dot -o tree.png.
+func (m *TreeModel) WriteDOT(w io.Writer) error {
+	if m.root == nil {
+		return ErrNotFitted
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph Tree {")
+	fmt.Fprintln(bw, `node [shape=box, style="rounded", fontname="helvetica"] ;`)
+	fmt.Fprintln(bw, `edge [fontname="helvetica"] ;`)
+	id := 0
+	var walk func(n *TreeNode) int
+	walk = func(n *TreeNode) int {
+		self := id
+		id++
+		var lines []string
+		if !n.Leaf() {
+			lines = append(lines, fmt.Sprintf("%s <= %.4g", m.featureName(n.Feature), n.Threshold))
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s = %.4g", m.Criterion, n.Impurity),
+			fmt.Sprintf("samples = %d", n.Samples),
+			m.valueString(n))
+		label := strings.Replace(strings.Join(lines, "\n"), `"`, `\"`, -1)
+		fmt.Fprintf(bw, "%d [label=\"%s\"] ;\n", self, strings.Replace(label, "\n", `\n`, -1))
+		if !n.Leaf() {
+			left := walk(n.Left)
+			fmt.Fprintf(bw, "%d -> %d [labeldistance=2.5, labelangle=45, headlabel=\"True\"] ;\n", self, left)
+			right := walk(n.Right)
+			fmt.Fprintf(bw, "%d -> %d [labeldistance=2.5, labelangle=-45, headlabel=\"False\"] ;\n", self, right)
+		}
+		return self
+	}
+	walk(m.root)
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// treeBuilder grows a tree from the examples x and y.
+type treeBuilder struct {
+	m                 *TreeModel
+	x                 [][]float64
+	y                 []float64
+	features, classes int
+	minSplit, minLeaf int
+	rnd               *rand.Rand
+	// order is a scratch permutation of the features, to choose MaxFeatures of them at random
+	order []int
+}
+
+// grow returns the subtree fitted to the examples idx at the given depth. It reorders idx.
+func (b *treeBuilder) grow(idx []int, depth int) *TreeNode {
+	n := b.node(idx)
+	if len(idx) < b.minSplit || len(idx) < 2*b.minLeaf || n.Impurity <= 0 || (b.m.MaxDepth > 0 && depth >= b.m.MaxDepth) {
+		return n
+	}
+	feature, threshold, ok := b.split(idx, n.Impurity*float64(len(idx)))
+	if !ok {
+		return n
+	}
+	// partition idx into the examples that go left and those that go right
+	i, j := 0, len(idx)-1
+	for i <= j {
+		if b.x[idx[i]][feature] <= threshold {
+			i++
+		} else {
+			idx[i], idx[j] = idx[j], idx[i]
+			j--
+		}
+	}
+	n.Feature, n.Threshold = feature, threshold
+	n.Left = b.grow(idx[:i], depth+1)
+	n.Right = b.grow(idx[i:], depth+1)
+	return n
+}
+
+// node returns a leaf for the examples idx.
+func (b *treeBuilder) node(idx []int) *TreeNode {
+	n := &TreeNode{Samples: len(idx)}
+	if !b.m.Criterion.classification() {
+		var sum, sum2 float64
+		for _, i := range idx {
+			sum += b.y[i]
+			sum2 += b.y[i] * b.y[i]
+		}
+		count := float64(len(idx))
+		n.Value = sum / count
+		n.Impurity = math.Max(sum2/count-n.Value*n.Value, 0)
+		return n
+	}
+	counts := make([]float64, b.classes, b.classes)
+	for _, i := range idx {
+		counts[int(b.y[i])]++
+	}
+	n.Impurity = b.impurity(counts, float64(len(idx))) / float64(len(idx))
+	n.Proba = counts
+	for c := range counts {
+		if counts[c] > counts[int(n.Value)] {
+			n.Value = float64(c)
+		}
+	}
+	for c := range n.Proba {
+		n.Proba[c] /= float64(len(idx))
+	}
+	return n
+}
+
+// impurity returns the impurity of a node with the given class counts times its number of examples, n.
+func (b *treeBuilder) impurity(counts []float64, n float64) float64 {
+	if n == 0 {
+		return 0
+	}
+	var ret float64
+	switch b.m.Criterion {
+	case Gini:
+		ret = n
+		for _, c := range counts {
+			ret -= c * c / n
+		}
+	case Entropy:
+		for _, c := range counts {
+			if c > 0 {
+				ret -= c * math.Log2(c/n)
+			}
+		}
+	}
+	return ret
+}
+
+// split returns the split of the examples idx that most reduces their impurity, times their number, from parent.
+// ok is false if no split reduces it.
+func (b *treeBuilder) split(idx []int, parent float64) (feature int, threshold float64, ok bool) {
+	features := b.features
+	if b.m.MaxFeatures > 0 && b.m.MaxFeatures < features {
+		features = b.m.MaxFeatures
+		for j := 0; j < features; j++ {
+			k := j + b.rnd.Intn(b.features-j)
+			b.order[j], b.order[k] = b.order[k], b.order[j]
+		}
+	}
+	best := parent - 1e-12*math.Max(1, math.Abs(parent))
+	sorted := make([]int, len(idx), len(idx))
+	var left, right []float64
+	if b.m.Criterion.classification() {
+		left, right = make([]float64, b.classes, b.classes), make([]float64, b.classes, b.classes)
+	}
+	for _, f := range b.order[:features] {
+		copy(sorted, idx)
+		sort.Slice(sorted, func(i, j int) bool { return b.x[sorted[i]][f] < b.x[sorted[j]][f] })
+		if b.x[sorted[0]][f] == b.x[sorted[len(sorted)-1]][f] {
+			continue
+		}
+
+		// sweep the split point from left to right, moving one example at a time across
+		var sumL, sumR, sum2L, sum2R float64
+		if left != nil {
+			for c := range left {
+				left[c], right[c] = 0, 0
+			}
+			for _, i := range sorted {
+				right[int(b.y[i])]++
+			}
+		} else {
+			for _, i := range sorted {
+				sumR += b.y[i]
+				sum2R += b.y[i] * b.y[i]
+			}
+		}
+		n := len(sorted)
+		for p := 1; p < n; p++ {
+			i := sorted[p-1]
+			if left != nil {
+				left[int(b.y[i])]++
+				right[int(b.y[i])]--
+			} else {
+				sumL, sumR = sumL+b.y[i], sumR-b.y[i]
+				sum2L, sum2R = sum2L+b.y[i]*b.y[i], sum2R-b.y[i]*b.y[i]
+			}
+			lo, hi := b.x[i][f], b.x[sorted[p]][f]
+			if lo == hi || p < b.minLeaf || n-p < b.minLeaf {
+				continue
+			}
+			nl, nr := float64(p), float64(n-p)
+			var cost float64
+			if left != nil {
+				cost = b.impurity(left, nl) + b.impurity(right, nr)
+			} else {
+				cost = sum2L - sumL*sumL/nl + sum2R - sumR*sumR/nr
+			}
+			if cost < best {
+				best, feature, ok = cost, f, true
+				// split half way between the values, unless they are so close that it rounds up to the higher one
+				if threshold = lo + (hi-lo)/2; threshold >= hi {
+					threshold = lo
+				}
+			}
+		}
+	}
+	return feature, threshold, ok
+}
+
+// treeState is the persisted form of TreeModel.
+type treeState struct {
+	Criterion       string    `json:"criterion"`
+	MaxDepth        int       `json:"max_depth"`
+	MinSamplesSplit int       `json:"min_samples_split"`
+	MinSamplesLeaf  int       `json:"min_samples_leaf"`
+	CCPAlpha        float64   `json:"ccp_alpha"`
+	MaxFeatures     int       `json:"max_features"`
+	Seed            int64     `json:"seed"`
+	FeatureNames    []string  `json:"feature_names,omitempty"`
+	ClassNames      []string  `json:"class_names,omitempty"`
+	Features        int       `json:"features"`
+	Classes         int       `json:"classes"`
+	Root            *TreeNode `json:"root"`
+}
+
+func (m *TreeModel) modelKind() (string, string) {
+	return "tree", "mlutil"
+}
+
+func (m *TreeModel) marshalModel() ([]byte, error) {
+	if m.root == nil {
+		return nil, ErrNotFitted
+	}
+	return json.Marshal(treeState{
+		m.Criterion.String(), m.MaxDepth, m.MinSamplesSplit, m.MinSamplesLeaf, m.CCPAlpha, m.MaxFeatures, m.Seed,
+		m.FeatureNames, m.ClassNames, m.features, m.classes, m.root,
+	})
+}
+
+func (m *TreeModel) unmarshalModel(data []byte) error {
+	var s treeState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	criterion, err := ParseCriterion(s.Criterion)
+	if err != nil {
+		return err
+	}
+	if s.Root == nil {
+		return errors.New("tree has no nodes")
+	}
+	*m = TreeModel{
+		Criterion:       criterion,
+		MaxDepth:        s.MaxDepth,
+		MinSamplesSplit: s.MinSamplesSplit,
+		MinSamplesLeaf:  s.MinSamplesLeaf,
+		CCPAlpha:        s.CCPAlpha,
+		MaxFeatures:     s.MaxFeatures,
+		Seed:            s.Seed,
+		FeatureNames:    s.FeatureNames,
+		ClassNames:      s.ClassNames,
+		root:            s.Root,
+		features:        s.Features,
+		classes:         s.Classes,
+	}
+	return nil
+}
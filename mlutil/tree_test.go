@@ -0,0 +1,170 @@
+package mlutil
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// column returns the values of v as examples of a single feature.
+func column(v ...float64) [][]float64 {
+	x := make([][]float64, len(v), len(v))
+	for i := range v {
+		x[i] = []float64{v[i]}
+	}
+	return x
+}
+
+func TestTreeModelSplit(t *testing.T) {
+	tests := []struct {
+		name       string
+		criterion  Criterion
+		x          [][]float64
+		y          []float64
+		feature    int
+		threshold  float64
+		impurity   float64
+		leftValue  float64
+		rightValue float64
+	}{
+		{
+			name:      "regression midpoint",
+			criterion: SquaredError,
+			x:         column(1, 2, 3, 10, 11, 12),
+			y:         []float64{1, 1, 1, 5, 5, 5},
+			feature:   0, threshold: 6.5, impurity: 4, leftValue: 1, rightValue: 5,
+		},
+		{
+			name:      "regression uneven",
+			criterion: SquaredError,
+			x:         column(4, 3, 2, 1),
+			y:         []float64{8, 8, 8, 0},
+			feature:   0, threshold: 1.5, impurity: 12, leftValue: 0, rightValue: 8,
+		},
+		{
+			name:      "gini informative second feature",
+			criterion: Gini,
+			x:         [][]float64{{5, 0}, {1, 0}, {5, 1}, {1, 1}},
+			y:         []float64{0, 0, 1, 1},
+			feature:   1, threshold: 0.5, impurity: 0.5, leftValue: 0, rightValue: 1,
+		},
+		{
+			name:      "entropy informative first feature",
+			criterion: Entropy,
+			x:         [][]float64{{3, 7}, {1, 7}, {4, 2}, {2, 2}},
+			y:         []float64{1, 0, 1, 0},
+			feature:   0, threshold: 2.5, impurity: 1, leftValue: 0, rightValue: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewTreeModel(tt.criterion, 1, 1)
+			if err := m.Fit(tt.x, tt.y); err != nil {
+				t.Fatal(err)
+			}
+			root := m.Root()
+			if root.Leaf() {
+				t.Fatal("the root was not split")
+			}
+			if root.Feature != tt.feature || root.Threshold != tt.threshold {
+				t.Errorf("split on x[%d] <= %v, want x[%d] <= %v", root.Feature, root.Threshold, tt.feature, tt.threshold)
+			}
+			if math.Abs(root.Impurity-tt.impurity) > 1e-12 {
+				t.Errorf("root impurity %v, want %v", root.Impurity, tt.impurity)
+			}
+			if root.Left.Value != tt.leftValue || root.Right.Value != tt.rightValue {
+				t.Errorf("leaf values %v and %v, want %v and %v", root.Left.Value, root.Right.Value, tt.leftValue, tt.rightValue)
+			}
+			if root.Left.Impurity != 0 || root.Right.Impurity != 0 {
+				t.Errorf("leaf impurities %v and %v, want pure leaves", root.Left.Impurity, root.Right.Impurity)
+			}
+			for i := range tt.x {
+				if got, _ := m.Predict(tt.x[i]); got != tt.y[i] {
+					t.Errorf("Predict(%v) = %v, want %v", tt.x[i], got, tt.y[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTreeModelPrune(t *testing.T) {
+	// The full tree splits 0 0 0 0 | 10 10 10 11 at 3.5, and then 10 10 10 | 11 at 6.5. Weighted by the 8 examples,
+	// the right node has impurity 0.1875 * 4/8 = 0.09375, so it collapses from alpha = 0.09375, and the root, of
+	// impurity 26.359375, from alpha = 26.359375 - 0.09375.
+	x := column(0, 1, 2, 3, 4, 5, 6, 7)
+	y := []float64{0, 0, 0, 0, 10, 10, 10, 11}
+	tests := []struct {
+		alpha  float64
+		leaves int
+		depth  int
+	}{
+		{0, 3, 2},
+		{0.05, 3, 2},
+		{0.09, 3, 2},
+		{0.09375, 2, 1},
+		{1, 2, 1},
+		{26.2, 2, 1},
+		{26.265625, 1, 0},
+		{100, 1, 0},
+	}
+	for _, tt := range tests {
+		m := &TreeModel{Criterion: SquaredError, CCPAlpha: tt.alpha}
+		if err := m.Fit(x, y); err != nil {
+			t.Fatal(err)
+		}
+		if m.Leaves() != tt.leaves || m.Depth() != tt.depth {
+			t.Errorf("alpha %v: %d leaves at depth %d, want %d at depth %d", tt.alpha, m.Leaves(), m.Depth(), tt.leaves, tt.depth)
+		}
+		// pruning a fitted tree further gives the same tree as pruning it while fitting
+		full := &TreeModel{Criterion: SquaredError}
+		if err := full.Fit(x, y); err != nil {
+			t.Fatal(err)
+		}
+		full.Prune(tt.alpha)
+		if full.Text() != m.Text() {
+			t.Errorf("alpha %v: Prune gave\n%s\nwant\n%s", tt.alpha, full.Text(), m.Text())
+		}
+	}
+}
+
+func TestTreeModelExport(t *testing.T) {
+	m := &TreeModel{Criterion: Gini, ClassNames: []string{"no", "yes"}}
+	if err := m.WriteDOT(&bytes.Buffer{}); err != ErrNotFitted {
+		t.Errorf("WriteDOT() of an unfitted tree returned %v, want ErrNotFitted", err)
+	}
+	if err := m.Fit(column(1, 2, 3, 4, 5, 6), []float64{0, 0, 1, 1, 1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	want := "|--- x[0] <= 2.5\n" +
+		"|   |--- class = no (samples = 2)\n" +
+		"|--- x[0] >  2.5\n" +
+		"|   |--- x[0] <= 5.5\n" +
+		"|   |   |--- class = yes (samples = 3)\n" +
+		"|   |--- x[0] >  5.5\n" +
+		"|   |   |--- class = no (samples = 1)\n"
+	if got := m.Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+
+	// a regression tree labels its nodes with their squared error and mean value
+	m = &TreeModel{Criterion: SquaredError, FeatureNames: []string{"income"}}
+	if err := m.Fit(column(1, 2, 3, 10, 11, 12), []float64{1, 1, 1, 5, 5, 5}); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := m.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	want = "digraph Tree {\n" +
+		"node [shape=box, style=\"rounded\", fontname=\"helvetica\"] ;\n" +
+		"edge [fontname=\"helvetica\"] ;\n" +
+		"0 [label=\"income <= 6.5\\nsquared_error = 4\\nsamples = 6\\nvalue = 3\"] ;\n" +
+		"1 [label=\"squared_error = 0\\nsamples = 3\\nvalue = 1\"] ;\n" +
+		"0 -> 1 [labeldistance=2.5, labelangle=45, headlabel=\"True\"] ;\n" +
+		"2 [label=\"squared_error = 0\\nsamples = 3\\nvalue = 5\"] ;\n" +
+		"0 -> 2 [labeldistance=2.5, labelangle=-45, headlabel=\"False\"] ;\n" +
+		"}\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteDOT() wrote\n%s\nwant\n%s", got, want)
+	}
+}
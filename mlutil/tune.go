@@ -0,0 +1,579 @@
+package mlutil
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Params holds a value for every hyper-parameter of a search, by name. Integer and categorical hyper-parameters are
+// stored as float64 too, eg. the number of trees or a libsvm kernel type.
+type Params map[string]float64
+
+// Int returns the value of the named hyper-parameter rounded to an integer.
+func (p Params) Int(name string) int {
+	return int(math.Round(p[name]))
+}
+
+// Names returns the names of the hyper-parameters in increasing order.
+func (p Params) Names() []string {
+	return sortedKeys(p)
+}
+
+// String formats the hyper-parameters as "name=value" pairs in order of name.
+func (p Params) String() string {
+	pairs := make([]string, 0, len(p))
+	for _, name := range p.Names() {
+		pairs = append(pairs, name+"="+formatFloat(p[name]))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// copy returns a copy of p.
+func (p Params) copy() Params {
+	ret := make(Params, len(p))
+	for k, v := range p {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Distribution is the range of values searched for a hyper-parameter.
+type Distribution interface {
+	// Grid returns the values tried by a grid search.
+	Grid() []float64
+	// Sample draws a value for a random search.
+	Sample(rnd *rand.Rand) float64
+}
+
+// Values is a distribution over a fixed list of values, all of which are tried by a grid search.
+type Values []float64
+
+// Grid implements Distribution.
+func (d Values) Grid() []float64 {
+	return d
+}
+
+// Sample implements Distribution. d must not be empty.
+func (d Values) Sample(rnd *rand.Rand) float64 {
+	return d[rnd.Intn(len(d))]
+}
+
+// Uniform is the uniform distribution over [Min, Max]. A grid search tries N evenly spaced values, including both
+// ends.
+type Uniform struct {
+	Min, Max float64
+	N        int
+}
+
+// Grid implements Distribution.
+func (d Uniform) Grid() []float64 {
+	return spaced(d.Min, d.Max, d.N, func(x float64) float64 { return x })
+}
+
+// Sample implements Distribution.
+func (d Uniform) Sample(rnd *rand.Rand) float64 {
+	return d.Min + rnd.Float64()*(d.Max-d.Min)
+}
+
+// LogUniform is the distribution whose logarithm is uniform over [log Min, log Max], suited to learning rates and
+// SVM costs that are searched over several orders of magnitude. A grid search tries N values evenly spaced on a log
+// scale.
+type LogUniform struct {
+	Min, Max float64
+	N        int
+}
+
+// Grid implements Distribution.
+func (d LogUniform) Grid() []float64 {
+	return spaced(math.Log(d.Min), math.Log(d.Max), d.N, math.Exp)
+}
+
+// Sample implements Distribution.
+func (d LogUniform) Sample(rnd *rand.Rand) float64 {
+	return math.Exp(math.Log(d.Min) + rnd.Float64()*(math.Log(d.Max)-math.Log(d.Min)))
+}
+
+// IntRange is the uniform distribution over the integers Min, Min+Step, ..., up to Max. A Step of 0 is taken as 1.
+type IntRange struct {
+	Min, Max, Step int
+}
+
+// Grid implements Distribution.
+func (d IntRange) Grid() []float64 {
+	var ret []float64
+	for v := d.Min; v <= d.Max; v += d.step() {
+		ret = append(ret, float64(v))
+	}
+	return ret
+}
+
+// Sample implements Distribution. Max must not be less than Min.
+func (d IntRange) Sample(rnd *rand.Rand) float64 {
+	return float64(d.Min + rnd.Intn((d.Max-d.Min)/d.step()+1)*d.step())
+}
+
+func (d IntRange) step() int {
+	if d.Step < 1 {
+		return 1
+	}
+	return d.Step
+}
+
+// spaced returns n values evenly spaced between min and max, mapped by f. n < 2 returns f(min) alone.
+func spaced(min, max float64, n int, f func(float64) float64) []float64 {
+	if n < 2 {
+		return []float64{f(min)}
+	}
+	ret := make([]float64, n, n)
+	for i := range ret {
+		ret[i] = f(min + float64(i)*(max-min)/float64(n-1))
+	}
+	return ret
+}
+
+// ParamSpace is the set of hyper-parameters searched over, by name.
+type ParamSpace map[string]Distribution
+
+// names returns the names of the hyper-parameters in increasing order.
+func (s ParamSpace) names() []string {
+	ret := make([]string, 0, len(s))
+	for name := range s {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Validate returns an error if the space is empty or a distribution has no values, such as an empty Values or an
+// IntRange whose Max is less than its Min.
+func (s ParamSpace) Validate() error {
+	if len(s) == 0 {
+		return errors.New("mlutil: empty parameter space")
+	}
+	for _, name := range s.names() {
+		var ok bool
+		switch d := s[name].(type) {
+		case nil:
+		case Values:
+			ok = len(d) > 0
+		case Uniform:
+			ok = d.Min <= d.Max
+		case LogUniform:
+			ok = d.Min > 0 && d.Min <= d.Max
+		case IntRange:
+			ok = d.Min <= d.Max
+		default:
+			ok = len(d.Grid()) > 0
+		}
+		if !ok {
+			return fmt.Errorf("mlutil: hyper-parameter %q has no values: %v", name, s[name])
+		}
+	}
+	return nil
+}
+
+// Grid returns every combination of the grid values of the hyper-parameters.
+func (s ParamSpace) Grid() ([]Params, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	names := s.names()
+	ret := []Params{{}}
+	for _, name := range names {
+		var next []Params
+		for _, p := range ret {
+			for _, v := range s[name].Grid() {
+				q := p.copy()
+				q[name] = v
+				next = append(next, q)
+			}
+		}
+		ret = next
+	}
+	return ret, nil
+}
+
+// Sample draws n sets of hyper-parameters from the space.
+func (s ParamSpace) Sample(rnd *rand.Rand, n int) ([]Params, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	// draw in a fixed order so that the same seed always gives the same candidates
+	names := s.names()
+	ret := make([]Params, n, n)
+	for i := range ret {
+		ret[i] = make(Params, len(s))
+		for _, name := range names {
+			ret[i][name] = s[name].Sample(rnd)
+		}
+	}
+	return ret, nil
+}
+
+// Search is a hyper-parameter search that scores every candidate set of hyper-parameters by k-fold
+// cross-validation on the same folds, running the trials in parallel.
+type Search struct {
+	// New returns an unfitted model with the given hyper-parameters.
+	New   func(p Params) (Model, error)
+	Space ParamSpace
+
+	// Scorer scores the predictions of a fold, and ScoreName names the score in the results. If Scorer is nil,
+	// Accuracy is used.
+	Scorer    Scorer
+	ScoreName string
+	// Minimise is set for scores where lower is better, such as MeanSquaredError.
+	Minimise bool
+
+	// Folds is the number of cross-validation folds, 5 if 0. If Stratify is set, the folds keep the proportions of
+	// the classes.
+	Folds    int
+	Stratify bool
+	// Workers is the maximum number of trials run at the same time. If 0, it is the number of CPUs.
+	Workers int
+	// Seed seeds the folds and the random candidates.
+	Seed int64
+}
+
+// Trial is the result of cross-validating one set of hyper-parameters.
+type Trial struct {
+	Params Params
+	// Mean and StdDev are the mean and standard deviation of the score over the folds.
+	Mean, StdDev float64
+	// Round and Resource are the round of successive halving the trial ran in and its budget. They are 0 for the
+	// other searches.
+	Round    int
+	Resource int
+	Duration time.Duration
+	// Err is the error that made the trial fail, such as a diverging optimiser. Failed trials rank last.
+	Err error
+}
+
+// SearchResult holds the trials of a search, ranked from best to worst.
+type SearchResult struct {
+	ScoreName string
+	Minimise  bool
+	Trials    []Trial
+}
+
+// Best returns the best trial. It is the zero Trial if there are none.
+func (r SearchResult) Best() Trial {
+	if len(r.Trials) == 0 {
+		return Trial{}
+	}
+	return r.Trials[0]
+}
+
+// Grid cross-validates every combination of the grid values of the space on x and y.
+func (s Search) Grid(x [][]float64, y []float64) (SearchResult, error) {
+	candidates, err := s.Space.Grid()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return s.run(x, y, candidates, 0, 0)
+}
+
+// Random cross-validates n sets of hyper-parameters drawn from the space on x and y.
+func (s Search) Random(x [][]float64, y []float64, n int) (SearchResult, error) {
+	if n < 1 {
+		return SearchResult{}, fmt.Errorf("mlutil: invalid number of candidates %d", n)
+	}
+	candidates, err := s.Space.Sample(rand.New(rand.NewSource(s.Seed)), n)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return s.run(x, y, candidates, 0, 0)
+}
+
+// Halving configures SuccessiveHalving.
+type Halving struct {
+	// Factor is 3 if 0: every round keeps the best 1/Factor of the candidates and gives them Factor times the
+	// budget.
+	Factor int
+	// Resource names the hyper-parameter that receives the budget, such as "iterations" or "epochs". If empty, the
+	// budget is the number of training examples, drawn from x at random.
+	Resource string
+	// MinResource is the budget of the first round. MaxResource caps the budget; if 0, it is the number of examples
+	// when Resource is empty.
+	MinResource, MaxResource int
+}
+
+// SuccessiveHalving cross-validates all the candidates with a small budget, keeps the best 1/Factor of them, and
+// repeats with Factor times the budget until a single candidate is left or the budget reaches MaxResource. It finds
+// good hyper-parameters among many candidates for a fraction of the cost of a full grid or random search. The
+// candidates are usually ParamSpace.Grid or ParamSpace.Sample. The ranking puts the trials of later rounds first.
+func (s Search) SuccessiveHalving(x [][]float64, y []float64, candidates []Params, h Halving) (SearchResult, error) {
+	if len(candidates) == 0 {
+		return SearchResult{}, errors.New("mlutil: no candidates")
+	}
+	factor := h.Factor
+	if factor == 0 {
+		factor = 3
+	}
+	if factor < 2 {
+		return SearchResult{}, fmt.Errorf("mlutil: invalid halving factor %d", factor)
+	}
+	max := h.MaxResource
+	if max == 0 && h.Resource == "" {
+		max = len(x)
+	}
+	if h.MinResource < 1 || max < h.MinResource {
+		return SearchResult{}, fmt.Errorf("mlutil: invalid halving budget from %d to %d", h.MinResource, max)
+	}
+
+	// draw the examples of the smaller budgets from a fixed shuffle, so that every round sees a superset of the
+	// examples of the previous one
+	order := rand.New(rand.NewSource(s.Seed)).Perm(len(x))
+	ret := SearchResult{ScoreName: s.scoreName(), Minimise: s.Minimise}
+	resource := h.MinResource
+	for round := 1; ; round++ {
+		if resource > max {
+			resource = max
+		}
+		rx, ry := x, y
+		params := candidates
+		if h.Resource == "" {
+			rx, ry = make([][]float64, resource, resource), make([]float64, resource, resource)
+			for i := range rx {
+				rx[i], ry[i] = x[order[i]], y[order[i]]
+			}
+		} else {
+			params = make([]Params, len(candidates), len(candidates))
+			for i := range candidates {
+				params[i] = candidates[i].copy()
+				params[i][h.Resource] = float64(resource)
+			}
+		}
+		r, err := s.run(rx, ry, params, round, resource)
+		if err != nil {
+			return ret, err
+		}
+		// later rounds rank first
+		ret.Trials = append(r.Trials, ret.Trials...)
+		if len(candidates) == 1 || resource == max {
+			return ret, nil
+		}
+		keep := len(candidates) / factor
+		if keep < 1 {
+			keep = 1
+		}
+		candidates = make([]Params, 0, keep)
+		for _, t := range r.Trials[:keep] {
+			if t.Err != nil {
+				break
+			}
+			p := t.Params.copy()
+			delete(p, h.Resource)
+			candidates = append(candidates, p)
+		}
+		if len(candidates) == 0 {
+			return ret, errors.New("mlutil: every trial failed")
+		}
+		resource *= factor
+	}
+}
+
+// run cross-validates every set of hyper-parameters on x and y with Workers trials at a time, and ranks them.
+func (s Search) run(x [][]float64, y []float64, candidates []Params, round, resource int) (SearchResult, error) {
+	ret := SearchResult{ScoreName: s.scoreName(), Minimise: s.Minimise}
+	if s.New == nil {
+		return ret, errors.New("mlutil: search has no model")
+	}
+	k := s.Folds
+	if k == 0 {
+		k = 5
+	}
+	folds, err := KFoldXY(y, k, s.Stratify, rand.New(rand.NewSource(s.Seed)))
+	if err != nil {
+		return ret, err
+	}
+	scorer := s.Scorer
+	if scorer == nil {
+		scorer = Accuracy
+	}
+	workers := s.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	ret.Trials = make([]Trial, len(candidates), len(candidates))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				ret.Trials[i] = s.trial(x, y, folds, candidates[i], scorer)
+				ret.Trials[i].Round, ret.Trials[i].Resource = round, resource
+			}
+		}()
+	}
+	for i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, t := range ret.Trials {
+		if t.Err != nil {
+			failed++
+		}
+	}
+	if failed == len(ret.Trials) && failed > 0 {
+		return ret, fmt.Errorf("mlutil: every trial failed, eg. %v", ret.Trials[0].Err)
+	}
+	sort.SliceStable(ret.Trials, func(i, j int) bool { return ret.better(ret.Trials[i], ret.Trials[j]) })
+	return ret, nil
+}
+
+// trial cross-validates a single set of hyper-parameters. A panic of the model, such as an index out of range for
+// an invalid hyper-parameter, fails the trial rather than the search.
+func (s Search) trial(x [][]float64, y []float64, folds [][]int, p Params, scorer Scorer) (t Trial) {
+	t = Trial{Params: p}
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Err = fmt.Errorf("mlutil: trial panicked: %v", r)
+			t.Duration = time.Since(start)
+		}
+	}()
+	cv, err := CrossValidateXY(x, y, folds, func(x [][]float64, y []float64) (Predictor, error) {
+		m, err := s.New(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Fit(x, y); err != nil {
+			return nil, err
+		}
+		return m.Predict, nil
+	}, map[string]Scorer{"score": scorer})
+	if err == nil && math.IsNaN(cv.Mean["score"]) {
+		err = errors.New("mlutil: score is NaN")
+	}
+	t.Mean, t.StdDev, t.Err = cv.Mean["score"], cv.StdDev["score"], err
+	t.Duration = time.Since(start)
+	return t
+}
+
+// better reports whether trial a ranks before trial b.
+func (r SearchResult) better(a, b Trial) bool {
+	if (a.Err == nil) != (b.Err == nil) {
+		return a.Err == nil
+	}
+	if a.Round != b.Round {
+		return a.Round > b.Round
+	}
+	if r.Minimise {
+		return a.Mean < b.Mean
+	}
+	return a.Mean > b.Mean
+}
+
+func (s Search) scoreName() string {
+	if s.ScoreName != "" {
+		return s.ScoreName
+	}
+	if s.Scorer == nil {
+		return "accuracy"
+	}
+	return "score"
+}
+
+// names returns the names of all the hyper-parameters of the trials.
+func (r SearchResult) names() []string {
+	seen := make(map[string]float64)
+	for _, t := range r.Trials {
+		for name := range t.Params {
+			seen[name] = 0
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// String formats the trials as a ranked table.
+func (r SearchResult) String() string {
+	names := r.names()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%-5s %12s %10s %6s %9s %9s", "Rank", r.ScoreName, "StdDev", "Round", "Resource", "Time")
+	for _, name := range names {
+		fmt.Fprintf(&b, " %12s", name)
+	}
+	b.WriteString("\n")
+	for i, t := range r.Trials {
+		fmt.Fprintf(&b, "%-5d %12.4f %10.4f %6d %9d %9s", i+1, t.Mean, t.StdDev, t.Round, t.Resource, t.Duration.Round(time.Millisecond))
+		for _, name := range names {
+			if v, ok := t.Params[name]; ok {
+				fmt.Fprintf(&b, " %12.6g", v)
+			} else {
+				fmt.Fprintf(&b, " %12s", "")
+			}
+		}
+		if t.Err != nil {
+			fmt.Fprintf(&b, "  error: %v", t.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// SaveCSV writes the ranked trials as CSV to path, creating any missing directories.
+func (r SearchResult) SaveCSV(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteCSV writes the ranked trials as CSV, with a header line and a column per hyper-parameter.
+func (r SearchResult) WriteCSV(w io.Writer) error {
+	names := r.names()
+	cw := csv.NewWriter(w)
+	header := append([]string{"rank", r.ScoreName, "std_dev", "round", "resource", "seconds"}, names...)
+	if err := cw.Write(append(header, "error")); err != nil {
+		return err
+	}
+	for i, t := range r.Trials {
+		record := []string{
+			strconv.Itoa(i + 1), formatFloat(t.Mean), formatFloat(t.StdDev), strconv.Itoa(t.Round), strconv.Itoa(t.Resource),
+			formatFloat(t.Duration.Seconds()),
+		}
+		for _, name := range names {
+			if v, ok := t.Params[name]; ok {
+				record = append(record, formatFloat(v))
+			} else {
+				record = append(record, "")
+			}
+		}
+		errMsg := ""
+		if t.Err != nil {
+			errMsg = t.Err.Error()
+		}
+		if err := cw.Write(append(record, errMsg)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,156 @@
+package mlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// constantSearch returns a search for the constant c that minimises the squared error. Negative constants fail.
+func constantSearch() Search {
+	return Search{
+		New: func(p Params) (Model, error) {
+			c := p["c"]
+			if c < 0 {
+				return nil, errors.New("negative constant")
+			}
+			return modelFunc(func([]float64) float64 { return c }), nil
+		},
+		Scorer:    MeanSquaredError,
+		ScoreName: "MSE",
+		Minimise:  true,
+		Folds:     2,
+		Workers:   2,
+	}
+}
+
+func TestParamSpace(t *testing.T) {
+	space := ParamSpace{
+		"a": Values{1, 2},
+		"b": IntRange{Min: 1, Max: 5, Step: 2},
+	}
+	grid, err := space.Grid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grid) != 6 || grid[0]["a"] != 1 || grid[0]["b"] != 1 || grid[5]["a"] != 2 || grid[5]["b"] != 5 {
+		t.Errorf("grid %v, want the 6 combinations from a=1 b=1 to a=2 b=5", grid)
+	}
+
+	space["lr"] = LogUniform{Min: 1e-3, Max: 1}
+	first, err := space.Sample(rand.New(rand.NewSource(7)), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := space.Sample(rand.New(rand.NewSource(7)), 20)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Sample() with the same seed drew different candidates")
+	}
+	for _, p := range first {
+		if p["lr"] < 1e-3 || p["lr"] > 1 || (p["b"] != 1 && p["b"] != 3 && p["b"] != 5) {
+			t.Errorf("sample %v outside the space", p)
+		}
+	}
+
+	for name, s := range map[string]ParamSpace{
+		"empty space":        {},
+		"empty values":       {"a": Values{}},
+		"reversed range":     {"a": IntRange{Min: 3, Max: 1}},
+		"non-positive scale": {"a": LogUniform{Min: 0, Max: 1}},
+	} {
+		if _, err := s.Grid(); err == nil {
+			t.Errorf("%s: Grid() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSearchGrid(t *testing.T) {
+	x := [][]float64{{0}, {1}, {2}, {3}, {4}, {5}}
+	y := []float64{2, 2, 2, 2, 2, 2}
+	s := constantSearch()
+	s.Space = ParamSpace{"c": Values{0, 1, -1, 2, 3}}
+	r, err := s.Grid(x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ranked []float64
+	for _, trial := range r.Trials {
+		ranked = append(ranked, trial.Params["c"])
+	}
+	if !reflect.DeepEqual(ranked, []float64{2, 1, 3, 0, -1}) {
+		t.Errorf("ranking %v, want 2, 1, 3, 0 and the failed -1 last", ranked)
+	}
+	if r.Best().Mean != 0 || r.Trials[4].Err == nil {
+		t.Errorf("best MSE %v and last error %v, want 0 and an error", r.Best().Mean, r.Trials[4].Err)
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 6 || lines[0] != "rank,MSE,std_dev,round,resource,seconds,c,error" || !strings.HasSuffix(lines[5], "negative constant") {
+		t.Errorf("CSV %q, want a header and 5 trials ending with the failure", b.String())
+	}
+
+	// a model that panics fails its trial alone
+	s.New = func(p Params) (Model, error) {
+		return modelFunc(func(x []float64) float64 { return x[int(p["c"])] }), nil
+	}
+	s.Space = ParamSpace{"c": Values{3, 0}}
+	r, err = s.Grid(x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Best().Err != nil || r.Trials[1].Params["c"] != 3 || !strings.Contains(fmt.Sprint(r.Trials[1].Err), "panicked") {
+		t.Errorf("trials %+v, want the panic of c=3 recorded as a failed trial", r.Trials)
+	}
+
+	s = constantSearch()
+	s.Space = ParamSpace{"c": Values{-1, -2}}
+	if _, err := s.Grid(x, y); err == nil {
+		t.Error("Grid() where every trial fails succeeded, want an error")
+	}
+}
+
+func TestSearchSuccessiveHalving(t *testing.T) {
+	x := make([][]float64, 12, 12)
+	y := make([]float64, 12, 12)
+	for i := range x {
+		x[i], y[i] = []float64{float64(i)}, 4
+	}
+	var candidates []Params
+	for c := 0; c < 9; c++ {
+		candidates = append(candidates, Params{"c": float64(c)})
+	}
+	s := constantSearch()
+	r, err := s.SuccessiveHalving(x, y, candidates, Halving{Resource: "iterations", MinResource: 1, MaxResource: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9 candidates with 1 iteration, 3 with 3 and 1 with 9
+	if len(r.Trials) != 13 {
+		t.Fatalf("%d trials, want 13", len(r.Trials))
+	}
+	best := r.Best()
+	if best.Params["c"] != 4 || best.Params["iterations"] != 9 || best.Round != 3 || best.Resource != 9 {
+		t.Errorf("best trial %+v, want c=4 with 9 iterations in round 3", best)
+	}
+
+	// the budget is the number of examples when no resource is named
+	r, err = s.SuccessiveHalving(x, y, candidates, Halving{MinResource: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best := r.Best(); best.Resource != 12 || best.Params["c"] != 4 {
+		t.Errorf("best trial %+v, want c=4 on all 12 examples", best)
+	}
+
+	if _, err := s.SuccessiveHalving(x, y, candidates, Halving{MinResource: 20}); err == nil {
+		t.Error("SuccessiveHalving() with a first budget above the examples succeeded, want an error")
+	}
+}